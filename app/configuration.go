@@ -2,8 +2,8 @@ package app
 
 import (
 	"fmt"
-	"io/ioutil"
 	"strconv"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
@@ -29,6 +29,28 @@ type Application struct {
 	Author      string
 	HostURL     string
 	LogLevel    string
+	Gazetteer   string
+	SMTPServer  string
+	SMTPUser    string
+	SMTPPass    string
+	MailFrom    string
+
+	// TrustedProxies a comma separated list of the addresses and networks
+	// of the proxies in front of the server.
+	TrustedProxies string
+
+	DBHost         string
+	DBPort         uint16
+	DBName         string
+	DBUser         string
+	DBPassword     string
+	DBPasswordFile string
+	DBSSLMode      string
+	DBPoolSize     uint16
+	DBIdleConns    uint16
+	DBConnLifetime time.Duration
+
+	AutoHideReports uint16
 }
 
 // ToString prints out a string representation of the configuration.
@@ -37,7 +59,7 @@ func (a *Application) ToString() string {
 }
 
 func loadConfiguration() *Application {
-	data, err := ioutil.ReadFile("app/application.yaml")
+	data, err := ReadFile("app/application.yaml")
 	if err != nil {
 		panic(err)
 	}
@@ -50,6 +72,10 @@ func loadConfiguration() *Application {
 	if err != nil {
 		panic(err)
 	}
+	autoHide, err := strconv.ParseUint(m["AutoHideReports"], 10, 16)
+	if err != nil {
+		panic(err)
+	}
 
 	return &Application{
 		Name:        m["Name"],
@@ -60,5 +86,51 @@ func loadConfiguration() *Application {
 		Author:      m["Author"],
 		HostURL:     m["HostURL"],
 		LogLevel:    m["LogLevel"],
+		Gazetteer:   m["Gazetteer"],
+		SMTPServer:  m["SMTPServer"],
+		SMTPUser:    m["SMTPUser"],
+		SMTPPass:    m["SMTPPass"],
+		MailFrom:    m["MailFrom"],
+
+		TrustedProxies: m["TrustedProxies"],
+
+		DBHost:         m["DBHost"],
+		DBPort:         optionalUint16(m["DBPort"]),
+		DBName:         m["DBName"],
+		DBUser:         m["DBUser"],
+		DBPassword:     m["DBPassword"],
+		DBPasswordFile: m["DBPasswordFile"],
+		DBSSLMode:      m["DBSSLMode"],
+		DBPoolSize:     optionalUint16(m["DBPoolSize"]),
+		DBIdleConns:    optionalUint16(m["DBIdleConns"]),
+		DBConnLifetime: optionalDuration(m["DBConnLifetime"]),
+
+		AutoHideReports: uint16(autoHide),
+	}
+}
+
+// optionalUint16 parses a setting which may be left out, in which case it is
+// zero.
+func optionalUint16(value string) uint16 {
+	if len(value) == 0 {
+		return 0
+	}
+	v, err := strconv.ParseUint(value, 10, 16)
+	if err != nil {
+		panic(err)
+	}
+	return uint16(v)
+}
+
+// optionalDuration parses a duration such as 30m which may be left out, in
+// which case it is zero.
+func optionalDuration(value string) time.Duration {
+	if len(value) == 0 {
+		return 0
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		panic(err)
 	}
+	return d
 }
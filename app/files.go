@@ -0,0 +1,86 @@
+package app
+
+import (
+	"io/fs"
+	"os"
+	"sort"
+)
+
+// Files the configuration, migrations and web assets, read by their path from
+// the root of the project such as assets/css/main.css. Until SetFiles is
+// called they are read from the working directory.
+var Files fs.FS = os.DirFS(".")
+
+// SetFiles sets the files the application reads from. When the override
+// directory is set its files are read in place of the embedded ones, so they
+// can be edited without rebuilding.
+func SetFiles(embedded fs.FS, overrideDir string) {
+	if len(overrideDir) == 0 {
+		Files = embedded
+	} else {
+		Files = overlayFS{os.DirFS(overrideDir), embedded}
+	}
+}
+
+// ReadFile reads a file from the application's files.
+func ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(Files, name)
+}
+
+// ReadDir reads a directory of the application's files, sorted by name.
+func ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(Files, name)
+}
+
+// OpenFile opens a file from the application's files.
+func OpenFile(name string) (fs.File, error) {
+	return Files.Open(name)
+}
+
+// overlayFS reads files from the top filesystem when they are found there,
+// and from the bottom one otherwise. Directories list the files of both.
+type overlayFS struct {
+	top    fs.FS
+	bottom fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	if f, err := o.top.Open(name); err == nil {
+		return f, nil
+	}
+	return o.bottom.Open(name)
+}
+
+func (o overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	top, topErr := fs.ReadDir(o.top, name)
+	bottom, bottomErr := fs.ReadDir(o.bottom, name)
+	if topErr != nil && bottomErr != nil {
+		return nil, bottomErr
+	}
+	entries := make(map[string]fs.DirEntry, len(top)+len(bottom))
+	for _, e := range bottom {
+		entries[e.Name()] = e
+	}
+	for _, e := range top {
+		entries[e.Name()] = e
+	}
+	merged := make([]fs.DirEntry, 0, len(entries))
+	for _, e := range entries {
+		merged = append(merged, e)
+	}
+	sort.Sort(dirEntrySorter(merged))
+	return merged, nil
+}
+
+func (o overlayFS) Stat(name string) (fs.FileInfo, error) {
+	if info, err := fs.Stat(o.top, name); err == nil {
+		return info, nil
+	}
+	return fs.Stat(o.bottom, name)
+}
+
+type dirEntrySorter []fs.DirEntry
+
+func (l dirEntrySorter) Len() int           { return len(l) }
+func (l dirEntrySorter) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l dirEntrySorter) Less(i, j int) bool { return l[i].Name() < l[j].Name() }
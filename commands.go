@@ -0,0 +1,295 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rchargel/localiday/app"
+	"github.com/rchargel/localiday/db"
+	"github.com/rchargel/localiday/geo"
+	"github.com/rchargel/localiday/services"
+	"github.com/rchargel/localiday/web"
+)
+
+// heatmapRegeneration the cron schedule dirty heatmap tiles are regenerated on.
+const heatmapRegeneration = "30 * * * * *"
+
+// serve migrates the database up to the configured version and starts the
+// application server. It will not start against a database which is newer
+// than the configured version, rolling back is left to migrate down.
+func serve(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	flags.Parse(args)
+
+	start := time.Now()
+	config := app.LoadConfiguration()
+	fmt.Println(config.ToString())
+
+	cores := runtime.NumCPU()
+	runtime.GOMAXPROCS(cores)
+	app.Log(app.Info, "Running on %v cores.", cores)
+	sport := os.Getenv("PORT")
+
+	port, err := strconv.ParseUint(sport, 10, 16)
+	if err != nil {
+		return fmt.Errorf("Could not read port %q: %v.", sport, err)
+	}
+	conn, dbConfig, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	migrator, err := db.NewMigrator(conn, dbConfig.Dialect)
+	if err != nil {
+		return err
+	}
+	current, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+	if current > config.DBVersion {
+		return fmt.Errorf("The database is at version %v, which is newer than version %v of this build, use migrate down --to %v to roll it back.",
+			current, config.DBVersion, config.DBVersion)
+	}
+	if err = printPlan(migrator, config.DBVersion); err != nil {
+		return err
+	}
+	if err = migrator.MigrateUp(config.DBVersion); err != nil {
+		return fmt.Errorf("Could not migrate database: %v.", err)
+	}
+	db.NewDatabase(conn, dbConfig)
+	err = db.BootStrap()
+	if err != nil {
+		return fmt.Errorf("Could not bootstrap database: %v.", err)
+	}
+	var geocoder geo.Geocoder
+	if len(config.Gazetteer) > 0 {
+		gazetteer, err := geo.LoadGazetteerFile(config.Gazetteer)
+		if err != nil {
+			return fmt.Errorf("Could not load gazetteer: %v.", err)
+		}
+		geocoder = gazetteer
+	}
+	proxies, err := web.ParseNetworks(config.TrustedProxies)
+	if err != nil {
+		return fmt.Errorf("Could not read the trusted proxies: %v.", err)
+	}
+	displays, err := services.LoadDisplayIndex()
+	if err != nil {
+		return fmt.Errorf("Could not index displays: %v.", err)
+	}
+	clusterer := geo.NewClusterer(displays.InBox)
+	displays.OnChange(clusterer.Invalidate)
+	heatmap := geo.NewHeatmap(services.DisplayHeatSource(displays))
+	displays.OnChange(heatmap.MarkDirty)
+	if err = heatmap.StartRegeneration(heatmapRegeneration); err != nil {
+		return fmt.Errorf("Could not start heatmap regeneration: %v.", err)
+	}
+	defer heatmap.StopRegeneration()
+	app.Log(app.Info, "Application started in %v.", time.Since(start))
+
+	appServer := web.AppServer{Port: uint16(port), Geocoder: geocoder, Displays: displays, Clusterer: clusterer, Heatmap: heatmap,
+		TrustedProxies: proxies}
+	stopOnSignal(appServer)
+	appServer.Start()
+	app.Log(app.Info, "Server stopped.")
+	return nil
+}
+
+// stopOnSignal stops the server when the process is interrupted or
+// terminated, so that serve can shut down cleanly.
+func stopOnSignal(server web.AppServer) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		s := <-signals
+		app.Log(app.Info, "Received %v, stopping the server.", s)
+		server.Stop()
+	}()
+}
+
+// migrate runs the migrate subcommands.
+func migrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New("Missing migrate command, one of status, up, down or new.")
+	}
+	switch args[0] {
+	case "status":
+		return migrateStatus()
+	case "up":
+		flags := flag.NewFlagSet("migrate up", flag.ExitOnError)
+		flags.Parse(args[1:])
+		return migrateTo(-1)
+	case "down":
+		flags := flag.NewFlagSet("migrate down", flag.ExitOnError)
+		to := flags.Int("to", -1, "The version to roll back to.")
+		flags.Parse(args[1:])
+		if *to < 0 {
+			return errors.New("Missing the version to roll back to, use migrate down --to N.")
+		}
+		return migrateTo(*to)
+	case "new":
+		if len(args) < 2 {
+			return errors.New("Missing the name of the migration, use migrate new <name>.")
+		}
+		projectDir := filesDir
+		if len(projectDir) == 0 {
+			projectDir = "."
+		}
+		update, rollback, err := db.CreateMigration(projectDir, strings.Join(args[1:], " "))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created %v and %v.\n", update, rollback)
+		fmt.Println("Set DBVersion in app/application.yaml to have serve apply it, and rebuild to embed it.")
+		return nil
+	}
+	return fmt.Errorf("Unknown migrate command %v.", args[0])
+}
+
+// database runs the db subcommands.
+func database(args []string) error {
+	if len(args) == 0 || args[0] != "reset" {
+		return errors.New("Missing db command, use db reset --confirm.")
+	}
+	flags := flag.NewFlagSet("db reset", flag.ExitOnError)
+	confirm := flags.Bool("confirm", false, "Confirms that every table and all of its data is to be dropped.")
+	flags.Parse(args[1:])
+
+	migrator, err := openMigrator()
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	down, err := migrator.Plan(0)
+	if err != nil {
+		return err
+	}
+	up := make([]db.MigrationStep, 0, len(migrator.Migrations()))
+	for _, m := range migrator.Migrations() {
+		up = append(up, db.MigrationStep{Migration: m, Up: true})
+	}
+	fmt.Println("Resetting the database will drop every table and ALL OF ITS DATA:")
+	printSteps(append(down, up...))
+	if !*confirm {
+		return errors.New("Nothing was run, use db reset --confirm to reset the database.")
+	}
+
+	if err = migrator.MigrateTo(0); err != nil {
+		return err
+	}
+	return migrator.MigrateTo(migrator.Latest())
+}
+
+// migrateTo migrates to the version, or to the newest version when it is
+// negative, printing the plan first.
+func migrateTo(version int) error {
+	migrator, err := openMigrator()
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	target := migrator.Latest()
+	if version >= 0 {
+		target = uint16(version)
+	}
+	current, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+	if target > current && version >= 0 {
+		return fmt.Errorf("The database is at version %v, use migrate up to go to %v.", current, target)
+	}
+	steps, err := migrator.Plan(target)
+	if err != nil {
+		return err
+	}
+	if len(steps) == 0 {
+		fmt.Printf("The database is at version %v, nothing to migrate.\n", current)
+		return nil
+	}
+	fmt.Printf("Migrating the database from version %v to %v:\n", current, target)
+	printSteps(steps)
+	return migrator.MigrateTo(target)
+}
+
+// migrateStatus prints every migration and whether it has been applied.
+func migrateStatus() error {
+	migrator, err := openMigrator()
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	statuses, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tFILE\tAPPLIED\tDURATION\t")
+	for _, s := range statuses {
+		applied, duration := "pending", ""
+		if s.Applied != nil {
+			applied = s.Applied.Applied.Format("2006-01-02 15:04:05")
+			duration = (time.Duration(s.Applied.DurationMs) * time.Millisecond).String()
+			if s.Edited {
+				applied += " (EDITED SINCE)"
+			}
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t\n", s.Version, s.Name, applied, duration)
+	}
+	return w.Flush()
+}
+
+// printPlan prints the steps which would migrate the database to the version.
+func printPlan(migrator *db.Migrator, version uint16) error {
+	steps, err := migrator.Plan(version)
+	if err != nil {
+		return err
+	}
+	if len(steps) > 0 {
+		fmt.Printf("Migrating the database to version %v:\n", version)
+		printSteps(steps)
+	}
+	return nil
+}
+
+func printSteps(steps []db.MigrationStep) {
+	for _, step := range steps {
+		fmt.Printf("  %v\n", step)
+	}
+}
+
+// openDatabase opens the connection pool using the configured settings.
+func openDatabase() (*sql.DB, *db.Config, error) {
+	config, err := db.LoadConfig(databaseURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn, err := db.Open(config)
+	return conn, config, err
+}
+
+func openMigrator() (*db.Migrator, error) {
+	conn, config, err := openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	migrator, err := db.NewMigrator(conn, config.Dialect)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return migrator, nil
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/coopernurse/gorp"
+)
+
+// notDeleted the condition which excludes soft deleted rows, added to every
+// query of an audited table unless deleted rows are wanted.
+const notDeleted = "deleted_at is null"
+
+// Audited the created and updated times, version and soft deletion of an
+// entity. Embedded in a table's struct, its gorp hooks maintain the times and
+// gorp checks the version on every update, refusing to overwrite a row which
+// has changed since it was read.
+type Audited struct {
+	Created   time.Time `structs:",omitnested"`
+	Updated   time.Time `structs:",omitnested"`
+	Version   int64
+	DeletedAt *time.Time `db:"deleted_at" structs:",omitnested"`
+}
+
+// PreInsert sets the created and updated times.
+func (a *Audited) PreInsert(s gorp.SqlExecutor) error {
+	a.Created = time.Now()
+	a.Updated = a.Created
+	return nil
+}
+
+// PreUpdate sets the updated time.
+func (a *Audited) PreUpdate(s gorp.SqlExecutor) error {
+	a.Updated = time.Now()
+	return nil
+}
+
+// touch sets the updated time and the next version, as saving does.
+func (a *Audited) touch() {
+	a.Updated = time.Now()
+	a.Version++
+}
+
+// IsDeleted determines if the entity has been soft deleted.
+func (a Audited) IsDeleted() bool {
+	return a.DeletedAt != nil
+}
+
+// IsConflict determines if the error is from saving an entity which was
+// changed by someone else after it was read.
+func IsConflict(err error) bool {
+	if e, ok := err.(gorp.OptimisticLockError); ok {
+		return e.RowExists
+	}
+	return false
+}
+
+// softDelete marks a row of an audited table as deleted, so that it is left
+// out of queries until it is restored.
+func softDelete(table string, id int64) error {
+	now := time.Now()
+	n, err := newQuery("update "+table+" set deleted_at = $1, updated = $1, version = version + 1 where id = $2 and "+notDeleted,
+		now, id).exec()
+	if err == nil && n == 0 {
+		err = fmt.Errorf("Could not find row %v of %v to delete.", id, table)
+	}
+	return err
+}
+
+// softDeleteOwned soft deletes a row of an audited table which belongs to the
+// user.
+func softDeleteOwned(table string, id, userID int64) error {
+	now := time.Now()
+	n, err := newQuery("update "+table+" set deleted_at = $1, updated = $1, version = version + 1 where id = $2 and user_id = $3 and "+notDeleted,
+		now, id, userID).exec()
+	if err == nil && n == 0 {
+		err = fmt.Errorf("Could not find row %v of %v to delete.", id, table)
+	}
+	return err
+}
+
+// restore restores a soft deleted row of an audited table.
+func restore(table string, id int64) error {
+	n, err := newQuery("update "+table+" set deleted_at = null, updated = $1, version = version + 1 where id = $2 and deleted_at is not null",
+		time.Now(), id).exec()
+	if err == nil && n == 0 {
+		err = fmt.Errorf("Could not find deleted row %v of %v to restore.", id, table)
+	}
+	return err
+}
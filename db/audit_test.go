@@ -0,0 +1,122 @@
+//go:build sqlite
+// +build sqlite
+
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckInSoftDelete(t *testing.T) {
+	useTestDatabase(t)
+	user, other := createTestUser(t), createTestUser(t)
+	checkIn := &CheckIn{UserID: user.ID, DisplayID: 42}
+	if err := CreateCheckIn(checkIn, nil); err != nil {
+		t.Fatal(err)
+	}
+	if checkIn.Version != 1 || checkIn.Created.IsZero() || !checkIn.Updated.Equal(checkIn.Created) {
+		t.Errorf("New check-in should be at version 1 with its created time: %+v", checkIn.Audited)
+	}
+
+	if err := DeleteCheckIn(other.ID, checkIn.ID); err == nil {
+		t.Error("Deleted another user's check-in")
+	}
+	if err := DeleteCheckIn(user.ID, checkIn.ID); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := FindCheckIns(user.ID, 0, 10); len(found) != 0 {
+		t.Errorf("Found %v check-ins after deleting the only one", len(found))
+	}
+	if counts, _ := CountCheckInsByDisplay([]int64{42}); counts[42] != 0 {
+		t.Errorf("Counted %v check-ins at a display after deleting them", counts[42])
+	}
+	// the limit on checking in still counts it.
+	if n := CountCheckInsSince(user.ID, 42, time.Now().Add(-time.Hour)); n != 1 {
+		t.Errorf("CountCheckInsSince = %v, expected the deleted check-in to count", n)
+	}
+
+	if err := RestoreCheckIn(checkIn.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := RestoreCheckIn(checkIn.ID); err == nil {
+		t.Error("Restored a check-in which was not deleted")
+	}
+	if found, _ := FindCheckIns(user.ID, 0, 10); len(found) != 1 || found[0].Version != 3 {
+		t.Errorf("Found %+v after restoring, expected the check-in at version 3", found)
+	}
+}
+
+func TestContestSoftDeleteAndClose(t *testing.T) {
+	useTestDatabase(t)
+	user := createTestUser(t)
+	now := time.Now()
+	contest := &Contest{Name: "Audited", Holiday: "CHRISTMAS", Latitude: 34, Longitude: -90, RadiusKm: 5,
+		VotingStart: now.Add(-time.Hour), VotingEnd: now.Add(time.Hour), CreatedBy: user.ID}
+	if err := CreateContest(contest, []int64{42}); err != nil {
+		t.Fatal(err)
+	}
+
+	stale, _ := (Contest{}).Get(contest.ID)
+	if err := contest.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if contest.Status != ContestClosed || contest.Version != 2 {
+		t.Errorf("Closed contest is %v at version %v, expected %v at version 2", contest.Status, contest.Version, ContestClosed)
+	}
+	if err := stale.Close(); !IsConflict(err) {
+		t.Errorf("Closing a contest twice should conflict, got %v", err)
+	}
+	if stale.Status != ContestOpen {
+		t.Error("A failed close should leave the contest as it was read")
+	}
+
+	if err := DeleteContest(contest.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (Contest{}).Get(contest.ID); err == nil {
+		t.Error("Got a deleted contest")
+	}
+	if err := DeleteContest(contest.ID); err == nil {
+		t.Error("Deleted a contest twice")
+	}
+	if err := RestoreContest(contest.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (Contest{}).Get(contest.ID); err != nil {
+		t.Errorf("Could not get a restored contest: %v", err)
+	}
+}
+
+func TestReportClaimConflicts(t *testing.T) {
+	useTestDatabase(t)
+	reporter, moderator, reported := createTestUser(t), createTestUser(t), createTestUser(t)
+	report, err := CreateReport(ItemUser, reported.ID, reporter.ID, reportReasons[0], "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := (Report{}).Get(report.ID)
+	second, _ := (Report{}).Get(report.ID)
+	if err = first.Claim(moderator.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err = second.Claim(reporter.ID); !IsConflict(err) {
+		t.Errorf("Claiming a report claimed by someone else should conflict, got %v", err)
+	}
+	if found, _ := (Report{}).Get(report.ID); found.ClaimedBy != moderator.ID || found.Version != 2 {
+		t.Errorf("Report is claimed by %v at version %v, expected %v at version 2", found.ClaimedBy, found.Version, moderator.ID)
+	}
+
+	if err = DeleteReport(report.ID); err != nil {
+		t.Fatal(err)
+	}
+	if n := CountActiveReports(ItemUser, reported.ID); n != 0 {
+		t.Errorf("Counted %v active reports after deleting the only one", n)
+	}
+	if _, err = CreateReport(ItemUser, reported.ID, reporter.ID, reportReasons[0], ""); err == nil {
+		t.Error("A user may only report an item once, even after the report is deleted")
+	}
+	if err = RestoreReport(report.ID); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -27,10 +27,28 @@ func BootStrap() error {
 }
 
 func initORM() error {
-	DB.AddTableWithName(User{}, "users").SetKeys(true, "ID")
-	DB.AddTableWithName(Role{}, "roles").SetKeys(true, "ID")
+	DB.AddTableWithName(User{}, "users").SetKeys(true, "ID").SetVersionCol("Version")
+	DB.AddTableWithName(Role{}, "roles").SetKeys(true, "ID").SetVersionCol("Version")
 	DB.AddTableWithName(UserRole{}, "user_roles").SetKeys(true, "ID")
 	DB.AddTableWithName(Session{}, "sessions").SetKeys(true, "ID")
+	DB.AddTableWithName(Tour{}, "tours").SetKeys(true, "ID").SetVersionCol("Version")
+	DB.AddTableWithName(TourStop{}, "tour_stops").SetKeys(true, "ID")
+	DB.AddTableWithName(Report{}, "reports").SetKeys(true, "ID").SetVersionCol("Version")
+	DB.AddTableWithName(ModeratedItem{}, "moderated_items").SetKeys(true, "ID")
+	DB.AddTableWithName(ModerationDecision{}, "moderation_decisions").SetKeys(true, "ID")
+	DB.AddTableWithName(StatusReport{}, "status_reports").SetKeys(true, "ID").SetVersionCol("Version")
+	DB.AddTableWithName(Contest{}, "contests").SetKeys(true, "ID").SetVersionCol("Version")
+	DB.AddTableWithName(ContestEntry{}, "contest_entries").SetKeys(true, "ID")
+	DB.AddTableWithName(ContestVote{}, "contest_votes").SetKeys(true, "ID")
+	DB.AddTableWithName(Follow{}, "follows").SetKeys(true, "ID")
+	DB.AddTableWithName(FollowedArea{}, "followed_areas").SetKeys(true, "ID")
+	DB.AddTableWithName(Activity{}, "activities").SetKeys(true, "ID")
+	DB.AddTableWithName(Notification{}, "notifications").SetKeys(true, "ID")
+	DB.AddTableWithName(NotificationPreference{}, "notification_preferences").SetKeys(true, "ID")
+	DB.AddTableWithName(CheckIn{}, "check_ins").SetKeys(true, "ID").SetVersionCol("Version")
+	DB.AddTableWithName(UserBadge{}, "user_badges").SetKeys(true, "ID")
+	DB.AddTableWithName(Display{}, "displays").SetKeys(true, "ID").SetVersionCol("Version")
+	DB.AddTableWithName(Favorite{}, "favorites").SetKeys(true, "ID")
 
 	return nil
 }
@@ -63,6 +81,9 @@ func initCron() error {
 	c := cron.New()
 
 	err = c.AddFunc("0 */5 * * * *", func() { CleanSessions() })
+	if err == nil {
+		err = c.AddFunc("0 * * * * *", func() { CloseExpiredContests() })
+	}
 	c.Start()
 
 	return err
@@ -72,9 +93,9 @@ func insert(obj interface{}) error {
 	return DB.Insert(obj)
 }
 
-func count(script string) uint32 {
+func count(script string, args ...interface{}) uint32 {
 	var v uint32
-	i, err := DB.SelectInt(script)
+	i, err := DB.SelectInt(script, args...)
 
 	if err != nil {
 		app.Log(app.Error, "Could not count items in table.", err)
@@ -0,0 +1,142 @@
+package db
+
+import (
+	"strings"
+	"time"
+)
+
+// CheckIn a user's visit to a display. Verified check-ins were made with the
+// device within reach of the display. Located check-ins know where the
+// display is, for the user's visit map.
+type CheckIn struct {
+	ID        int64
+	UserID    int64 `db:"user_id"`
+	DisplayID int64 `db:"display_id"`
+	Verified  bool
+	Located   bool
+	Latitude  float64
+	Longitude float64
+	Holidays  string
+
+	Audited
+}
+
+// UserBadge a badge a user has earned.
+type UserBadge struct {
+	ID      int64
+	UserID  int64 `db:"user_id"`
+	Badge   string
+	Created time.Time
+}
+
+// CreateCheckIn records a check-in, with the keys of the holidays the display
+// is put up for.
+func CreateCheckIn(checkIn *CheckIn, holidays []string) error {
+	checkIn.Holidays = strings.Join(holidays, ",")
+	return insert(checkIn)
+}
+
+// DeleteCheckIn soft deletes one of the user's check-ins. Badges it earned are
+// kept.
+func DeleteCheckIn(userID, checkInID int64) error {
+	return softDeleteOwned("check_ins", checkInID, userID)
+}
+
+// RestoreCheckIn restores a deleted check-in.
+func RestoreCheckIn(checkInID int64) error {
+	return restore("check_ins", checkInID)
+}
+
+// HolidayKeys gets the keys of the holidays the display was put up for at the
+// check-in.
+func (c CheckIn) HolidayKeys() []string {
+	if len(c.Holidays) == 0 {
+		return []string{}
+	}
+	return strings.Split(c.Holidays, ",")
+}
+
+// FindCheckIns finds the user's check-ins, newest first.
+func FindCheckIns(userID int64, offset, max int) ([]CheckIn, error) {
+	var checkIns []CheckIn
+	_, err := DB.Select(&checkIns, "select * from check_ins where user_id = $1 and "+notDeleted+" order by created desc limit $2 offset $3",
+		userID, max, offset)
+	return checkIns, err
+}
+
+// FindLocatedCheckIns finds the user's check-ins which can be shown on a map.
+func FindLocatedCheckIns(userID int64) ([]CheckIn, error) {
+	var checkIns []CheckIn
+	_, err := DB.Select(&checkIns, "select * from check_ins where user_id = $1 and located and "+notDeleted+" order by created", userID)
+	return checkIns, err
+}
+
+// CountCheckInsSince counts the user's check-ins at the display since the
+// time. Deleted check-ins are counted, so deleting one cannot get around the
+// limit on checking in.
+func CountCheckInsSince(userID, displayID int64, since time.Time) uint32 {
+	return count("select count(*) from check_ins where user_id = $1 and display_id = $2 and created > $3",
+		userID, displayID, since)
+}
+
+// CountCheckInsByDisplay counts the check-ins at each of the displays.
+func CountCheckInsByDisplay(displayIDs []int64) (map[int64]int64, error) {
+	return countByDisplay("check_ins", notDeleted, displayIDs)
+}
+
+// CountDisplaysVisitedSince counts the different displays the user has
+// checked in at since the time.
+func CountDisplaysVisitedSince(userID int64, since time.Time) uint32 {
+	return count("select count(distinct display_id) from check_ins where user_id = $1 and created > $2 and "+notDeleted, userID, since)
+}
+
+// FindHolidaysVisited finds the keys of every holiday the user has checked in
+// at a display during.
+func FindHolidaysVisited(userID int64) ([]string, error) {
+	var lists []string
+	_, err := DB.Select(&lists, "select distinct holidays from check_ins where user_id = $1 and holidays <> '' and "+notDeleted, userID)
+	found := make(map[string]bool, 10)
+	keys := make([]string, 0, 10)
+	for _, list := range lists {
+		for _, key := range strings.Split(list, ",") {
+			if !found[key] {
+				found[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys, err
+}
+
+// CountContestsWithWinnersVisited counts the closed contests where the user
+// has checked in at every winning display.
+func CountContestsWithWinnersVisited(userID int64) uint32 {
+	return count(`select count(*) from contests c where c.status = $1 and c.deleted_at is null
+		and exists (select 1 from contest_entries e where e.contest_id = c.id and e.winner)
+		and not exists (select 1 from contest_entries e where e.contest_id = c.id and e.winner
+			and not exists (select 1 from check_ins ci where ci.user_id = $2 and ci.display_id = e.display_id and ci.deleted_at is null))`,
+		ContestClosed, userID)
+}
+
+// AwardBadge awards a badge to the user, returning false if they already have it.
+func AwardBadge(userID int64, badge string) (bool, error) {
+	if HasBadge(userID, badge) {
+		return false, nil
+	}
+	if err := insert(&UserBadge{UserID: userID, Badge: badge, Created: time.Now()}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// HasBadge checks to see if the user has earned the badge.
+func HasBadge(userID int64, badge string) bool {
+	return count("select count(*) from user_badges where user_id = $1 and badge = $2", userID, badge) > 0
+}
+
+// FindBadges finds the badges the user has earned, oldest first.
+func FindBadges(userID int64) ([]UserBadge, error) {
+	var badges []UserBadge
+	_, err := DB.Select(&badges, "select * from user_badges where user_id = $1 order by created", userID)
+	return badges, err
+}
@@ -0,0 +1,289 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rchargel/localiday/app"
+)
+
+// Defines the states of a contest. Results are frozen once a contest closes.
+const (
+	ContestOpen   = "OPEN"
+	ContestClosed = "CLOSED"
+)
+
+// Contest a "best display" contest for an area and holiday.
+type Contest struct {
+	ID          int64
+	Name        string
+	Holiday     string
+	Latitude    float64
+	Longitude   float64
+	RadiusKm    float64   `db:"radius_km"`
+	VotingStart time.Time `db:"voting_start"`
+	VotingEnd   time.Time `db:"voting_end"`
+	Status      string
+	CreatedBy   int64 `db:"created_by"`
+
+	Audited
+}
+
+// ContestEntry a display eligible in a contest. Votes, rank and winner are
+// set when the contest closes, or calculated for a live leaderboard.
+type ContestEntry struct {
+	ID        int64
+	ContestID int64 `db:"contest_id"`
+	DisplayID int64 `db:"display_id"`
+	Votes     int64
+	Rank      int64
+	Winner    bool
+}
+
+// ContestVote a user's vote in a contest. Flagged votes are suspected of fraud
+// and are not counted.
+type ContestVote struct {
+	ID        int64
+	ContestID int64  `db:"contest_id"`
+	DisplayID int64  `db:"display_id"`
+	UserID    int64  `db:"user_id"`
+	IPAddress string `db:"ip_address"`
+	Flagged   bool
+	Created   time.Time
+}
+
+// CreateContest creates a new open contest with its eligible displays.
+func CreateContest(contest *Contest, displayIDs []int64) error {
+	if len(displayIDs) == 0 {
+		return errors.New("A contest must have eligible displays.")
+	}
+	if !contest.VotingEnd.After(contest.VotingStart) {
+		return errors.New("Voting must end after it starts.")
+	}
+	contest.Status = ContestOpen
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	if err = tx.Insert(contest); err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, id := range displayIDs {
+		if err = tx.Insert(&ContestEntry{ContestID: contest.ID, DisplayID: id}); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	app.Log(app.Info, "Created contest %v with %v displays.", contest.Name, len(displayIDs))
+	return tx.Commit()
+}
+
+// Get gets the contest by the ID.
+func (c Contest) Get(contestID int64) (*Contest, error) {
+	err := DB.SelectOne(&c, "select * from contests where id = $1 and "+notDeleted, contestID)
+	return &c, err
+}
+
+// FindContests finds the contests with the status, newest first.
+func (c Contest) FindContests(status string) ([]Contest, error) {
+	var contests []Contest
+	_, err := DB.Select(&contests, "select * from contests where status = $1 and "+notDeleted+" order by voting_end desc", status)
+	return contests, err
+}
+
+// DeleteContest soft deletes a contest. Its votes are left out of display
+// popularity until it is restored.
+func DeleteContest(contestID int64) error {
+	return softDelete("contests", contestID)
+}
+
+// RestoreContest restores a deleted contest.
+func RestoreContest(contestID int64) error {
+	return restore("contests", contestID)
+}
+
+// IsVotingOpen checks to see if votes may be cast at the time.
+func (c *Contest) IsVotingOpen(t time.Time) bool {
+	return c.Status == ContestOpen && !t.Before(c.VotingStart) && t.Before(c.VotingEnd)
+}
+
+// IsEligible checks to see if the display is entered in the contest.
+func (c *Contest) IsEligible(displayID int64) bool {
+	return count("select count(*) from contest_entries where contest_id = $1 and display_id = $2", c.ID, displayID) > 0
+}
+
+// HasVoted checks to see if the user has voted in the contest.
+func (c *Contest) HasVoted(userID int64) bool {
+	return count("select count(*) from contest_votes where contest_id = $1 and user_id = $2", c.ID, userID) > 0
+}
+
+// CountVotesByDisplay counts the unflagged votes each of the displays has
+// received in every contest which has not been deleted.
+func CountVotesByDisplay(displayIDs []int64) (map[int64]int64, error) {
+	return countByDisplay("contest_votes", "not flagged and contest_id in (select id from contests where "+notDeleted+")", displayIDs)
+}
+
+// CountNewAccountVotes counts the votes for a display cast since the given
+// time by accounts created after the account time.
+func (c *Contest) CountNewAccountVotes(displayID int64, since, accountsSince time.Time) uint32 {
+	return count(`select count(*) from contest_votes v join users u on u.id = v.user_id
+		where v.contest_id = $1 and v.display_id = $2 and v.created > $3 and u.created > $4`,
+		c.ID, displayID, since, accountsSince)
+}
+
+// FlagNewAccountVotes flags the votes counted by CountNewAccountVotes so they
+// are left out of the results.
+func (c *Contest) FlagNewAccountVotes(displayID int64, since, accountsSince time.Time) (int64, error) {
+	result, err := DB.Exec(`update contest_votes set flagged = true where contest_id = $1 and display_id = $2
+		and created > $3 and user_id in (select id from users where created > $4)`,
+		c.ID, displayID, since, accountsSince)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// CastVote records a vote while voting is open, unless maxPerIP votes have
+// already been cast in the contest from its address. The contest is locked
+// while it is checked and its votes are counted, so votes cast at the same
+// time cannot pass the limit together, nor be cast as it closes. A user may
+// only vote once in a contest.
+func CastVote(vote *ContestVote, maxPerIP uint32) error {
+	vote.Created = time.Now()
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	contest, err := lockContest(tx, vote.ContestID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if !contest.IsVotingOpen(vote.Created) {
+		tx.Rollback()
+		return errors.New("Voting is not open for this contest.")
+	}
+	n, err := tx.SelectInt("select count(*) from contest_votes where contest_id = $1 and ip_address = $2", vote.ContestID, vote.IPAddress)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if n >= int64(maxPerIP) {
+		tx.Rollback()
+		return errors.New("Too many votes have been cast from your network.")
+	}
+	if err = tx.Insert(vote); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// lockContest locks the contest's row, or all of a SQLite database, until the
+// transaction ends, and reads the contest as it is locked.
+func lockContest(tx *Transaction, contestID int64) (*Contest, error) {
+	if _, err := tx.Exec("update contests set status = status where id = $1", contestID); err != nil {
+		return nil, err
+	}
+	var contest Contest
+	if err := tx.SelectOne(&contest, "select * from contests where id = $1 and "+notDeleted, contestID); err != nil {
+		return nil, fmt.Errorf("Could not find contest %v.", contestID)
+	}
+	return &contest, nil
+}
+
+// Leaderboard gets the contest's entries, highest ranked first. The frozen
+// results are returned for a closed contest, otherwise the current unflagged
+// votes are counted.
+func (c *Contest) Leaderboard() ([]ContestEntry, error) {
+	var entries []ContestEntry
+	if c.Status == ContestClosed {
+		_, err := DB.Select(&entries, "select * from contest_entries where contest_id = $1 order by rank, display_id", c.ID)
+		return entries, err
+	}
+	return countEntries(DB, c.ID)
+}
+
+// countEntries counts the unflagged votes for each of the contest's entries,
+// ranking them.
+func countEntries(ex executor, contestID int64) ([]ContestEntry, error) {
+	var entries []ContestEntry
+	_, err := ex.Select(&entries, `select e.id, e.contest_id, e.display_id, count(v.id) as votes, 0 as rank, false as winner
+		from contest_entries e left join contest_votes v
+		on v.contest_id = e.contest_id and v.display_id = e.display_id and not v.flagged
+		where e.contest_id = $1 group by e.id, e.contest_id, e.display_id order by votes desc, e.display_id`, contestID)
+	if err != nil {
+		return entries, err
+	}
+	rankEntries(entries)
+	return entries, nil
+}
+
+// Close freezes the contest's results and awards its winners, every display
+// sharing the most votes. The contest is locked while its votes are counted.
+// It fails with a conflict if the contest was changed, or closed by someone
+// else, after it was read.
+func (c *Contest) Close() error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err = lockContest(tx, c.ID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	closed := *c
+	closed.Status = ContestClosed
+	if _, err = tx.Update(&closed); err != nil {
+		tx.Rollback()
+		return err
+	}
+	entries, err := countEntries(tx, c.ID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, e := range entries {
+		if _, err = tx.Exec("update contest_entries set votes = $1, rank = $2, winner = $3 where id = $4",
+			e.Votes, e.Rank, e.Winner, e.ID); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	*c = closed
+	app.Log(app.Info, "Closed contest %v.", c.Name)
+	return nil
+}
+
+// CloseExpiredContests closes every open contest whose voting has ended.
+func CloseExpiredContests() {
+	var contests []Contest
+	if _, err := DB.Select(&contests, "select * from contests where status = $1 and voting_end <= $2 and "+notDeleted, ContestOpen, time.Now()); err != nil {
+		app.Log(app.Error, "Could not find expired contests.", err)
+		return
+	}
+	for i := range contests {
+		if err := contests[i].Close(); err != nil {
+			app.Log(app.Error, "Could not close contest %v.", contests[i].ID, err)
+		}
+	}
+}
+
+// rankEntries ranks entries sorted by votes, sharing the rank between ties.
+// Entries ranked first with at least one vote win.
+func rankEntries(entries []ContestEntry) {
+	for i := range entries {
+		if i > 0 && entries[i].Votes == entries[i-1].Votes {
+			entries[i].Rank = entries[i-1].Rank
+		} else {
+			entries[i].Rank = int64(i + 1)
+		}
+		entries[i].Winner = entries[i].Rank == 1 && entries[i].Votes > 0
+	}
+}
@@ -2,254 +2,143 @@ package db
 
 import (
 	"database/sql"
-	"fmt"
-	"io/ioutil"
-	"os"
-	"sort"
-	"strconv"
-	"strings"
-	"time"
 
 	"github.com/coopernurse/gorp"
 	// The postgresql driver.
 	_ "github.com/lib/pq"
-	"github.com/rchargel/localiday/app"
 )
 
-// Database a connection to the database.
+// Database a connection to the database. Statements are written with $n
+// parameters and rewritten for the dialect of the database.
 type Database struct {
-	Username string
-	Password string
-	Hostname string
-	Database string
-
 	*gorp.DbMap
+	dialect string
 }
 
-type rollback struct {
-	version uint16
-	script  string
-	name    string
+// Transaction a transaction which rewrites its statements for the dialect of
+// the database.
+type Transaction struct {
+	*gorp.Transaction
+	dialect string
 }
 
-type update struct {
-	version uint16
-	script  string
-	name    string
+// executor runs statements either on the database or within a transaction.
+type executor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Select(holder interface{}, query string, args ...interface{}) ([]interface{}, error)
+	SelectInt(query string, args ...interface{}) (int64, error)
+	Insert(list ...interface{}) error
 }
 
-type rollbackSorter []rollback
-type updateSorter []update
-
 // DB the root object for database call
 var DB *Database
 
-// NewDatabase creates a new connection to the database.
-func NewDatabase(username, password, hostname, database string, rebuild bool) error {
-	start := time.Now()
-	db := &Database{Username: username, Password: password, Hostname: hostname, Database: database}
-	if rebuild {
-		db.recreateDatabase()
-	}
-	dbMap, error := db.init()
-
-	app.Log(app.Info, "Data initialized in %v.", time.Since(start))
-
-	DB = &Database{username, password, hostname, database, dbMap}
-	return error
-}
-
-func (db *Database) recreateDatabase() error {
-	config := app.LoadConfiguration()
-	conn, err := sql.Open("postgres", fmt.Sprintf("postgres://%v:%v@%v/%v?sslmode=disable", db.Username, db.Password, db.Hostname, db.Database))
-	defer conn.Close()
-	if err == nil {
-		rows, err := conn.Query("select version from application")
-		var version uint16
-		version = 0
-		if err == nil {
-			if rows.Next() {
-				if inrerr := rows.Scan(&version); inrerr != nil {
-					app.Log(app.Fatal, "Could not read row count.", inrerr)
-				}
-				rows.Close()
-			}
-		}
-
-		err = recreateTables(version, config.DBVersion, conn)
-	}
-	return err
+// NewDatabase sets the connection pool used for every database call. The
+// pool should already be migrated to the configured version.
+func NewDatabase(conn *sql.DB, config *Config) {
+	DB = &Database{&gorp.DbMap{Db: conn, Dialect: gorpDialect(config.Dialect)}, config.Dialect}
 }
 
-func (db *Database) init() (*gorp.DbMap, error) {
-	conn, err := sql.Open("postgres", fmt.Sprintf("postgres://%v:%v@%v/%v?sslmode=disable", db.Username, db.Password, db.Hostname, db.Database))
-
-	config := app.LoadConfiguration()
-
-	rows, err := conn.Query("select version from application")
-	var version uint16
-	version = 0
-	if err != nil {
-		err = createTables(version, config.DBVersion, conn)
-	} else {
-		if rows.Next() {
-			if inrerr := rows.Scan(&version); inrerr != nil {
-				app.Log(app.Fatal, "Could not read row count.", inrerr)
-			}
-			rows.Close()
-		}
-		if version != config.DBVersion {
-			err = createTables(version, config.DBVersion, conn)
-		}
-	}
-
-	dbmap := &gorp.DbMap{Db: conn, Dialect: gorp.PostgresDialect{}}
+// Exec executes the statement.
+func (d *Database) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return d.DbMap.Exec(rebind(d.dialect, query), args...)
+}
 
-	return dbmap, err
+// Select selects every row into the holder.
+func (d *Database) Select(holder interface{}, query string, args ...interface{}) ([]interface{}, error) {
+	return d.DbMap.Select(holder, rebind(d.dialect, query), args...)
 }
 
-func processUpdates(currVersion, appVersion uint16, updates []update, conn *sql.DB) error {
-	tx, err := conn.Begin()
-	if err != nil {
-		return err
-	}
-	for _, update := range updates {
-		if update.version > currVersion && update.version <= appVersion {
-			err = runSqlFile(conn, update.name, update.script)
-			if err != nil {
-				return err
-			}
-		}
-	}
-	err = tx.Commit()
-	return err
+// SelectOne selects a single row into the holder.
+func (d *Database) SelectOne(holder interface{}, query string, args ...interface{}) error {
+	return d.DbMap.SelectOne(holder, rebind(d.dialect, query), args...)
 }
 
-func processRollbacks(currVersion, appVersion uint16, rollbacks []rollback, conn *sql.DB) error {
-	tx, err := conn.Begin()
-	if err != nil {
-		return err
-	}
-	for _, rollback := range rollbacks {
-		if rollback.version > appVersion && rollback.version <= currVersion {
-			err = runSqlFile(conn, rollback.name, rollback.script)
-			if err != nil {
-				return err
-			}
-		}
-	}
-	err = tx.Commit()
-	return err
+// SelectInt selects a single integer.
+func (d *Database) SelectInt(query string, args ...interface{}) (int64, error) {
+	return d.DbMap.SelectInt(rebind(d.dialect, query), args...)
 }
 
-func runSqlFile(conn *sql.DB, filename, script string) error {
-	app.Log(app.Info, "Running sql script %v.", filename)
-	_, err := conn.Exec(script)
+// SelectNullInt selects a single integer which may be null.
+func (d *Database) SelectNullInt(query string, args ...interface{}) (sql.NullInt64, error) {
+	return d.DbMap.SelectNullInt(rebind(d.dialect, query), args...)
+}
 
-	return err
+// SelectFloat selects a single float.
+func (d *Database) SelectFloat(query string, args ...interface{}) (float64, error) {
+	return d.DbMap.SelectFloat(rebind(d.dialect, query), args...)
 }
 
-func createTables(currVersion, appVersion uint16, conn *sql.DB) error {
-	app.Log(app.Debug, "Creating database tables")
-	return runSqlFiles(currVersion, appVersion, conn)
+// SelectNullFloat selects a single float which may be null.
+func (d *Database) SelectNullFloat(query string, args ...interface{}) (sql.NullFloat64, error) {
+	return d.DbMap.SelectNullFloat(rebind(d.dialect, query), args...)
 }
 
-func recreateTables(currVersion, appVersion uint16, conn *sql.DB) error {
-	app.Log(app.Debug, "Recreating database tables")
-	start := time.Now()
-	rollbackFiles, updateFiles, err := getAllSqlFiles()
-	if err == nil {
-		app.Log(app.Info, "REBUILDING DATABASE")
-		processRollbacks(65535, 0, rollbackFiles, conn)
-		processUpdates(currVersion, appVersion, updateFiles, conn)
-		app.Log(app.Info, "DATABASE REBUILD COMPLETE (%v)", time.Since(start))
-	}
-	return err
+// SelectStr selects a single string.
+func (d *Database) SelectStr(query string, args ...interface{}) (string, error) {
+	return d.DbMap.SelectStr(rebind(d.dialect, query), args...)
 }
 
-func runSqlFiles(currVersion, appVersion uint16, conn *sql.DB) error {
-	start := time.Now()
-	rollbackFiles, updateFiles, err := getAllSqlFiles()
+// SelectNullStr selects a single string which may be null.
+func (d *Database) SelectNullStr(query string, args ...interface{}) (sql.NullString, error) {
+	return d.DbMap.SelectNullStr(rebind(d.dialect, query), args...)
+}
 
-	if err == nil {
-		app.Log(app.Info, "RUNNING DATABASE MIGRATION")
-		processRollbacks(currVersion, appVersion, rollbackFiles, conn)
-		processUpdates(currVersion, appVersion, updateFiles, conn)
-		app.Log(app.Info, "DATABASE MIGRATION COMPLETE (%v)", time.Since(start))
+// Begin starts a transaction.
+func (d *Database) Begin() (*Transaction, error) {
+	tx, err := d.DbMap.Begin()
+	if err != nil {
+		return nil, err
 	}
+	return &Transaction{tx, d.dialect}, nil
+}
 
-	return err
-}
-
-func getAllSqlFiles() ([]rollback, []update, error) {
-	rollbackFiles := make([]rollback, 0, 10)
-	updateFiles := make([]update, 0, 10)
-
-	files, err := ioutil.ReadDir("sql")
-	if err == nil {
-		for _, file := range files {
-			if strings.Contains(file.Name(), "update_") {
-				update, err := createUpdate(file)
-				if err != nil {
-					return rollbackFiles, updateFiles, err
-				}
-				updateFiles = append(updateFiles, update)
-			} else if strings.Contains(file.Name(), "rollback_") {
-				rollback, err := createRollback(file)
-				if err != nil {
-					return rollbackFiles, updateFiles, err
-				}
-				rollbackFiles = append(rollbackFiles, rollback)
-			}
-		}
-		sort.Sort(updateSorter(updateFiles))
-		sort.Sort(rollbackSorter(rollbackFiles))
-	}
-	return rollbackFiles, updateFiles, err
+// Exec executes the statement within the transaction.
+func (t *Transaction) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.Transaction.Exec(rebind(t.dialect, query), args...)
 }
 
-func createRollback(file os.FileInfo) (rollback, error) {
-	var r rollback
-	version, script, err := createContentAndVersion(file)
+// Select selects every row into the holder within the transaction.
+func (t *Transaction) Select(holder interface{}, query string, args ...interface{}) ([]interface{}, error) {
+	return t.Transaction.Select(holder, rebind(t.dialect, query), args...)
+}
 
-	if err == nil {
-		r = rollback{version: version, script: script, name: file.Name()}
-	}
-	return r, err
+// SelectOne selects a single row into the holder within the transaction.
+func (t *Transaction) SelectOne(holder interface{}, query string, args ...interface{}) error {
+	return t.Transaction.SelectOne(holder, rebind(t.dialect, query), args...)
 }
 
-func createUpdate(file os.FileInfo) (update, error) {
-	var u update
-	version, script, err := createContentAndVersion(file)
+// SelectInt selects a single integer within the transaction.
+func (t *Transaction) SelectInt(query string, args ...interface{}) (int64, error) {
+	return t.Transaction.SelectInt(rebind(t.dialect, query), args...)
+}
 
-	if err == nil {
-		u = update{version: version, script: script, name: file.Name()}
-	}
-	return u, err
+// SelectNullInt selects a single integer which may be null within the transaction.
+func (t *Transaction) SelectNullInt(query string, args ...interface{}) (sql.NullInt64, error) {
+	return t.Transaction.SelectNullInt(rebind(t.dialect, query), args...)
 }
 
-func createContentAndVersion(file os.FileInfo) (uint16, string, error) {
-	name := file.Name()
-	si := strings.Index(name, "_") + 1
-	ei := strings.Index(name, ".sql")
-	v := name[si:ei]
-	version, err := strconv.ParseUint(v, 10, 16)
+// SelectFloat selects a single float within the transaction.
+func (t *Transaction) SelectFloat(query string, args ...interface{}) (float64, error) {
+	return t.Transaction.SelectFloat(rebind(t.dialect, query), args...)
+}
 
-	if err != nil {
-		return 0, "", err
-	}
-	data, err := ioutil.ReadFile("sql/" + name)
-	if err != nil {
-		return 0, "", err
-	}
+// SelectNullFloat selects a single float which may be null within the transaction.
+func (t *Transaction) SelectNullFloat(query string, args ...interface{}) (sql.NullFloat64, error) {
+	return t.Transaction.SelectNullFloat(rebind(t.dialect, query), args...)
+}
 
-	return uint16(version), string(data), err
+// SelectStr selects a single string within the transaction.
+func (t *Transaction) SelectStr(query string, args ...interface{}) (string, error) {
+	return t.Transaction.SelectStr(rebind(t.dialect, query), args...)
 }
 
-func (l updateSorter) Len() int           { return len(l) }
-func (l updateSorter) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
-func (l updateSorter) Less(i, j int) bool { return l[i].version < l[j].version }
+// SelectNullStr selects a single string which may be null within the transaction.
+func (t *Transaction) SelectNullStr(query string, args ...interface{}) (sql.NullString, error) {
+	return t.Transaction.SelectNullStr(rebind(t.dialect, query), args...)
+}
 
-func (l rollbackSorter) Len() int           { return len(l) }
-func (l rollbackSorter) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
-func (l rollbackSorter) Less(i, j int) bool { return l[i].version > l[j].version }
+// Prepare prepares the statement within the transaction.
+func (t *Transaction) Prepare(query string) (*sql.Stmt, error) {
+	return t.Transaction.Prepare(rebind(t.dialect, query))
+}
@@ -0,0 +1,59 @@
+//go:build sqlite
+// +build sqlite
+
+package db
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+var testDatabase struct {
+	sync.Once
+	err error
+}
+
+// useTestDatabase points the package at an in-memory SQLite database migrated
+// to the newest version, shared by every test in the package.
+func useTestDatabase(t *testing.T) {
+	testDatabase.Do(func() {
+		testDatabase.err = openTestDatabase()
+	})
+	if testDatabase.err != nil {
+		t.Fatal(testDatabase.err)
+	}
+}
+
+func openTestDatabase() error {
+	config, err := LoadConfig("sqlite::memory:")
+	if err != nil {
+		return err
+	}
+	conn, err := Open(config)
+	if err != nil {
+		return err
+	}
+	migrator, err := NewMigrator(conn, config.Dialect)
+	if err != nil {
+		return err
+	}
+	if err = migrator.MigrateUp(migrator.Latest()); err != nil {
+		return err
+	}
+	NewDatabase(conn, config)
+	return initORM()
+}
+
+var testUsers int
+
+// createTestUser creates a user no other test has used.
+func createTestUser(t *testing.T) *User {
+	testUsers++
+	name := fmt.Sprintf("tester%v", testUsers)
+	user, err := CreateNewUser(name, "secret", "Test User", name, name+"@localiday.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"strings"
+
+	"github.com/coopernurse/gorp"
+)
+
+// The SQL dialects the database may be written in. Each has its own driver.
+// Migrations are written in postgres and translated for the other dialects,
+// files in sql/<dialect> replace the shared ones where no translation will do.
+const (
+	PostgresDialect = "postgres"
+	SQLiteDialect   = "sqlite"
+)
+
+// driverName the name the dialect's driver is registered under.
+func driverName(dialect string) string {
+	if dialect == SQLiteDialect {
+		return "sqlite3"
+	}
+	return "postgres"
+}
+
+// gorpDialect the gorp dialect which generates the dialect's SQL.
+func gorpDialect(dialect string) gorp.Dialect {
+	if dialect == SQLiteDialect {
+		return gorp.SqliteDialect{}
+	}
+	return gorp.PostgresDialect{}
+}
+
+// rebind rewrites the $n parameters of a statement for the dialect. SQLite
+// takes ?n, which binds the same argument as $n does in postgres. Quoted
+// strings and identifiers are copied as they are, so a literal such as
+// 'costs $5' is left alone.
+func rebind(dialect, query string) string {
+	if dialect != SQLiteDialect || !strings.Contains(query, "$") {
+		return query
+	}
+	out := []byte(query)
+	var quote byte
+	for i := 0; i < len(out); i++ {
+		switch c := out[i]; {
+		case quote != 0:
+			// a doubled quote is an escaped quote, which toggles back in.
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '$' && i+1 < len(out) && out[i+1] >= '0' && out[i+1] <= '9':
+			out[i] = '?'
+		}
+	}
+	return string(out)
+}
+
+// sqliteDDL the postgres column definitions which SQLite spells differently.
+var sqliteDDL = strings.NewReplacer(
+	"serial primary key", "integer primary key autoincrement",
+	"default now()", "default current_timestamp",
+)
+
+// translate rewrites a migration script written for postgres in the dialect.
+func translate(dialect, script string) string {
+	if dialect == SQLiteDialect {
+		return sqliteDDL.Replace(script)
+	}
+	return script
+}
@@ -0,0 +1,32 @@
+package db
+
+import "testing"
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		dialect, query, expected string
+	}{
+		{PostgresDialect, "select * from users where id = $1", "select * from users where id = $1"},
+		{SQLiteDialect, "select * from users where id = $1 and active = $2", "select * from users where id = ?1 and active = ?2"},
+		{SQLiteDialect, "select * from tours where name = 'costs $5' and id = $1", "select * from tours where name = 'costs $5' and id = ?1"},
+		{SQLiteDialect, "select * from tours where name = 'it''s $1' and id = $2", "select * from tours where name = 'it''s $1' and id = ?2"},
+		{SQLiteDialect, `select "$1" from tours where id = $1`, `select "$1" from tours where id = ?1`},
+		{SQLiteDialect, "select '$' || name from tours", "select '$' || name from tours"},
+	}
+	for _, test := range tests {
+		if actual := rebind(test.dialect, test.query); actual != test.expected {
+			t.Errorf("rebind(%v, %q) = %q, expected %q", test.dialect, test.query, actual, test.expected)
+		}
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	script := "create table things (\n  id serial primary key,\n  created timestamp default now()\n);"
+	expected := "create table things (\n  id integer primary key autoincrement,\n  created timestamp default current_timestamp\n);"
+	if actual := translate(SQLiteDialect, script); actual != expected {
+		t.Errorf("translate(sqlite) = %q, expected %q", actual, expected)
+	}
+	if actual := translate(PostgresDialect, script); actual != script {
+		t.Errorf("translate(postgres) = %q, expected it unchanged", actual)
+	}
+}
@@ -0,0 +1,177 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/rchargel/localiday/app"
+	"github.com/rchargel/localiday/geo"
+	"github.com/rchargel/localiday/schedule"
+)
+
+// duplicateDegrees how close, in degrees, a display with the same title must
+// be to count as the same display, about ten meters.
+const duplicateDegrees = 0.0001
+
+// notHidden the condition which excludes displays hidden or deleted by
+// moderation, added with notDeleted to every query of displays shown to users.
+const notHidden = "id not in (select item_id from moderated_items where item_type = '" + ItemDisplay + "' and (hidden or deleted))"
+
+// Display a holiday light display. Tags and holidays are comma separated, and
+// the lighting schedule, if any, is stored as JSON.
+type Display struct {
+	ID          int64
+	UserID      int64 `db:"user_id"`
+	Title       string
+	Description string
+	Address     string
+	Tags        string
+	Holidays    string
+	Latitude    float64
+	Longitude   float64
+	Schedule    string
+
+	Audited
+}
+
+// CreateDisplay creates a new display.
+func CreateDisplay(display *Display) error {
+	if err := display.Point().Validate(); err != nil {
+		return err
+	}
+	if err := insert(display); err != nil {
+		return err
+	}
+	app.Log(app.Debug, "Created display %v, %v.", display.ID, display.Title)
+	return nil
+}
+
+// Get gets a display by its ID, unless it is hidden.
+func (d Display) Get(id int64) (*Display, error) {
+	var found Display
+	if err := DB.SelectOne(&found, "select * from displays where id = $1 and "+notDeleted+" and "+notHidden, id); err != nil {
+		app.Log(app.Debug, "Could not find display %v: %v", id, err)
+		return nil, fmt.Errorf("Could not find display %v.", id)
+	}
+	return &found, nil
+}
+
+// FindAll finds every display which has not been deleted or hidden.
+func (d Display) FindAll() ([]Display, error) {
+	var displays []Display
+	_, err := DB.Select(&displays, "select * from displays where "+notDeleted+" and "+notHidden+" order by id")
+	return displays, err
+}
+
+// FindByIDs finds the displays with the IDs, in no particular order.
+func (d Display) FindByIDs(ids []int64) ([]Display, error) {
+	displays := make([]Display, 0, len(ids))
+	if len(ids) == 0 {
+		return displays, nil
+	}
+	q := newQuery("select * from displays where " + notDeleted + " and " + notHidden)
+	q.add(" and id in (" + q.bindIDs(ids) + ")")
+	err := q.selectAll(&displays)
+	return displays, err
+}
+
+// FindInBox finds the displays inside the box, which may cross the
+// antimeridian.
+func (d Display) FindInBox(box geo.BoundingBox) ([]Display, error) {
+	var displays []Display
+	q := newQuery("select * from displays where "+notDeleted+" and "+notHidden+" and latitude between $1 and $2", box.South, box.North)
+	if box.West <= box.East {
+		q.add(" and longitude between " + q.bind(box.West) + " and " + q.bind(box.East))
+	} else {
+		q.add(" and (longitude >= " + q.bind(box.West) + " or longitude <= " + q.bind(box.East) + ")")
+	}
+	err := q.add(" order by id").selectAll(&displays)
+	return displays, err
+}
+
+// Exists checks to see if there is already a display with the title at about
+// the same place.
+func (d Display) Exists(title string, p geo.Point) bool {
+	return count("select count(*) from displays where lower(title) = $1 and latitude between $2 and $3 and longitude between $4 and $5 and "+notDeleted,
+		strings.ToLower(title), p.Latitude-duplicateDegrees, p.Latitude+duplicateDegrees,
+		p.Longitude-duplicateDegrees, p.Longitude+duplicateDegrees) > 0
+}
+
+// UpdateDisplay saves the changes to a display, failing with a conflict if it
+// has been changed since it was read.
+func UpdateDisplay(display *Display) error {
+	_, err := DB.Update(display)
+	return err
+}
+
+// DeleteDisplay soft deletes a display.
+func DeleteDisplay(id int64) error {
+	return softDelete("displays", id)
+}
+
+// Point gets where the display is.
+func (d *Display) Point() geo.Point {
+	return geo.Point{Latitude: d.Latitude, Longitude: d.Longitude}
+}
+
+// Marker gets the display as a map marker.
+func (d *Display) Marker() geo.Marker {
+	return geo.Marker{ID: d.ID, Point: d.Point()}
+}
+
+// HolidayKeys gets the keys of the holidays the display is put up for.
+func (d *Display) HolidayKeys() []string {
+	return splitList(d.Holidays)
+}
+
+// TagList gets the display's tags.
+func (d *Display) TagList() []string {
+	return splitList(d.Tags)
+}
+
+// LightingSchedule gets the display's lighting schedule, or nil if it has
+// none or it cannot be read.
+func (d *Display) LightingSchedule() *schedule.Schedule {
+	if len(d.Schedule) == 0 {
+		return nil
+	}
+	var s schedule.Schedule
+	if err := json.Unmarshal([]byte(d.Schedule), &s); err != nil {
+		app.Log(app.Error, "Could not read the schedule of display %v: %v", d.ID, err)
+		return nil
+	}
+	if s.Position.Latitude == 0 && s.Position.Longitude == 0 {
+		s.Position = d.Point()
+	}
+	return &s
+}
+
+// SetLightingSchedule sets the display's lighting schedule, which must be
+// valid, or removes it if nil.
+func (d *Display) SetLightingSchedule(s *schedule.Schedule) error {
+	if s == nil {
+		d.Schedule = ""
+		return nil
+	}
+	s.Position = d.Point()
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	d.Schedule = string(data)
+	return nil
+}
+
+func splitList(list string) []string {
+	items := make([]string, 0, 4)
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/rchargel/localiday/geo"
+)
+
+// Weights of the display fields in a text search, as Postgres weighs them
+// from A down to D.
+const (
+	titleWeight       = 1.0
+	tagsWeight        = 0.4
+	addressWeight     = 0.2
+	descriptionWeight = 0.1
+)
+
+// TextMatch a display matching a text search and how well it matches, the
+// higher the rank the better.
+type TextMatch struct {
+	Display
+	Rank float64
+}
+
+// SearchText finds up to max displays matching every word of the text, best
+// match first. The title counts most, then the tags, the address and lastly
+// the description. The holiday, if given, and the box, if not nil, limit the
+// displays searched.
+func (d Display) SearchText(text, holiday string, box *geo.BoundingBox, max int) ([]TextMatch, error) {
+	if DB.dialect == SQLiteDialect {
+		return searchTextLike(text, holiday, box, max)
+	}
+	var matches []TextMatch
+	q := newQuery(`select d.*, ts_rank(s.document, q) as rank
+		from displays d join display_search s on s.display_id = d.id, plainto_tsquery('english', $1) q
+		where s.document @@ q and d.`+notDeleted+` and d.`+notHidden, text)
+	addDisplayFilters(q, holiday, box)
+	err := q.add(" order by rank desc, d.id limit " + q.bind(max)).selectAll(&matches)
+	return matches, err
+}
+
+// searchTextLike matches each word anywhere in the text fields, for
+// databases without full-text search, ranking the matches by the weight of
+// the fields the words are found in.
+func searchTextLike(text, holiday string, box *geo.BoundingBox, max int) ([]TextMatch, error) {
+	terms := strings.Fields(strings.ToLower(text))
+	if len(terms) == 0 {
+		return []TextMatch{}, nil
+	}
+	var displays []Display
+	q := newQuery("select d.* from displays d where d." + notDeleted + " and d." + notHidden)
+	for _, term := range terms {
+		p := q.bind("%" + escapeLike(term) + "%")
+		q.add(" and (lower(d.title) like " + p + " escape '\\' or lower(d.tags) like " + p + " escape '\\'" +
+			" or lower(d.address) like " + p + " escape '\\' or lower(d.description) like " + p + " escape '\\')")
+	}
+	addDisplayFilters(q, holiday, box)
+	if err := q.selectAll(&displays); err != nil {
+		return nil, err
+	}
+	matches := make([]TextMatch, len(displays))
+	for i := range displays {
+		matches[i] = TextMatch{displays[i], rankText(&displays[i], terms)}
+	}
+	sort.Sort(textMatchSorter(matches))
+	if len(matches) > max {
+		matches = matches[:max]
+	}
+	return matches, nil
+}
+
+// rankText adds up the weights of the fields each term is found in.
+func rankText(d *Display, terms []string) float64 {
+	fields := []struct {
+		text   string
+		weight float64
+	}{{d.Title, titleWeight}, {d.Tags, tagsWeight}, {d.Address, addressWeight}, {d.Description, descriptionWeight}}
+	var rank float64
+	for _, term := range terms {
+		for _, f := range fields {
+			if strings.Contains(strings.ToLower(f.text), term) {
+				rank += f.weight
+			}
+		}
+	}
+	return rank
+}
+
+// CompleteTitle finds up to max titles of displays starting with the prefix,
+// ignoring case, in alphabetical order.
+func (d Display) CompleteTitle(prefix string, max int) ([]string, error) {
+	var titles []string
+	title := "lower(title)"
+	if DB.dialect == SQLiteDialect {
+		// like ignores case in sqlite, which lets it use the title index.
+		title = "title"
+	}
+	_, err := DB.Select(&titles, "select distinct title from displays where "+title+" like $1 escape '\\' and "+notDeleted+
+		" and "+notHidden+" order by title limit $2", escapeLike(strings.ToLower(prefix))+"%", max)
+	return titles, err
+}
+
+// addDisplayFilters limits a query of displays, aliased d, to those put up
+// for the holiday and inside the box.
+func addDisplayFilters(q *query, holiday string, box *geo.BoundingBox) {
+	if len(holiday) > 0 {
+		q.add(" and (',' || d.holidays || ',') like " + q.bind("%,"+escapeLike(holiday)+",%") + " escape '\\'")
+	}
+	if box != nil {
+		q.add(" and d.latitude between " + q.bind(box.South) + " and " + q.bind(box.North))
+		if box.West <= box.East {
+			q.add(" and d.longitude between " + q.bind(box.West) + " and " + q.bind(box.East))
+		} else {
+			q.add(" and (d.longitude >= " + q.bind(box.West) + " or d.longitude <= " + q.bind(box.East) + ")")
+		}
+	}
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike escapes the wildcards of like in the text, to match it as is.
+func escapeLike(text string) string {
+	return likeEscaper.Replace(text)
+}
+
+type textMatchSorter []TextMatch
+
+func (l textMatchSorter) Len() int      { return len(l) }
+func (l textMatchSorter) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l textMatchSorter) Less(i, j int) bool {
+	if l[i].Rank != l[j].Rank {
+		return l[i].Rank > l[j].Rank
+	}
+	return l[i].ID < l[j].ID
+}
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"errors"
+	"time"
+)
+
+// Favorite a display on a user's favorites list.
+type Favorite struct {
+	ID        int64
+	UserID    int64 `db:"user_id"`
+	DisplayID int64 `db:"display_id"`
+	Created   time.Time
+}
+
+// AddFavorite adds the display to the user's favorites.
+func AddFavorite(userID, displayID int64) error {
+	if _, err := (Display{}).Get(displayID); err != nil {
+		return err
+	}
+	if IsFavorite(userID, displayID) {
+		return errors.New("This display is already one of your favorites.")
+	}
+	return insert(&Favorite{UserID: userID, DisplayID: displayID, Created: time.Now()})
+}
+
+// RemoveFavorite removes the display from the user's favorites.
+func RemoveFavorite(userID, displayID int64) error {
+	_, err := DB.Exec("delete from favorites where user_id = $1 and display_id = $2", userID, displayID)
+	return err
+}
+
+// IsFavorite checks to see if the display is one of the user's favorites.
+func IsFavorite(userID, displayID int64) bool {
+	return count("select count(*) from favorites where user_id = $1 and display_id = $2", userID, displayID) > 0
+}
+
+// FindFavoriteDisplays finds the displays on the user's favorites list, in
+// the order they were added. Deleted and hidden displays are left out.
+func FindFavoriteDisplays(userID int64) ([]Display, error) {
+	var displays []Display
+	_, err := DB.Select(&displays, `select d.* from displays d join favorites f on f.display_id = d.id
+		where f.user_id = $1 and d.`+notDeleted+` and d.`+notHidden+` order by f.created, f.id`, userID)
+	return displays, err
+}
@@ -0,0 +1,159 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rchargel/localiday/app"
+	"github.com/rchargel/localiday/geo"
+)
+
+// ActivityStatus the activity type of a status report. Displays, photos and
+// reviews use their moderation item types so hidden items drop out of feeds.
+const ActivityStatus = "STATUS"
+
+var activityTypes = []string{ItemDisplay, ItemPhoto, ItemReview, ActivityStatus}
+
+// Follow a user following another user.
+type Follow struct {
+	ID         int64
+	FollowerID int64 `db:"follower_id"`
+	FollowedID int64 `db:"followed_id"`
+	Created    time.Time
+}
+
+// FollowedArea an area a user follows, a center and a radius in kilometers.
+type FollowedArea struct {
+	ID        int64
+	UserID    int64 `db:"user_id"`
+	Name      string
+	Latitude  float64
+	Longitude float64
+	RadiusKm  float64 `db:"radius_km"`
+	Created   time.Time
+}
+
+// Activity something a user did which shows in the feeds of their followers,
+// and of users following the area it happened in if it is located.
+type Activity struct {
+	ID           int64
+	UserID       int64  `db:"user_id"`
+	ActivityType string `db:"activity_type"`
+	ItemID       int64  `db:"item_id"`
+	Located      bool
+	Latitude     float64
+	Longitude    float64
+	Summary      string
+	Created      time.Time
+}
+
+// FollowUser makes the follower follow the other user.
+func FollowUser(followerID, followedID int64) error {
+	if followerID == followedID {
+		return errors.New("You cannot follow yourself.")
+	}
+	if count("select count(*) from follows where follower_id = $1 and followed_id = $2", followerID, followedID) > 0 {
+		return errors.New("You already follow this user.")
+	}
+	return insert(&Follow{FollowerID: followerID, FollowedID: followedID, Created: time.Now()})
+}
+
+// UnfollowUser stops the follower following the other user.
+func UnfollowUser(followerID, followedID int64) error {
+	_, err := DB.Exec("delete from follows where follower_id = $1 and followed_id = $2", followerID, followedID)
+	return err
+}
+
+// FindFollowedUserIDs finds the IDs of the users the user follows.
+func FindFollowedUserIDs(userID int64) ([]int64, error) {
+	var follows []Follow
+	_, err := DB.Select(&follows, "select * from follows where follower_id = $1", userID)
+	ids := make([]int64, len(follows))
+	for i, f := range follows {
+		ids[i] = f.FollowedID
+	}
+	return ids, err
+}
+
+// FollowArea saves an area for the user to follow.
+func FollowArea(area *FollowedArea) error {
+	if err := (geo.Point{Latitude: area.Latitude, Longitude: area.Longitude}).Validate(); err != nil {
+		return err
+	}
+	if area.RadiusKm <= 0 {
+		return errors.New("An area must have a radius.")
+	}
+	area.Created = time.Now()
+	return insert(area)
+}
+
+// UnfollowArea removes one of the user's followed areas.
+func UnfollowArea(userID, areaID int64) error {
+	_, err := DB.Exec("delete from followed_areas where id = $1 and user_id = $2", areaID, userID)
+	return err
+}
+
+// FindFollowedAreas finds the areas the user follows.
+func FindFollowedAreas(userID int64) ([]FollowedArea, error) {
+	var areas []FollowedArea
+	_, err := DB.Select(&areas, "select * from followed_areas where user_id = $1 order by name", userID)
+	return areas, err
+}
+
+// Point gets the center of the area.
+func (a FollowedArea) Point() geo.Point {
+	return geo.Point{Latitude: a.Latitude, Longitude: a.Longitude}
+}
+
+// RecordActivity records an activity for followers' feeds.
+func RecordActivity(activity *Activity) error {
+	if !app.Contains(activityTypes, activity.ActivityType) {
+		return fmt.Errorf("%v is not a valid activity type.", activity.ActivityType)
+	}
+	activity.Created = time.Now()
+	return insert(activity)
+}
+
+// FindFeedActivities finds activities by the users, or located inside the
+// boxes, with IDs below the given ID, newest first. An ID of zero starts from
+// the newest. Activities on hidden or deleted items, and deleted status
+// reports, are left out.
+func FindFeedActivities(userIDs []int64, boxes []geo.BoundingBox, before int64, max int) ([]Activity, error) {
+	var activities []Activity
+	q := newQuery("")
+	or := make([]string, 0, len(boxes)+1)
+	if len(userIDs) > 0 {
+		or = append(or, "a.user_id in ("+q.bindIDs(userIDs)+")")
+	}
+	for _, box := range boxes {
+		lon := "a.longitude between %v and %v"
+		if box.West > box.East {
+			lon = "(a.longitude >= %v or a.longitude <= %v)"
+		}
+		lon = fmt.Sprintf(lon, q.bind(box.West), q.bind(box.East))
+		or = append(or, fmt.Sprintf("(a.located and a.latitude between %v and %v and %v)", q.bind(box.South), q.bind(box.North), lon))
+	}
+	if len(or) == 0 {
+		return activities, nil
+	}
+
+	q.add("select a.* from activities a where (" + strings.Join(or, " or ") + ")")
+	if before > 0 {
+		q.add(" and a.id < " + q.bind(before))
+	}
+	q.add(` and not exists (select 1 from moderated_items m where m.item_type = a.activity_type
+		and m.item_id = a.item_id and (m.hidden or m.deleted))`)
+	q.add(" and not exists (select 1 from status_reports s where a.activity_type = " + q.bind(ActivityStatus) +
+		" and s.id = a.item_id and s.deleted_at is not null)")
+	q.add(" order by a.id desc limit " + q.bind(max))
+
+	err := q.selectAll(&activities)
+	return activities, err
+}
+
+// Point gets the location of the activity.
+func (a Activity) Point() geo.Point {
+	return geo.Point{Latitude: a.Latitude, Longitude: a.Longitude}
+}
@@ -0,0 +1,74 @@
+//go:build sqlite
+// +build sqlite
+
+package db
+
+import "testing"
+
+// injections input which would change the meaning of a statement it was
+// written into rather than bound to.
+var injections = []string{
+	"' or '1'='1",
+	"x'; drop table users;--",
+	"' or 1=1 --",
+	"\" or \"\"=\"",
+	"'; delete from sessions; --",
+	"$1",
+}
+
+func TestInjectionFindsNoUsers(t *testing.T) {
+	useTestDatabase(t)
+	user := createTestUser(t)
+	session := CreateNewSession(user.ID)
+	users, sessions := User{}.Count(), countSessions(t)
+
+	for _, input := range injections {
+		if found, err := (User{}).FindByUsername(input); err == nil {
+			t.Errorf("FindByUsername(%q) found user %v", input, found.Username)
+		}
+		if found, err := (User{}).FindByUsernameAndPassword(input, input); err == nil {
+			t.Errorf("FindByUsernameAndPassword(%q) found user %v", input, found.Username)
+		}
+		if found, err := GetUserBySession(input); err == nil {
+			t.Errorf("GetUserBySession(%q) found user %v", input, found.Username)
+		}
+		if err := DeleteSession(input); err != nil {
+			t.Errorf("DeleteSession(%q) failed: %v", input, err)
+		}
+	}
+
+	if n := (User{}).Count(); n != users {
+		t.Errorf("There are %v users, expected %v", n, users)
+	}
+	if n := countSessions(t); n != sessions {
+		t.Errorf("There are %v sessions, expected %v", n, sessions)
+	}
+	if found, err := GetUserBySession(session.SessionID); err != nil || found.ID != user.ID {
+		t.Errorf("The session of user %v should still be valid: %v", user.ID, err)
+	}
+}
+
+func TestInjectionStoredAsIs(t *testing.T) {
+	useTestDatabase(t)
+	for _, input := range injections[:2] {
+		user, err := CreateNewUser(input, "secret", input, input, "")
+		if err != nil {
+			t.Fatalf("Could not create user %q: %v", input, err)
+		}
+		found, err := User{}.FindByUsername(input)
+		if err != nil || found.ID != user.ID || found.FullName != input {
+			t.Errorf("FindByUsername(%q) should find only the user with that name: %v", input, err)
+		}
+	}
+	if n := count("select count(*) from users where username like $1", "%drop table%"); n != 1 {
+		t.Errorf("Found %v users named for dropping the table, expected 1", n)
+	}
+}
+
+func countSessions(t *testing.T) int64 {
+	n, err := DB.SelectInt("select count(*) from sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
@@ -0,0 +1,14 @@
+package db
+
+import (
+	"os"
+	"testing"
+
+	"github.com/rchargel/localiday/app"
+)
+
+func TestMain(m *testing.M) {
+	// the configuration and migrations are read from the project directory.
+	app.SetFiles(os.DirFS(".."), "")
+	os.Exit(m.Run())
+}
@@ -0,0 +1,325 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coopernurse/gorp"
+	"github.com/rchargel/localiday/app"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// memoryStore holds users, roles and sessions in memory. It is safe for
+// concurrent use.
+type memoryStore struct {
+	lock      sync.RWMutex
+	nextID    int64
+	users     map[int64]User
+	roles     map[int64]Role
+	userRoles map[int64][]int64
+	sessions  map[string]Session
+}
+
+// NewMemoryRepositories creates repositories which keep everything in memory
+// and behave as the database repositories do. They are lost when the
+// application stops.
+func NewMemoryRepositories() *Repositories {
+	store := &memoryStore{
+		users:     make(map[int64]User, 10),
+		roles:     make(map[int64]Role, 10),
+		userRoles: make(map[int64][]int64, 10),
+		sessions:  make(map[string]Session, 10),
+	}
+	return &Repositories{memoryUsers{store}, memoryRoles{store}, memorySessions{store}}
+}
+
+func (m *memoryStore) newID() int64 {
+	m.nextID++
+	return m.nextID
+}
+
+type memoryUsers struct{ *memoryStore }
+
+func (m memoryUsers) Get(userID int64) (*User, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	if u, found := m.users[userID]; found && !u.IsDeleted() {
+		return &u, nil
+	}
+	return nil, fmt.Errorf("Could not find user %v.", userID)
+}
+
+func (m memoryUsers) FindByUsername(username string) (*User, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	if u, found := m.findByUsername(username); found {
+		return &u, nil
+	}
+	return nil, fmt.Errorf("Could not find a user with the supplied username: %v.", username)
+}
+
+func (m memoryUsers) FindByUsernameAndPassword(username, password string) (*User, error) {
+	found, err := m.FindByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(found.Password), []byte(password)) != nil {
+		return nil, errors.New("Username and password do not match.")
+	}
+	if !found.Active {
+		return nil, errors.New("This account has been disabled.")
+	}
+	return found, nil
+}
+
+func (m memoryUsers) Create(username, password, fullname, nickname, email string) (*User, error) {
+	now := time.Now()
+	user := User{
+		Username: username,
+		FullName: fullname,
+		NickName: nickname,
+		Email:    email,
+		Active:   true,
+		Audited:  Audited{Created: now, Updated: now, Version: 1},
+	}
+	if err := user.encryptPassword(password); err != nil {
+		return nil, err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	// as in the database, a deleted user keeps their username so they can be
+	// restored.
+	for _, u := range m.users {
+		if u.Username == username {
+			return nil, fmt.Errorf("The username %v is taken.", username)
+		}
+	}
+	user.ID = m.newID()
+	m.users[user.ID] = user
+	return &user, nil
+}
+
+func (m memoryUsers) Deactivate(userID int64) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	u, found := m.users[userID]
+	if !found || u.IsDeleted() {
+		return fmt.Errorf("Could not find user %v.", userID)
+	}
+	u.Active = false
+	return m.update(&u)
+}
+
+func (m memoryUsers) Update(user *User) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.update(user)
+}
+
+// update saves the user as UpdateUser does, ending their session if they are
+// not active. The caller holds the lock.
+func (m *memoryStore) update(user *User) error {
+	existing, found := m.users[user.ID]
+	if !found {
+		return gorp.OptimisticLockError{TableName: "users", Keys: []interface{}{user.ID}, RowExists: false, LocalVersion: user.Version}
+	}
+	if existing.Version != user.Version {
+		return gorp.OptimisticLockError{TableName: "users", Keys: []interface{}{user.ID}, RowExists: true, LocalVersion: user.Version}
+	}
+	user.touch()
+	m.users[user.ID] = *user
+	if !user.Active {
+		m.deleteSessions(user.ID)
+	}
+	return nil
+}
+
+func (m memoryUsers) Delete(userID int64) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	u, found := m.users[userID]
+	if !found || u.IsDeleted() {
+		return fmt.Errorf("Could not find user %v to delete.", userID)
+	}
+	u.touch()
+	u.DeletedAt = &u.Updated
+	m.users[userID] = u
+	m.deleteSessions(userID)
+	return nil
+}
+
+func (m memoryUsers) Restore(userID int64) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	u, found := m.users[userID]
+	if !found || !u.IsDeleted() {
+		return fmt.Errorf("Could not find deleted user %v to restore.", userID)
+	}
+	u.touch()
+	u.DeletedAt = nil
+	m.users[userID] = u
+	return nil
+}
+
+func (m memoryUsers) Count() uint32 {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	var count uint32
+	for _, u := range m.users {
+		if !u.IsDeleted() {
+			count++
+		}
+	}
+	return count
+}
+
+func (m memoryUsers) CountActive() uint32 {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	var active uint32
+	for _, u := range m.users {
+		if u.Active && !u.IsDeleted() {
+			active++
+		}
+	}
+	return active
+}
+
+func (m *memoryStore) findByUsername(username string) (User, bool) {
+	for _, u := range m.users {
+		if u.Username == username && !u.IsDeleted() {
+			return u, true
+		}
+	}
+	return User{}, false
+}
+
+func (m *memoryStore) deleteSessions(userID int64) {
+	for id, s := range m.sessions {
+		if s.UserID == userID {
+			delete(m.sessions, id)
+		}
+	}
+}
+
+type memoryRoles struct{ *memoryStore }
+
+func (m memoryRoles) FindByAuthority(authority string) (*Role, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	for _, r := range m.roles {
+		if r.Authority == authority {
+			return &r, nil
+		}
+	}
+	return nil, fmt.Errorf("Could not find role by authority: %v.", authority)
+}
+
+func (m memoryRoles) Create(authority string) (*Role, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, r := range m.roles {
+		if r.Authority == authority {
+			return nil, fmt.Errorf("Could not create role %v.", authority)
+		}
+	}
+	now := time.Now()
+	role := Role{ID: m.newID(), Authority: authority, Audited: Audited{Created: now, Updated: now, Version: 1}}
+	m.roles[role.ID] = role
+	return &role, nil
+}
+
+func (m memoryRoles) AddToUser(user *User, role *Role) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, found := m.users[user.ID]; !found {
+		return fmt.Errorf("Could not find user %v.", user.ID)
+	}
+	if _, found := m.roles[role.ID]; !found {
+		return fmt.Errorf("Could not find role %v.", role.Authority)
+	}
+	for _, id := range m.userRoles[user.ID] {
+		if id == role.ID {
+			return fmt.Errorf("User %v already has role %v.", user.Username, role.Authority)
+		}
+	}
+	m.userRoles[user.ID] = append(m.userRoles[user.ID], role.ID)
+	return nil
+}
+
+func (m memoryRoles) GetAuthorities(userID int64) []string {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	authorities := make([]string, 0, len(m.userRoles[userID]))
+	for _, id := range m.userRoles[userID] {
+		authorities = append(authorities, m.roles[id].Authority)
+	}
+	return authorities
+}
+
+type memorySessions struct{ *memoryStore }
+
+func (m memorySessions) Create(userID int64) *Session {
+	return m.CreateOAuth(userID, "", "")
+}
+
+func (m memorySessions) CreateOAuth(userID int64, oauthToken, oauthProvider string) *Session {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	now := time.Now()
+	for id, s := range m.sessions {
+		if s.UserID == userID {
+			s.LastAccessed = now
+			m.sessions[id] = s
+			return &s
+		}
+	}
+	s := Session{
+		ID:             m.newID(),
+		UserID:         userID,
+		SessionID:      createSessionString(),
+		OAuthToken:     oauthToken,
+		OAuthProvider:  strings.ToUpper(oauthProvider),
+		SessionCreated: now,
+		LastAccessed:   now,
+	}
+	m.sessions[s.SessionID] = s
+	return &s
+}
+
+func (m memorySessions) Get(sessionID string) (*Session, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	s, found := m.sessions[sessionID]
+	if !found || s.LastAccessed.Before(sessionExpiry()) {
+		return nil, fmt.Errorf("Could not find session %v.", sessionID)
+	}
+	s.LastAccessed = time.Now()
+	m.sessions[sessionID] = s
+	return &s, nil
+}
+
+func (m memorySessions) Delete(sessionID string) error {
+	m.lock.Lock()
+	delete(m.sessions, sessionID)
+	m.lock.Unlock()
+	return nil
+}
+
+func (m memorySessions) Clean() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	expiry := sessionExpiry()
+	purged := 0
+	for id, s := range m.sessions {
+		if s.LastAccessed.Before(expiry) {
+			delete(m.sessions, id)
+			purged++
+		}
+	}
+	app.Log(app.Debug, "Purged %v expired in-memory sessions.", purged)
+	return nil
+}
@@ -0,0 +1,461 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rchargel/localiday/app"
+)
+
+// migrationLockID the key of the advisory lock held while migrating, so two
+// instances never migrate the same database at once.
+const migrationLockID = 7510414
+
+const createMigrationsTable = `create table if not exists schema_migrations (
+  version integer primary key,
+  name varchar(200) not null,
+  checksum varchar(64) not null,
+  duration_ms bigint not null,
+  applied timestamp not null
+)`
+
+// Migration a schema version, the update which migrates to it and the
+// rollback which undoes it.
+type Migration struct {
+	Version      uint16
+	Name         string
+	Script       string
+	Checksum     string
+	RollbackName string
+	Rollback     string
+}
+
+// AppliedMigration the record of a migration which has been applied.
+type AppliedMigration struct {
+	Version    uint16
+	Name       string
+	Checksum   string
+	DurationMs int64 `db:"duration_ms"`
+	Applied    time.Time
+}
+
+// MigrationStatus a migration and whether it has been applied. Edited is set
+// when the file has changed since it was applied.
+type MigrationStatus struct {
+	Migration
+	Applied *AppliedMigration
+	Edited  bool
+}
+
+// MigrationStep a migration to run, up to apply its update or down to apply
+// its rollback.
+type MigrationStep struct {
+	Migration
+	Up bool
+}
+
+// String prints out the step as it is shown in a plan.
+func (s MigrationStep) String() string {
+	if s.Up {
+		return fmt.Sprintf("up   %3v  %v", s.Version, s.Name)
+	}
+	return fmt.Sprintf("down %3v  %v", s.Version, s.RollbackName)
+}
+
+// Migrator migrates the database between schema versions using the update
+// and rollback files in the sql directory. Every file runs in its own
+// transaction along with the change to the schema_migrations history.
+type Migrator struct {
+	conn       *sql.DB
+	dialect    string
+	migrations []Migration
+}
+
+// NewMigrator creates a migrator for a connection to a database of the
+// dialect, reading the migration files.
+func NewMigrator(conn *sql.DB, dialect string) (*Migrator, error) {
+	migrations, err := readMigrations(app.Files, dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{conn, dialect, migrations}, nil
+}
+
+// Close closes the migrator's connection.
+func (m *Migrator) Close() error {
+	return m.conn.Close()
+}
+
+// Latest gets the version of the newest migration.
+func (m *Migrator) Latest() uint16 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Migrations gets every migration, in version order.
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Status gets every migration and whether it has been applied.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	ctx := context.Background()
+	conn, err := m.conn.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	unlock, err := m.lock(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if err = m.prepareHistory(ctx, conn); err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	return m.status(applied), nil
+}
+
+// Version gets the latest applied schema version.
+func (m *Migrator) Version() (uint16, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return 0, err
+	}
+	return currentVersion(statuses), nil
+}
+
+// Plan gets the steps which would migrate the database to the version.
+func (m *Migrator) Plan(version uint16) ([]MigrationStep, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+	return m.plan(statuses, version)
+}
+
+// MigrateTo migrates the database up or down to the version. It stops at the
+// first file which fails, leaving the database at the last version which
+// succeeded. Nothing is run if an applied file has been edited.
+func (m *Migrator) MigrateTo(version uint16) error {
+	return m.migrate(version, false)
+}
+
+// MigrateUp migrates the database up to the version. Nothing is rolled back,
+// if the database is already past the version an error is returned instead.
+func (m *Migrator) MigrateUp(version uint16) error {
+	return m.migrate(version, true)
+}
+
+func (m *Migrator) migrate(version uint16, upOnly bool) error {
+	ctx := context.Background()
+	conn, err := m.conn.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	unlock, err := m.lock(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err = m.prepareHistory(ctx, conn); err != nil {
+		return err
+	}
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return err
+	}
+	statuses := m.status(applied)
+	if current := currentVersion(statuses); upOnly && current > version {
+		return fmt.Errorf("The database is at version %v, which is newer than version %v.", current, version)
+	}
+	steps, err := m.plan(statuses, version)
+	if err != nil {
+		return err
+	}
+	if len(steps) == 0 {
+		app.Log(app.Debug, "Database is at version %v, nothing to migrate.", version)
+		return nil
+	}
+
+	start := time.Now()
+	app.Log(app.Info, "RUNNING DATABASE MIGRATION")
+	for _, step := range steps {
+		if err = m.runStep(ctx, conn, step); err != nil {
+			return err
+		}
+	}
+	app.Log(app.Info, "DATABASE MIGRATION COMPLETE (%v)", time.Since(start))
+	return nil
+}
+
+// lock holds the migration lock on the connection until the returned
+// function is called. SQLite locks the file for each transaction, and has no
+// advisory locks.
+func (m *Migrator) lock(ctx context.Context, conn *sql.Conn) (func(), error) {
+	if m.dialect != PostgresDialect {
+		return func() {}, nil
+	}
+	if _, err := conn.ExecContext(ctx, "select pg_advisory_lock($1)", migrationLockID); err != nil {
+		return nil, fmt.Errorf("Could not lock the database for migration: %v.", err)
+	}
+	return func() { conn.ExecContext(ctx, "select pg_advisory_unlock($1)", migrationLockID) }, nil
+}
+
+func (m *Migrator) status(applied map[uint16]AppliedMigration) []MigrationStatus {
+	statuses := make([]MigrationStatus, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i] = MigrationStatus{Migration: migration}
+		if a, found := applied[migration.Version]; found {
+			statuses[i].Applied = &a
+			statuses[i].Edited = a.Checksum != migration.Checksum
+		}
+	}
+	return statuses
+}
+
+func (m *Migrator) plan(statuses []MigrationStatus, version uint16) ([]MigrationStep, error) {
+	if version > m.Latest() {
+		return nil, fmt.Errorf("There is no migration for version %v.", version)
+	}
+	edited := make([]string, 0, 1)
+	for _, s := range statuses {
+		if s.Edited {
+			edited = append(edited, fmt.Sprintf("%v (applied %v, checksum %.12v now %.12v)",
+				s.Name, s.Applied.Applied.Format(time.RFC3339), s.Applied.Checksum, s.Checksum))
+		}
+	}
+	if len(edited) > 0 {
+		return nil, fmt.Errorf("Applied migrations have been edited: %v.", strings.Join(edited, ", "))
+	}
+
+	steps := make([]MigrationStep, 0, len(statuses))
+	for _, s := range statuses {
+		if s.Applied == nil && s.Version <= version {
+			steps = append(steps, MigrationStep{s.Migration, true})
+		}
+	}
+	for i := len(statuses) - 1; i >= 0; i-- {
+		if s := statuses[i]; s.Applied != nil && s.Version > version {
+			if len(s.Rollback) == 0 {
+				return nil, fmt.Errorf("Migration %v has no rollback.", s.Name)
+			}
+			steps = append(steps, MigrationStep{s.Migration, false})
+		}
+	}
+	return steps, nil
+}
+
+func currentVersion(statuses []MigrationStatus) uint16 {
+	var version uint16
+	for _, s := range statuses {
+		if s.Applied != nil && s.Version > version {
+			version = s.Version
+		}
+	}
+	return version
+}
+
+// runStep runs the step's file in a transaction, recording it in the history.
+func (m *Migrator) runStep(ctx context.Context, conn *sql.Conn, step MigrationStep) error {
+	name, script := step.Name, step.Script
+	if !step.Up {
+		name, script = step.RollbackName, step.Rollback
+	}
+	app.Log(app.Info, "Running sql script %v.", name)
+	start := time.Now()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, script); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Migration %v failed: %v.", name, err)
+	}
+	if step.Up {
+		_, err = tx.ExecContext(ctx, rebind(m.dialect, "insert into schema_migrations (version, name, checksum, duration_ms, applied) values ($1, $2, $3, $4, $5)"),
+			step.Version, step.Name, step.Checksum, time.Since(start).Nanoseconds()/int64(time.Millisecond), time.Now())
+	} else {
+		_, err = tx.ExecContext(ctx, rebind(m.dialect, "delete from schema_migrations where version = $1"), step.Version)
+	}
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Could not record migration %v: %v.", name, err)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("Migration %v failed: %v.", name, err)
+	}
+	app.Log(app.Info, "Ran sql script %v in %v.", name, time.Since(start))
+	return nil
+}
+
+// prepareHistory creates the history table. A database migrated before the
+// history was kept has its version in the application table, and every
+// migration up to that version is recorded as applied.
+func (m *Migrator) prepareHistory(ctx context.Context, conn *sql.Conn) error {
+	if _, err := conn.ExecContext(ctx, createMigrationsTable); err != nil {
+		return fmt.Errorf("Could not create the migration history: %v.", err)
+	}
+	var recorded int
+	if err := conn.QueryRowContext(ctx, "select count(*) from schema_migrations").Scan(&recorded); err != nil || recorded > 0 {
+		return err
+	}
+
+	var legacy uint16
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err = tx.QueryRowContext(ctx, "select version from application").Scan(&legacy); err != nil || legacy == 0 {
+		// a new database, which has no application table yet.
+		tx.Rollback()
+		return nil
+	}
+	for _, migration := range m.migrations {
+		if migration.Version > legacy {
+			break
+		}
+		if _, err = tx.ExecContext(ctx, rebind(m.dialect, "insert into schema_migrations (version, name, checksum, duration_ms, applied) values ($1, $2, $3, 0, $4)"),
+			migration.Version, migration.Name, migration.Checksum, time.Now()); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	app.Log(app.Info, "Recorded migrations up to version %v from the application table.", legacy)
+	return nil
+}
+
+func appliedMigrations(ctx context.Context, conn *sql.Conn) (map[uint16]AppliedMigration, error) {
+	rows, err := conn.QueryContext(ctx, "select version, name, checksum, duration_ms, applied from schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[uint16]AppliedMigration, 10)
+	for rows.Next() {
+		var a AppliedMigration
+		if err = rows.Scan(&a.Version, &a.Name, &a.Checksum, &a.DurationMs, &a.Applied); err != nil {
+			return nil, err
+		}
+		applied[a.Version] = a
+	}
+	return applied, rows.Err()
+}
+
+// CreateMigration scaffolds the update and rollback files of a new migration
+// in the sql directory under the project directory, numbered after the newest
+// one, returning their paths.
+func CreateMigration(projectDir, name string) (string, string, error) {
+	if len(strings.TrimSpace(name)) == 0 {
+		return "", "", errors.New("A migration must have a name.")
+	}
+	migrations, err := readMigrations(os.DirFS(projectDir), PostgresDialect)
+	if err != nil {
+		return "", "", err
+	}
+	version := 1
+	if len(migrations) > 0 {
+		version = int(migrations[len(migrations)-1].Version) + 1
+	}
+	update := filepath.Join(projectDir, "sql", fmt.Sprintf("update_%v.sql", version))
+	rollback := filepath.Join(projectDir, "sql", fmt.Sprintf("rollback_%v.sql", version))
+	header := fmt.Sprintf("-- %v\n-- version %v, created %v\n\n", name, version, time.Now().Format("2006-01-02"))
+	if err = ioutil.WriteFile(update, []byte(header), 0644); err != nil {
+		return "", "", err
+	}
+	if err = ioutil.WriteFile(rollback, []byte(header+"-- undo everything update_"+strconv.Itoa(version)+".sql does.\n"), 0644); err != nil {
+		return "", "", err
+	}
+	return update, rollback, nil
+}
+
+// readMigrations reads the update_N.sql and rollback_N.sql files, pairing
+// them by version and translating them for the dialect. Files in the
+// dialect's directory, such as sql/sqlite, replace the shared files of the
+// same name.
+func readMigrations(files fs.FS, dialect string) ([]Migration, error) {
+	byVersion := make(map[uint16]*Migration, 20)
+	if err := readMigrationDir(files, "sql", "", dialect, byVersion); err != nil {
+		return nil, err
+	}
+	if _, err := fs.Stat(files, "sql/"+dialect); err == nil {
+		if err = readMigrationDir(files, "sql/"+dialect, dialect+"/", dialect, byVersion); err != nil {
+			return nil, err
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if len(m.Name) == 0 {
+			return nil, fmt.Errorf("Rollback %v has no update.", m.RollbackName)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Sort(migrationSorter(migrations))
+	return migrations, nil
+}
+
+func readMigrationDir(files fs.FS, dir, prefix, dialect string, byVersion map[uint16]*Migration) error {
+	entries, err := fs.ReadDir(files, dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range entries {
+		name := file.Name()
+		update := strings.HasPrefix(name, "update_")
+		if file.IsDir() || !strings.HasSuffix(name, ".sql") || !(update || strings.HasPrefix(name, "rollback_")) {
+			continue
+		}
+		v, err := strconv.ParseUint(name[strings.Index(name, "_")+1:strings.Index(name, ".sql")], 10, 16)
+		if err != nil {
+			return fmt.Errorf("Could not read the version of %v.", prefix+name)
+		}
+		data, err := fs.ReadFile(files, dir+"/"+name)
+		if err != nil {
+			return err
+		}
+		script := translate(dialect, string(data))
+		m, found := byVersion[uint16(v)]
+		if !found {
+			m = &Migration{Version: uint16(v)}
+			byVersion[m.Version] = m
+		}
+		if update {
+			sum := sha256.Sum256([]byte(script))
+			m.Name, m.Script, m.Checksum = prefix+name, script, hex.EncodeToString(sum[:])
+		} else {
+			m.RollbackName, m.Rollback = prefix+name, script
+		}
+	}
+	return nil
+}
+
+type migrationSorter []Migration
+
+func (l migrationSorter) Len() int           { return len(l) }
+func (l migrationSorter) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l migrationSorter) Less(i, j int) bool { return l[i].Version < l[j].Version }
@@ -0,0 +1,134 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rchargel/localiday/app"
+)
+
+// Defines the types of notifications sent to users. REPORTED tells a user what
+// came of their report, APPROVED that their reported item was kept and
+// MODERATED that it was hidden or deleted.
+const (
+	NotifyReported   = "REPORTED"
+	NotifyApproved   = "APPROVED"
+	NotifyModerated  = "MODERATED"
+	NotifyFollowed   = "FOLLOWED"
+	NotifyContestWon = "CONTEST_WON"
+	NotifyBadge      = "BADGE"
+)
+
+// Defines how a type of notification is delivered to a user. Notifications
+// delivered by email are also kept in the inbox.
+const (
+	DeliverInApp = "IN_APP"
+	DeliverEmail = "EMAIL"
+	DeliverOff   = "OFF"
+)
+
+// NotificationTypes the types of notifications.
+var NotificationTypes = []string{NotifyReported, NotifyApproved, NotifyModerated, NotifyFollowed, NotifyContestWon, NotifyBadge}
+
+var deliveries = []string{DeliverInApp, DeliverEmail, DeliverOff}
+
+// Notification a message to a user about an item.
+type Notification struct {
+	ID               int64
+	UserID           int64  `db:"user_id"`
+	NotificationType string `db:"notification_type"`
+	Subject          string
+	Body             string
+	ItemType         string `db:"item_type"`
+	ItemID           int64  `db:"item_id"`
+	Read             bool
+	Created          time.Time
+}
+
+// NotificationPreference how a user wants a type of notification delivered.
+type NotificationPreference struct {
+	ID               int64
+	UserID           int64  `db:"user_id"`
+	NotificationType string `db:"notification_type"`
+	Delivery         string
+}
+
+// CreateNotification stores a notification in the user's inbox.
+func CreateNotification(n *Notification) error {
+	if !app.Contains(NotificationTypes, n.NotificationType) {
+		return fmt.Errorf("%v is not a valid notification type.", n.NotificationType)
+	}
+	n.Created = time.Now()
+	return insert(n)
+}
+
+// FindNotifications finds the user's notifications, newest first.
+func FindNotifications(userID int64, unreadOnly bool, offset, max int) ([]Notification, error) {
+	var notifications []Notification
+	query := "select * from notifications where user_id = $1"
+	if unreadOnly {
+		query += " and not read"
+	}
+	_, err := DB.Select(&notifications, query+" order by id desc limit $2 offset $3", userID, max, offset)
+	return notifications, err
+}
+
+// CountUnreadNotifications counts the user's unread notifications.
+func CountUnreadNotifications(userID int64) uint32 {
+	return count("select count(*) from notifications where user_id = $1 and not read", userID)
+}
+
+// MarkNotificationsRead marks the user's notifications as read, or all of
+// them if no IDs are given.
+func MarkNotificationsRead(userID int64, ids []int64) error {
+	q := newQuery("update notifications set read = true where user_id = $1 and not read", userID)
+	if len(ids) > 0 {
+		q.add(" and id in (" + q.bindIDs(ids) + ")")
+	}
+	_, err := q.exec()
+	return err
+}
+
+// GetDelivery gets how the user wants the type of notification delivered,
+// which is in-app unless they have said otherwise.
+func GetDelivery(userID int64, notificationType string) string {
+	var pref NotificationPreference
+	if err := DB.SelectOne(&pref, "select * from notification_preferences where user_id = $1 and notification_type = $2",
+		userID, notificationType); err != nil {
+		return DeliverInApp
+	}
+	return pref.Delivery
+}
+
+// FindDeliveries finds how the user wants each type of notification delivered.
+func FindDeliveries(userID int64) (map[string]string, error) {
+	var prefs []NotificationPreference
+	_, err := DB.Select(&prefs, "select * from notification_preferences where user_id = $1", userID)
+	deliveries := make(map[string]string, len(NotificationTypes))
+	for _, t := range NotificationTypes {
+		deliveries[t] = DeliverInApp
+	}
+	for _, p := range prefs {
+		deliveries[p.NotificationType] = p.Delivery
+	}
+	return deliveries, err
+}
+
+// SetDelivery sets how the user wants the type of notification delivered.
+func SetDelivery(userID int64, notificationType, delivery string) error {
+	if !app.Contains(NotificationTypes, notificationType) {
+		return fmt.Errorf("%v is not a valid notification type.", notificationType)
+	}
+	if !app.Contains(deliveries, delivery) {
+		return fmt.Errorf("%v is not a valid delivery.", delivery)
+	}
+	result, err := DB.Exec("update notification_preferences set delivery = $1 where user_id = $2 and notification_type = $3",
+		delivery, userID, notificationType)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		return nil
+	}
+	return insert(&NotificationPreference{UserID: userID, NotificationType: notificationType, Delivery: delivery})
+}
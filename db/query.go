@@ -0,0 +1,88 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+// query a SQL statement and the arguments bound to its $n parameters. Values
+// are only ever passed to the database as arguments, never written into the
+// statement.
+type query struct {
+	sql  string
+	args []interface{}
+}
+
+// newQuery creates a query from a statement whose $n parameters are bound to
+// the arguments in order.
+func newQuery(sql string, args ...interface{}) *query {
+	return &query{sql, args}
+}
+
+// bind binds the value to the next parameter, returning its placeholder.
+func (q *query) bind(value interface{}) string {
+	q.args = append(q.args, value)
+	return fmt.Sprintf("$%v", len(q.args))
+}
+
+// bindIDs binds each of the IDs, returning their placeholders separated by
+// commas for use in an "in" list.
+func (q *query) bindIDs(ids []int64) string {
+	placeholders := make([]string, len(ids))
+	for i, id := range ids {
+		placeholders[i] = q.bind(id)
+	}
+	return strings.Join(placeholders, ", ")
+}
+
+// add appends to the statement.
+func (q *query) add(sql string) *query {
+	q.sql += sql
+	return q
+}
+
+// selectOne selects a single row into the holder.
+func (q *query) selectOne(holder interface{}) error {
+	return DB.SelectOne(holder, q.sql, q.args...)
+}
+
+// selectAll selects every row into the holder, a pointer to a slice.
+func (q *query) selectAll(holder interface{}) error {
+	_, err := DB.Select(holder, q.sql, q.args...)
+	return err
+}
+
+// exec executes the statement, returning the number of rows affected.
+func (q *query) exec() (int64, error) {
+	result, err := DB.Exec(q.sql, q.args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// count selects a count.
+func (q *query) count() uint32 {
+	return count(q.sql, q.args...)
+}
+
+type displayCount struct {
+	DisplayID int64 `db:"display_id"`
+	Count     int64
+}
+
+// countByDisplay counts the rows of the table for each of the displays which
+// match the condition, keyed by display ID.
+func countByDisplay(table, condition string, displayIDs []int64) (map[int64]int64, error) {
+	counts := make(map[int64]int64, len(displayIDs))
+	if len(displayIDs) == 0 {
+		return counts, nil
+	}
+	var rows []displayCount
+	q := newQuery("select display_id, count(*) as count from " + table + " where " + condition)
+	err := q.add(" and display_id in (" + q.bindIDs(displayIDs) + ") group by display_id").selectAll(&rows)
+	for _, r := range rows {
+		counts[r.DisplayID] = r.Count
+	}
+	return counts, err
+}
@@ -0,0 +1,326 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rchargel/localiday/app"
+)
+
+// Defines the types of user generated items. Only displays and users may be
+// reported, as photos and reviews are not yet stored.
+const (
+	ItemDisplay = "DISPLAY"
+	ItemPhoto   = "PHOTO"
+	ItemReview  = "REVIEW"
+	ItemUser    = "USER"
+)
+
+// Defines the reasons an item may be reported for.
+const (
+	ReasonSpam          = "SPAM"
+	ReasonOffensive     = "OFFENSIVE"
+	ReasonInappropriate = "INAPPROPRIATE"
+	ReasonCopyright     = "COPYRIGHT"
+	ReasonInaccurate    = "INACCURATE"
+	ReasonOther         = "OTHER"
+)
+
+// Defines the states of a report.
+const (
+	ReportOpen      = "OPEN"
+	ReportClaimed   = "CLAIMED"
+	ReportResolved  = "RESOLVED"
+	ReportDismissed = "DISMISSED"
+)
+
+// Defines the decisions a moderator may make. AUTO_HIDE is recorded when an
+// item is hidden after being reported too many times.
+const (
+	ActionHide     = "HIDE"
+	ActionDelete   = "DELETE"
+	ActionDismiss  = "DISMISS"
+	ActionBan      = "BAN"
+	ActionAutoHide = "AUTO_HIDE"
+)
+
+var (
+	reportableItems   = []string{ItemDisplay, ItemUser}
+	reportReasons     = []string{ReasonSpam, ReasonOffensive, ReasonInappropriate, ReasonCopyright, ReasonInaccurate, ReasonOther}
+	moderationActions = []string{ActionHide, ActionDelete, ActionDismiss, ActionBan}
+)
+
+// Report a user's report of an item.
+type Report struct {
+	ID         int64
+	ItemType   string `db:"item_type"`
+	ItemID     int64  `db:"item_id"`
+	ReporterID int64  `db:"reporter_id"`
+	Reason     string
+	Comment    string
+	Status     string
+	ClaimedBy  int64 `db:"claimed_by"`
+
+	Audited
+}
+
+// ModeratedItem the visibility of an item which has been moderated.
+type ModeratedItem struct {
+	ID       int64
+	ItemType string `db:"item_type"`
+	ItemID   int64  `db:"item_id"`
+	Hidden   bool
+	Deleted  bool
+	Updated  time.Time
+}
+
+// ModerationDecision a decision made about a reported item. Automatic
+// decisions have no moderator.
+type ModerationDecision struct {
+	ID          int64
+	ReportID    int64  `db:"report_id"`
+	ItemType    string `db:"item_type"`
+	ItemID      int64  `db:"item_id"`
+	ModeratorID int64  `db:"moderator_id"`
+	Action      string
+	Note        string
+	Created     time.Time
+}
+
+// CreateReport creates a new open report of an item which exists. A user may
+// only report an item once, even if the report was deleted.
+func CreateReport(itemType string, itemID, reporterID int64, reason, comment string) (*Report, error) {
+	if !app.Contains(reportableItems, itemType) {
+		return nil, fmt.Errorf("%v is not a valid item type.", itemType)
+	}
+	if !app.Contains(reportReasons, reason) {
+		return nil, fmt.Errorf("%v is not a valid report reason.", reason)
+	}
+	if !itemExists(itemType, itemID) {
+		return nil, fmt.Errorf("Could not find %v %v to report.", strings.ToLower(itemType), itemID)
+	}
+	if c := count("select count(*) from reports where item_type = $1 and item_id = $2 and reporter_id = $3",
+		itemType, itemID, reporterID); c > 0 {
+		return nil, errors.New("You have already reported this item.")
+	}
+	report := &Report{
+		ItemType:   itemType,
+		ItemID:     itemID,
+		ReporterID: reporterID,
+		Reason:     reason,
+		Comment:    comment,
+		Status:     ReportOpen,
+	}
+	err := insert(report)
+	return report, err
+}
+
+// itemExists checks to see if a reportable item exists and has not been
+// deleted. A hidden item may still be reported.
+func itemExists(itemType string, itemID int64) bool {
+	table := "displays"
+	if itemType == ItemUser {
+		table = "users"
+	}
+	return count("select count(*) from "+table+" where id = $1 and "+notDeleted, itemID) > 0
+}
+
+// CountActiveReports counts the reports against an item from different users
+// which have not been dismissed.
+func CountActiveReports(itemType string, itemID int64) uint32 {
+	return countActiveReports(DB, itemType, itemID)
+}
+
+func countActiveReports(ex executor, itemType string, itemID int64) uint32 {
+	n, err := ex.SelectInt("select count(distinct reporter_id) from reports where item_type = $1 and item_id = $2 and status <> $3 and "+notDeleted,
+		itemType, itemID, ReportDismissed)
+	if err != nil {
+		app.Log(app.Error, "Could not count the reports against %v %v.", itemType, itemID, err)
+	}
+	return uint32(n)
+}
+
+// Get gets the report by the ID.
+func (r Report) Get(reportID int64) (*Report, error) {
+	err := DB.SelectOne(&r, "select * from reports where id = $1 and "+notDeleted, reportID)
+	return &r, err
+}
+
+// FindReports finds reports for the moderation queue, oldest first. Empty
+// filters are ignored.
+func (r Report) FindReports(status, itemType, reason string, offset, max int) ([]Report, error) {
+	q := newQuery("select * from reports")
+	where := []string{notDeleted}
+	for column, value := range map[string]string{"status": status, "item_type": itemType, "reason": reason} {
+		if len(value) > 0 {
+			where = append(where, column+" = "+q.bind(value))
+		}
+	}
+	q.add(" where " + strings.Join(where, " and "))
+	q.add(" order by created, id limit " + q.bind(max) + " offset " + q.bind(offset))
+
+	var reports []Report
+	err := q.selectAll(&reports)
+	return reports, err
+}
+
+// Claim claims an open report for the moderator. It fails with a conflict if
+// the report was changed, or claimed by someone else, after it was read.
+func (r *Report) Claim(moderatorID int64) error {
+	if r.Status != ReportOpen {
+		return fmt.Errorf("Report %v is not open.", r.ID)
+	}
+	claimed := *r
+	claimed.Status = ReportClaimed
+	claimed.ClaimedBy = moderatorID
+	if _, err := DB.Update(&claimed); err != nil {
+		return err
+	}
+	*r = claimed
+	return nil
+}
+
+// DeleteReport soft deletes a report.
+func DeleteReport(reportID int64) error {
+	return softDelete("reports", reportID)
+}
+
+// RestoreReport restores a deleted report.
+func RestoreReport(reportID int64) error {
+	return restore("reports", reportID)
+}
+
+// FindOpenReporterIDs finds the users with an open or claimed report against
+// an item.
+func FindOpenReporterIDs(itemType string, itemID int64) ([]int64, error) {
+	var ids []int64
+	_, err := DB.Select(&ids, "select reporter_id from reports where item_type = $1 and item_id = $2 and status in ($3, $4) and "+notDeleted+" order by id",
+		itemType, itemID, ReportOpen, ReportClaimed)
+	return ids, err
+}
+
+// Decide applies a moderator's decision to the report and records it, all
+// within one transaction. The report must be open or claimed, and it fails
+// with a conflict if the report was changed after it was read. Hiding,
+// deleting and banning resolve every open report against the item and hide
+// it, banning also deactivating the user banUserID. Dismissing dismisses the
+// report and shows the item again once no other report against it is active.
+func (r *Report) Decide(decision *ModerationDecision, banUserID int64) error {
+	if !app.Contains(moderationActions, decision.Action) {
+		return fmt.Errorf("%v is not a valid moderation action.", decision.Action)
+	}
+	if r.Status != ReportOpen && r.Status != ReportClaimed {
+		return fmt.Errorf("Report %v has already been decided.", r.ID)
+	}
+	if decision.Action == ActionBan && banUserID == 0 {
+		return fmt.Errorf("No user given to ban for %v %v.", r.ItemType, r.ItemID)
+	}
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	decided := *r
+	decided.Status = ReportResolved
+	if decision.Action == ActionDismiss {
+		decided.Status = ReportDismissed
+	}
+	// updating the report first locks it against another decision.
+	if _, err = tx.Update(&decided); err != nil {
+		tx.Rollback()
+		return err
+	}
+	switch decision.Action {
+	case ActionHide:
+		err = setItemVisibility(tx, r.ItemType, r.ItemID, true, false)
+	case ActionDelete:
+		err = setItemVisibility(tx, r.ItemType, r.ItemID, true, true)
+	case ActionDismiss:
+		if countActiveReports(tx, r.ItemType, r.ItemID) == 0 {
+			err = setItemVisibility(tx, r.ItemType, r.ItemID, false, false)
+		}
+	case ActionBan:
+		if err = deactivateUser(tx, banUserID); err == nil {
+			err = setItemVisibility(tx, r.ItemType, r.ItemID, true, false)
+		}
+	}
+	if err == nil && decision.Action != ActionDismiss {
+		err = closeReports(tx, r.ItemType, r.ItemID, decided.Status)
+	}
+	if err == nil {
+		err = recordDecision(tx, decision)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	*r = decided
+	return nil
+}
+
+// FindItemOwner finds the user who owns an item, if it is known. The owner of
+// a display is found even if it has been hidden or deleted.
+func FindItemOwner(itemType string, itemID int64) (int64, bool) {
+	switch itemType {
+	case ItemUser:
+		return itemID, true
+	case ItemDisplay:
+		if owner, err := DB.SelectInt("select user_id from displays where id = $1", itemID); err == nil && owner > 0 {
+			return owner, true
+		}
+	}
+	return 0, false
+}
+
+// closeReports closes every open or claimed report against an item.
+func closeReports(ex executor, itemType string, itemID int64, status string) error {
+	_, err := ex.Exec("update reports set status = $1, updated = $2, version = version + 1 where item_type = $3 and item_id = $4 and status in ($5, $6) and "+notDeleted,
+		status, time.Now(), itemType, itemID, ReportOpen, ReportClaimed)
+	return err
+}
+
+// RecordDecision records a moderation decision.
+func RecordDecision(decision *ModerationDecision) error {
+	return recordDecision(DB, decision)
+}
+
+func recordDecision(ex executor, decision *ModerationDecision) error {
+	decision.Created = time.Now()
+	app.Log(app.Info, "Moderation %v on %v %v by %v.", decision.Action, decision.ItemType, decision.ItemID, decision.ModeratorID)
+	return ex.Insert(decision)
+}
+
+// FindDecisions finds the decisions made about an item, newest first.
+func (d ModerationDecision) FindDecisions(itemType string, itemID int64) ([]ModerationDecision, error) {
+	var decisions []ModerationDecision
+	_, err := DB.Select(&decisions, "select * from moderation_decisions where item_type = $1 and item_id = $2 order by created desc",
+		itemType, itemID)
+	return decisions, err
+}
+
+// SetItemVisibility hides, deletes or restores an item.
+func SetItemVisibility(itemType string, itemID int64, hidden, deleted bool) error {
+	return setItemVisibility(DB, itemType, itemID, hidden, deleted)
+}
+
+func setItemVisibility(ex executor, itemType string, itemID int64, hidden, deleted bool) error {
+	result, err := ex.Exec("update moderated_items set hidden = $1, deleted = $2, updated = $3 where item_type = $4 and item_id = $5",
+		hidden, deleted, time.Now(), itemType, itemID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		return nil
+	}
+	return ex.Insert(&ModeratedItem{ItemType: itemType, ItemID: itemID, Hidden: hidden, Deleted: deleted, Updated: time.Now()})
+}
+
+// IsHidden checks to see if an item has been hidden or deleted by moderation.
+func IsHidden(itemType string, itemID int64) bool {
+	return count("select count(*) from moderated_items where item_type = $1 and item_id = $2 and (hidden or deleted)",
+		itemType, itemID) > 0
+}
@@ -0,0 +1,105 @@
+//go:build sqlite
+// +build sqlite
+
+package db
+
+import "testing"
+
+func TestReportOnlyItemsWhichExist(t *testing.T) {
+	useTestDatabase(t)
+	reporter, reported := createTestUser(t), createTestUser(t)
+	tests := []struct {
+		itemType string
+		itemID   int64
+		ok       bool
+	}{
+		{ItemUser, reported.ID, true},
+		{ItemUser, reported.ID + 1000, false},
+		{ItemDisplay, 999999, false},
+		{ItemPhoto, 1, false},
+		{ItemReview, 1, false},
+	}
+	for _, test := range tests {
+		if _, err := CreateReport(test.itemType, test.itemID, reporter.ID, ReasonSpam, ""); (err == nil) != test.ok {
+			t.Errorf("Reporting %v %v: %v", test.itemType, test.itemID, err)
+		}
+	}
+}
+
+func TestDecideOnlyOpenReports(t *testing.T) {
+	useTestDatabase(t)
+	reporter, other, moderator, reported := createTestUser(t), createTestUser(t), createTestUser(t), createTestUser(t)
+	report, err := CreateReport(ItemUser, reported.ID, reporter.ID, ReasonSpam, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := CreateReport(ItemUser, reported.ID, other.ID, ReasonOffensive, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale, _ := (Report{}).Get(report.ID)
+
+	hide := &ModerationDecision{ReportID: report.ID, ItemType: ItemUser, ItemID: reported.ID, ModeratorID: moderator.ID, Action: ActionHide}
+	if err = report.Decide(hide, 0); err != nil {
+		t.Fatal(err)
+	}
+	if report.Status != ReportResolved || !IsHidden(ItemUser, reported.ID) {
+		t.Errorf("Hidden report is %v, hidden %v", report.Status, IsHidden(ItemUser, reported.ID))
+	}
+	if found, _ := (Report{}).Get(second.ID); found.Status != ReportResolved {
+		t.Errorf("The other report against the user is %v, expected it resolved", found.Status)
+	}
+	if err = report.Decide(&ModerationDecision{Action: ActionDismiss}, 0); err == nil {
+		t.Error("Decided a report which was already resolved")
+	}
+
+	// a decision on a copy read before the report was resolved.
+	if err = stale.Decide(&ModerationDecision{ReportID: report.ID, ItemType: ItemUser, ItemID: reported.ID, Action: ActionDismiss}, 0); !IsConflict(err) {
+		t.Errorf("Deciding a stale report should conflict, got %v", err)
+	}
+	if decisions, _ := (ModerationDecision{}).FindDecisions(ItemUser, reported.ID); len(decisions) != 1 {
+		t.Errorf("Recorded %v decisions, expected only the first", len(decisions))
+	}
+	if !IsHidden(ItemUser, reported.ID) {
+		t.Error("A failed decision should leave the item hidden")
+	}
+}
+
+func TestDecideRejectsBadActions(t *testing.T) {
+	useTestDatabase(t)
+	reporter, reported := createTestUser(t), createTestUser(t)
+	report, err := CreateReport(ItemUser, reported.ID, reporter.ID, ReasonSpam, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, action := range []string{ActionAutoHide, "PUBLISH"} {
+		if err = report.Decide(&ModerationDecision{Action: action}, 0); err == nil {
+			t.Errorf("Decided %v on a report", action)
+		}
+	}
+	if err = report.Decide(&ModerationDecision{Action: ActionBan}, 0); err == nil {
+		t.Error("Banned without a user to ban")
+	}
+	if found, _ := (Report{}).Get(report.ID); found.Status != ReportOpen || found.Version != 1 {
+		t.Errorf("Report is %v at version %v after failed decisions", found.Status, found.Version)
+	}
+}
+
+func TestDecideBansUser(t *testing.T) {
+	useTestDatabase(t)
+	reporter, reported := createTestUser(t), createTestUser(t)
+	session := CreateNewSession(reported.ID)
+	report, err := CreateReport(ItemUser, reported.ID, reporter.ID, ReasonOffensive, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = report.Decide(&ModerationDecision{ReportID: report.ID, ItemType: ItemUser, ItemID: reported.ID, Action: ActionBan}, reported.ID); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := (User{}).Get(reported.ID); found.Active || found.Version != reported.Version+1 {
+		t.Errorf("Banned user is active %v at version %v", found.Active, found.Version)
+	}
+	if _, err = GetSessionBySessionID(session.SessionID); err == nil {
+		t.Error("Banning a user should end their session")
+	}
+}
@@ -0,0 +1,134 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/rchargel/localiday/app"
+)
+
+// UserRepository stores users.
+type UserRepository interface {
+	Get(userID int64) (*User, error)
+	FindByUsername(username string) (*User, error)
+	FindByUsernameAndPassword(username, password string) (*User, error)
+	Create(username, password, fullname, nickname, email string) (*User, error)
+	Update(user *User) error
+	Deactivate(userID int64) error
+	Delete(userID int64) error
+	Restore(userID int64) error
+	Count() uint32
+	CountActive() uint32
+}
+
+// RoleRepository stores roles and the users they are given to.
+type RoleRepository interface {
+	FindByAuthority(authority string) (*Role, error)
+	Create(authority string) (*Role, error)
+	AddToUser(user *User, role *Role) error
+	GetAuthorities(userID int64) []string
+}
+
+// SessionRepository stores user sessions. Sessions expire when they have not
+// been accessed for a while, and getting a session counts as accessing it.
+type SessionRepository interface {
+	Create(userID int64) *Session
+	CreateOAuth(userID int64, oauthToken, oauthProvider string) *Session
+	Get(sessionID string) (*Session, error)
+	Delete(sessionID string) error
+	Clean() error
+}
+
+// Repositories the user, role and session repositories used together.
+type Repositories struct {
+	Users    UserRepository
+	Roles    RoleRepository
+	Sessions SessionRepository
+}
+
+// NewDBRepositories creates repositories stored in the database.
+func NewDBRepositories() *Repositories {
+	return &Repositories{dbUsers{}, dbRoles{}, dbSessions{}}
+}
+
+// GetUserBySession gets the user of an active session.
+func (r *Repositories) GetUserBySession(sessionID string) (*User, error) {
+	s, err := r.Sessions.Get(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return r.Users.Get(s.UserID)
+}
+
+// IsAuthorized determines if the user of the session has any of the roles.
+func (r *Repositories) IsAuthorized(sessionID string, roles ...string) bool {
+	user, err := r.GetUserBySession(sessionID)
+	if err != nil {
+		app.Log(app.Error, "Could not find user for session %v.", sessionID)
+		return false
+	}
+	authorities := r.Roles.GetAuthorities(user.ID)
+	for _, authority := range roles {
+		if app.Contains(authorities, authority) {
+			return true
+		}
+	}
+	return false
+}
+
+type dbUsers struct{}
+
+func (d dbUsers) Get(userID int64) (*User, error) { return User{}.Get(userID) }
+
+func (d dbUsers) FindByUsername(username string) (*User, error) {
+	return User{}.FindByUsername(username)
+}
+
+func (d dbUsers) FindByUsernameAndPassword(username, password string) (*User, error) {
+	return User{}.FindByUsernameAndPassword(username, password)
+}
+
+func (d dbUsers) Create(username, password, fullname, nickname, email string) (*User, error) {
+	return CreateNewUser(username, password, fullname, nickname, email)
+}
+
+func (d dbUsers) Update(user *User) error       { return UpdateUser(user) }
+func (d dbUsers) Deactivate(userID int64) error { return DeactivateUser(userID) }
+func (d dbUsers) Delete(userID int64) error     { return DeleteUser(userID) }
+func (d dbUsers) Restore(userID int64) error    { return RestoreUser(userID) }
+func (d dbUsers) Count() uint32                 { return User{}.Count() }
+func (d dbUsers) CountActive() uint32           { return User{}.CountActive() }
+
+type dbRoles struct{}
+
+func (d dbRoles) FindByAuthority(authority string) (*Role, error) {
+	return Role{}.FindByAuthority(authority)
+}
+
+func (d dbRoles) Create(authority string) (*Role, error) {
+	role := &Role{Authority: authority}
+	if err := insert(role); err != nil {
+		return nil, fmt.Errorf("Could not create role %v.", authority)
+	}
+	return role, nil
+}
+
+func (d dbRoles) AddToUser(user *User, role *Role) error {
+	return insert(&UserRole{UserID: user.ID, RoleID: role.ID})
+}
+
+func (d dbRoles) GetAuthorities(userID int64) []string {
+	return (&User{ID: userID}).GetAuthoritiesStrings()
+}
+
+type dbSessions struct{}
+
+func (d dbSessions) Create(userID int64) *Session { return CreateNewSession(userID) }
+
+func (d dbSessions) CreateOAuth(userID int64, oauthToken, oauthProvider string) *Session {
+	return CreateNewOAuthSession(userID, oauthToken, oauthProvider)
+}
+
+func (d dbSessions) Get(sessionID string) (*Session, error) { return GetSessionBySessionID(sessionID) }
+
+func (d dbSessions) Delete(sessionID string) error { return DeleteSession(sessionID) }
+func (d dbSessions) Clean() error                  { return CleanSessions() }
@@ -0,0 +1,11 @@
+//go:build sqlite
+// +build sqlite
+
+package db
+
+import "testing"
+
+func TestDBRepositories(t *testing.T) {
+	useTestDatabase(t)
+	testRepositories(t, NewDBRepositories())
+}
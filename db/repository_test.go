@@ -0,0 +1,239 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/rchargel/localiday/app"
+)
+
+func TestMemoryRepositories(t *testing.T) {
+	testRepositories(t, NewMemoryRepositories())
+}
+
+// testRepositories the contract every implementation of the repositories must
+// keep. It only counts on what it creates, so it may run against a database
+// shared with other tests.
+func testRepositories(t *testing.T, repos *Repositories) {
+	testUserRepository(t, repos)
+	testUserDeletion(t, repos)
+	testRoleRepository(t, repos)
+	testSessionRepository(t, repos)
+}
+
+var contractNames int
+
+// contractName a name no other run of the contract has used.
+func contractName(prefix string) string {
+	contractNames++
+	return fmt.Sprintf("%v%v", prefix, contractNames)
+}
+
+func createContractUser(t *testing.T, repos *Repositories) *User {
+	name := contractName("contract")
+	user, err := repos.Users.Create(name, "secret", "Contract User", name, name+"@localiday.com")
+	if err != nil {
+		t.Fatalf("Could not create user %v: %v", name, err)
+	}
+	return user
+}
+
+func testUserRepository(t *testing.T, repos *Repositories) {
+	count, active := repos.Users.Count(), repos.Users.CountActive()
+	user := createContractUser(t, repos)
+	if !user.Active || user.Version != 1 || user.Created.IsZero() {
+		t.Errorf("Created user should be active at version 1 with a created time: %+v", user)
+	}
+	if n := repos.Users.Count(); n != count+1 {
+		t.Errorf("Count is %v after creating a user, expected %v", n, count+1)
+	}
+	if _, err := repos.Users.Create(user.Username, "other", "Other", "other", "other@localiday.com"); err == nil {
+		t.Errorf("Created a second user named %v", user.Username)
+	}
+
+	found, err := repos.Users.Get(user.ID)
+	if err != nil || found.Username != user.Username {
+		t.Errorf("Get(%v) = %+v, %v", user.ID, found, err)
+	}
+	if found, err = repos.Users.FindByUsername(user.Username); err != nil || found.ID != user.ID {
+		t.Errorf("FindByUsername(%v) = %+v, %v", user.Username, found, err)
+	}
+	if _, err = repos.Users.FindByUsername(contractName("nobody")); err == nil {
+		t.Error("Found a user who does not exist")
+	}
+	if _, err = repos.Users.Get(-1); err == nil {
+		t.Error("Got a user who does not exist")
+	}
+	if _, err = repos.Users.FindByUsernameAndPassword(user.Username, "secret"); err != nil {
+		t.Errorf("Could not log in as %v: %v", user.Username, err)
+	}
+	if _, err = repos.Users.FindByUsernameAndPassword(user.Username, "wrong"); err == nil {
+		t.Error("Logged in with the wrong password")
+	}
+
+	stale := *found
+	found.NickName = "renamed"
+	if err = repos.Users.Update(found); err != nil {
+		t.Fatalf("Could not update user %v: %v", user.ID, err)
+	}
+	if found.Version != stale.Version+1 {
+		t.Errorf("Version is %v after an update, expected %v", found.Version, stale.Version+1)
+	}
+	if updated, _ := repos.Users.Get(user.ID); updated == nil || updated.NickName != "renamed" {
+		t.Errorf("The update was not saved: %+v", updated)
+	}
+	stale.NickName = "overwritten"
+	if err = repos.Users.Update(&stale); !IsConflict(err) {
+		t.Errorf("Updating a stale user should conflict, got %v", err)
+	}
+
+	session := repos.Sessions.Create(user.ID)
+	found.Active = false
+	if err = repos.Users.Update(found); err != nil {
+		t.Fatalf("Could not update user %v: %v", user.ID, err)
+	}
+	if _, err = repos.Sessions.Get(session.SessionID); err == nil {
+		t.Error("Saving a user as inactive should end their session")
+	}
+	found.Active = true
+	if err = repos.Users.Update(found); err != nil {
+		t.Fatalf("Could not update user %v: %v", user.ID, err)
+	}
+
+	session = repos.Sessions.Create(user.ID)
+	version := found.Version
+	if err = repos.Users.Deactivate(user.ID); err != nil {
+		t.Fatalf("Could not deactivate user %v: %v", user.ID, err)
+	}
+	if found, err = repos.Users.Get(user.ID); err != nil || found.Active || found.Version != version+1 {
+		t.Errorf("Deactivated user is %+v, %v, expected inactive at version %v", found, err, version+1)
+	}
+	if n := repos.Users.CountActive(); n != active {
+		t.Errorf("CountActive is %v after deactivating, expected %v", n, active)
+	}
+	if _, err = repos.Users.FindByUsernameAndPassword(user.Username, "secret"); err == nil {
+		t.Error("Logged in as a deactivated user")
+	}
+	if _, err = repos.Sessions.Get(session.SessionID); err == nil {
+		t.Error("Deactivating a user should end their session")
+	}
+	if err = repos.Users.Deactivate(-1); err == nil {
+		t.Error("Deactivated a user who does not exist")
+	}
+}
+
+func testUserDeletion(t *testing.T, repos *Repositories) {
+	user := createContractUser(t, repos)
+	count := repos.Users.Count()
+	session := repos.Sessions.Create(user.ID)
+	if err := repos.Users.Delete(user.ID); err != nil {
+		t.Fatalf("Could not delete user %v: %v", user.ID, err)
+	}
+	if _, err := repos.Users.Get(user.ID); err == nil {
+		t.Error("Got a deleted user")
+	}
+	if _, err := repos.Users.FindByUsername(user.Username); err == nil {
+		t.Error("Found a deleted user by username")
+	}
+	if n := repos.Users.Count(); n != count-1 {
+		t.Errorf("Count is %v after deleting a user, expected %v", n, count-1)
+	}
+	if _, err := repos.Sessions.Get(session.SessionID); err == nil {
+		t.Error("Deleting a user should end their session")
+	}
+	if err := repos.Users.Deactivate(user.ID); err == nil {
+		t.Error("Deactivated a deleted user")
+	}
+	if err := repos.Users.Delete(user.ID); err == nil {
+		t.Error("Deleted a user twice")
+	}
+	if _, err := repos.Users.Create(user.Username, "secret", "Other", "other", "other@localiday.com"); err == nil {
+		t.Error("A deleted user's username should stay taken until they are restored")
+	}
+
+	if err := repos.Users.Restore(user.ID); err != nil {
+		t.Fatalf("Could not restore user %v: %v", user.ID, err)
+	}
+	if found, err := repos.Users.Get(user.ID); err != nil || !found.Active {
+		t.Errorf("Restored user is %+v, %v", found, err)
+	}
+	if err := repos.Users.Restore(user.ID); err == nil {
+		t.Error("Restored a user who was not deleted")
+	}
+}
+
+func testRoleRepository(t *testing.T, repos *Repositories) {
+	authority := contractName("CONTRACT_ROLE_")
+	role, err := repos.Roles.Create(authority)
+	if err != nil {
+		t.Fatalf("Could not create role %v: %v", authority, err)
+	}
+	if _, err = repos.Roles.Create(authority); err == nil {
+		t.Errorf("Created role %v twice", authority)
+	}
+	if found, err := repos.Roles.FindByAuthority(authority); err != nil || found.ID != role.ID {
+		t.Errorf("FindByAuthority(%v) = %+v, %v", authority, found, err)
+	}
+	if _, err = repos.Roles.FindByAuthority(contractName("NO_ROLE_")); err == nil {
+		t.Error("Found a role which does not exist")
+	}
+
+	user := createContractUser(t, repos)
+	if authorities := repos.Roles.GetAuthorities(user.ID); len(authorities) != 0 {
+		t.Errorf("New user has authorities %v", authorities)
+	}
+	if err = repos.Roles.AddToUser(user, role); err != nil {
+		t.Fatalf("Could not give user %v role %v: %v", user.ID, authority, err)
+	}
+	if err = repos.Roles.AddToUser(user, role); err == nil {
+		t.Error("Gave a user the same role twice")
+	}
+	if authorities := repos.Roles.GetAuthorities(user.ID); !app.Contains(authorities, authority) || len(authorities) != 1 {
+		t.Errorf("User has authorities %v, expected [%v]", authorities, authority)
+	}
+
+	session := repos.Sessions.Create(user.ID)
+	if !repos.IsAuthorized(session.SessionID, authority) {
+		t.Errorf("User should be authorized as %v", authority)
+	}
+	if repos.IsAuthorized(session.SessionID, RoleAdmin) {
+		t.Error("User should not be authorized as an admin")
+	}
+}
+
+func testSessionRepository(t *testing.T, repos *Repositories) {
+	user := createContractUser(t, repos)
+	session := repos.Sessions.Create(user.ID)
+	if len(session.SessionID) == 0 || session.UserID != user.ID {
+		t.Fatalf("Created session %+v for user %v", session, user.ID)
+	}
+	if again := repos.Sessions.Create(user.ID); again.SessionID != session.SessionID {
+		t.Error("A user should keep their session until it ends")
+	}
+	if found, err := repos.Sessions.Get(session.SessionID); err != nil || found.UserID != user.ID {
+		t.Errorf("Get(%v) = %+v, %v", session.SessionID, found, err)
+	}
+	if found, err := repos.GetUserBySession(session.SessionID); err != nil || found.ID != user.ID {
+		t.Errorf("GetUserBySession(%v) = %+v, %v", session.SessionID, found, err)
+	}
+	if _, err := repos.Sessions.Get("no-such-session"); err == nil {
+		t.Error("Got a session which does not exist")
+	}
+	if err := repos.Sessions.Delete(session.SessionID); err != nil {
+		t.Fatalf("Could not delete session %v: %v", session.SessionID, err)
+	}
+	if _, err := repos.Sessions.Get(session.SessionID); err == nil {
+		t.Error("Got a deleted session")
+	}
+
+	oauth := repos.Sessions.CreateOAuth(user.ID, "token", "google")
+	if oauth.SessionID == session.SessionID || oauth.OAuthProvider != "GOOGLE" || oauth.OAuthToken != "token" {
+		t.Errorf("Created OAuth session %+v", oauth)
+	}
+	if err := repos.Sessions.Clean(); err != nil {
+		t.Fatalf("Could not clean sessions: %v", err)
+	}
+	if _, err := repos.Sessions.Get(oauth.SessionID); err != nil {
+		t.Errorf("Cleaning ended a current session: %v", err)
+	}
+}
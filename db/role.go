@@ -17,6 +17,8 @@ type UserRole struct {
 type Role struct {
 	ID        int64
 	Authority string
+
+	Audited
 }
 
 // AddAuthorityToUser adds an existing authority/role to an existing user.
@@ -42,7 +44,7 @@ func CreateAuthority(authority string) *Role {
 // FindByAuthority finds a role by the given authority.
 func (r Role) FindByAuthority(authority string) (*Role, error) {
 	var role Role
-	err := DB.SelectOne(&role, fmt.Sprintf("select * from roles where authority = '%v'", authority))
+	err := newQuery("select * from roles where authority = $1 and "+notDeleted, authority).selectOne(&role)
 	if err != nil || len(role.Authority) == 0 {
 		app.Log(app.Debug, "Could not find role by authority: "+authority, err)
 		return nil, fmt.Errorf("Could not find role by authority: %v.", authority)
@@ -53,8 +55,8 @@ func (r Role) FindByAuthority(authority string) (*Role, error) {
 // GetAuthorities get the list of user authorities.
 func (u *User) GetAuthorities() []Role {
 	var roles []Role
-	DB.Select(&roles, fmt.Sprintf(`select r.* from users u inner join user_roles ur on u.id = ur.user_id
-  inner join roles r on ur.role_id = r.id where u.id = %v`, u.ID))
+	newQuery(`select r.* from users u inner join user_roles ur on u.id = ur.user_id
+  inner join roles r on ur.role_id = r.id where u.id = $1 and r.deleted_at is null`, u.ID).selectAll(&roles)
 	return roles
 }
 
@@ -3,7 +3,6 @@ package db
 import (
 	"crypto/rand"
 	"encoding/hex"
-	"fmt"
 	"strings"
 	"sync"
 	"time"
@@ -82,9 +81,8 @@ func CreateNewSession(userID int64) *Session {
 func GetSessionBySessionID(sessionID string) (*Session, error) {
 	sessionLock.Lock()
 	var s Session
-	err := DB.SelectOne(&s,
-		fmt.Sprintf("select * from sessions where session_id = '%v' and last_accessed > now() - interval '%v seconds'",
-			sessionID, sessionTimeoutSeconds))
+	err := newQuery("select * from sessions where session_id = $1 and last_accessed > $2",
+		sessionID, sessionExpiry()).selectOne(&s)
 
 	if err == nil {
 		updateLastAccessedSessionTime(s.ID)
@@ -105,9 +103,8 @@ func ValidateSession(sessionID string) (bool, error) {
 func CleanSessions() error {
 	sessionLock.Lock()
 	s := time.Now()
-	result, err := DB.Exec(fmt.Sprintf("delete from sessions where last_accessed < now() - interval '%v seconds'", sessionTimeoutSeconds))
+	count, err := newQuery("delete from sessions where last_accessed < $1", sessionExpiry()).exec()
 	if err == nil {
-		count, _ := result.RowsAffected()
 		if count == 0 {
 			app.Log(app.Debug, "No expired sessions found (%v).", time.Since(s))
 		} else {
@@ -122,10 +119,11 @@ func CleanSessions() error {
 }
 
 // DeleteSession used when the user logs out of their session.
-func DeleteSession(sessionID string) {
+func DeleteSession(sessionID string) error {
 	sessionLock.Lock()
-	DB.Exec("delete from sessions where session_id = ?", sessionID)
-	sessionLock.Unlock()
+	defer sessionLock.Unlock()
+	_, err := newQuery("delete from sessions where session_id = $1", sessionID).exec()
+	return err
 }
 
 // IsAuthorized determins if the user has any of the supplied roles.
@@ -146,7 +144,7 @@ func IsAuthorized(sessionID string, roles ...string) bool {
 func getSessionByUserID(userID int64) (*Session, error) {
 	sessionLock.Lock()
 	s := &Session{}
-	err := DB.SelectOne(s, fmt.Sprintf("select * from sessions where user_id = %v", userID))
+	err := newQuery("select * from sessions where user_id = $1", userID).selectOne(s)
 	if err == nil {
 		updateLastAccessedSessionTime(s.ID)
 	} else {
@@ -163,9 +161,14 @@ func createSessionString() string {
 }
 
 func updateLastAccessedSessionTime(sessionID int64) error {
-	_, err := DB.Exec(fmt.Sprintf("update sessions set last_accessed = now() where id = %v", sessionID))
+	_, err := newQuery("update sessions set last_accessed = $1 where id = $2", time.Now(), sessionID).exec()
 	if err != nil {
 		app.Log(app.Error, "Error updating session access time.", err)
 	}
 	return err
 }
+
+// sessionExpiry the last accessed time before which sessions have expired.
+func sessionExpiry() time.Time {
+	return time.Now().Add(-sessionTimeoutSeconds * time.Second)
+}
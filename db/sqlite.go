@@ -0,0 +1,7 @@
+//go:build sqlite
+// +build sqlite
+
+package db
+
+// The SQLite driver, which needs cgo, is only built in with the sqlite tag.
+import _ "github.com/mattn/go-sqlite3"
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rchargel/localiday/app"
+)
+
+// Defines the live statuses a visitor may report for a display.
+const (
+	StatusLit        = "LIT"
+	StatusDark       = "DARK"
+	StatusGone       = "GONE"
+	StatusRoadClosed = "ROAD_CLOSED"
+)
+
+var displayStatuses = []string{StatusLit, StatusDark, StatusGone, StatusRoadClosed}
+
+// StatusReport a visitor's report of a display's live status.
+type StatusReport struct {
+	ID        int64
+	DisplayID int64 `db:"display_id"`
+	UserID    int64 `db:"user_id"`
+	Status    string
+
+	Audited
+}
+
+// CreateStatusReport creates a new status report.
+func CreateStatusReport(displayID, userID int64, status string) (*StatusReport, error) {
+	if !app.Contains(displayStatuses, status) {
+		return nil, fmt.Errorf("%v is not a valid display status.", status)
+	}
+	report := &StatusReport{DisplayID: displayID, UserID: userID, Status: status}
+	err := insert(report)
+	return report, err
+}
+
+// DeleteStatusReport soft deletes one of the user's status reports.
+func DeleteStatusReport(userID, reportID int64) error {
+	return softDeleteOwned("status_reports", reportID, userID)
+}
+
+// RestoreStatusReport restores a deleted status report.
+func RestoreStatusReport(reportID int64) error {
+	return restore("status_reports", reportID)
+}
+
+// FindRecentStatusReports finds the status reports for the displays made since
+// the given time, newest first.
+func FindRecentStatusReports(displayIDs []int64, since time.Time) ([]StatusReport, error) {
+	var reports []StatusReport
+	if len(displayIDs) == 0 {
+		return reports, nil
+	}
+	q := newQuery("select * from status_reports where created > $1 and "+notDeleted, since)
+	q.add(" and display_id in (" + q.bindIDs(displayIDs) + ") order by created desc")
+	err := q.selectAll(&reports)
+	return reports, err
+}
+
+// CountStatusReportsByUser counts the status reports the user has made since
+// the given time, for all displays or, if the display ID is not zero, for one.
+// Deleted reports are counted, so deleting them cannot get around the limits.
+func CountStatusReportsByUser(userID, displayID int64, since time.Time) uint32 {
+	if displayID == 0 {
+		return count("select count(*) from status_reports where user_id = $1 and created > $2", userID, since)
+	}
+	return count("select count(*) from status_reports where user_id = $1 and display_id = $2 and created > $3",
+		userID, displayID, since)
+}
+
+// CountPastStatusReports counts the status reports the user made before the
+// given time, which have had a chance to be contradicted by others.
+func CountPastStatusReports(userID int64, before time.Time) uint32 {
+	return count("select count(*) from status_reports where user_id = $1 and created < $2 and "+notDeleted, userID, before)
+}
+
+// CountUpheldReports counts the reports against an item which a moderator
+// acted on.
+func CountUpheldReports(itemType string, itemID int64) uint32 {
+	return count("select count(*) from reports where item_type = $1 and item_id = $2 and status = $3 and "+notDeleted,
+		itemType, itemID, ReportResolved)
+}
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/rchargel/localiday/app"
+)
+
+const shareCodeSize = 8
+
+// Tour a saved tour of displays which may be shared by its share code.
+type Tour struct {
+	ID             int64
+	UserID         int64   `db:"user_id"`
+	ShareCode      string  `db:"share_code"`
+	StartLatitude  float64 `db:"start_latitude"`
+	StartLongitude float64 `db:"start_longitude"`
+	ReturnHome     bool    `db:"return_home"`
+	TotalDistance  float64 `db:"total_distance"`
+
+	Audited
+}
+
+// TourStop a display visited on a saved tour, in the order given by position.
+type TourStop struct {
+	ID        int64
+	TourID    int64 `db:"tour_id"`
+	Position  int
+	DisplayID int64 `db:"display_id"`
+	Latitude  float64
+	Longitude float64
+}
+
+// SaveTour saves the tour and its stops, creating a new share code for it.
+func SaveTour(tour *Tour, stops []TourStop) error {
+	tour.ShareCode = createShareCode()
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	if err = tx.Insert(tour); err != nil {
+		tx.Rollback()
+		return err
+	}
+	for i := range stops {
+		stops[i].TourID = tour.ID
+		stops[i].Position = i
+		if err = tx.Insert(&stops[i]); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	app.Log(app.Debug, "Saved tour %v with %v stops.", tour.ShareCode, len(stops))
+	return tx.Commit()
+}
+
+// FindByShareCode finds a saved tour by its share code.
+func (t Tour) FindByShareCode(shareCode string) (*Tour, error) {
+	var found Tour
+	err := DB.SelectOne(&found, "select * from tours where share_code = $1 and "+notDeleted, shareCode)
+	if err != nil {
+		app.Log(app.Debug, "Could not find tour: "+shareCode, err)
+		return nil, fmt.Errorf("Could not find a tour with the share code: %v.", shareCode)
+	}
+	return &found, nil
+}
+
+// DeleteTour soft deletes a tour saved by the user.
+func DeleteTour(userID int64, shareCode string) error {
+	n, err := newQuery("update tours set deleted_at = $1, updated = $1, version = version + 1 where user_id = $2 and share_code = $3 and "+notDeleted,
+		time.Now(), userID, shareCode).exec()
+	if err == nil && n == 0 {
+		err = fmt.Errorf("Could not find a tour with the share code: %v.", shareCode)
+	}
+	return err
+}
+
+// RestoreTour restores a deleted tour.
+func RestoreTour(shareCode string) error {
+	n, err := newQuery("update tours set deleted_at = null, updated = $1, version = version + 1 where share_code = $2 and deleted_at is not null",
+		time.Now(), shareCode).exec()
+	if err == nil && n == 0 {
+		err = fmt.Errorf("Could not find a deleted tour with the share code: %v.", shareCode)
+	}
+	return err
+}
+
+// GetStops gets the stops of the tour in the order they are visited.
+func (t *Tour) GetStops() []TourStop {
+	var stops []TourStop
+	DB.Select(&stops, "select * from tour_stops where tour_id = $1 order by position", t.ID)
+	return stops
+}
+
+func createShareCode() string {
+	rb := make([]byte, shareCodeSize)
+	rand.Read(rb)
+	return hex.EncodeToString(rb)
+}
@@ -19,6 +19,8 @@ type User struct {
 	Email           string
 	PasswordExpired bool `db:"password_expired"`
 	Active          bool
+
+	Audited
 }
 
 // CreateNewUser creates a new user with default configuration.
@@ -39,17 +41,14 @@ func CreateNewUser(username, password, fullname, nickname, email string) (*User,
 
 // PreInsert called before the user is inserted into the database.
 func (u *User) PreInsert(s gorp.SqlExecutor) error {
+	u.Audited.PreInsert(s)
 	return u.encryptPassword(u.Password)
 }
 
 // PreDelete called before the user is deleted.
 func (u *User) PreDelete(s gorp.SqlExecutor) error {
-	query := fmt.Sprintf("delete from user_roles where user_id = %v", u.ID)
-	_, err := s.Exec(query)
-	if err != nil {
-		return err
-	}
-	return nil
+	_, err := s.Exec("delete from user_roles where user_id = $1", u.ID)
+	return err
 }
 
 // SetPassword sets an encrypted version of the password.
@@ -65,7 +64,7 @@ func (u *User) encryptPassword(password string) error {
 
 // Get gets the user by the ID.
 func (u User) Get(userID int64) (*User, error) {
-	err := DB.SelectOne(&u, fmt.Sprintf("select * from users where id = %v", userID))
+	err := newQuery("select * from users where id = $1 and "+notDeleted, userID).selectOne(&u)
 	return &u, err
 }
 
@@ -73,8 +72,8 @@ func (u User) Get(userID int64) (*User, error) {
 func GetUserBySession(sessionID string) (*User, error) {
 	var u User
 	s, err := GetSessionBySessionID(sessionID)
-	if err != nil {
-		err = DB.SelectOne(&u, fmt.Sprintf("select * from users where id = %v", s.UserID))
+	if err == nil {
+		err = newQuery("select * from users where id = $1 and "+notDeleted, s.UserID).selectOne(&u)
 	}
 	return &u, err
 }
@@ -82,7 +81,7 @@ func GetUserBySession(sessionID string) (*User, error) {
 // FindByUsername used to find a user by their username.
 func (u User) FindByUsername(username string) (*User, error) {
 	var found User
-	err := DB.SelectOne(&found, fmt.Sprintf("select * from users where username = '%v'", username))
+	err := newQuery("select * from users where username = $1 and "+notDeleted, username).selectOne(&found)
 	if err != nil || len(found.Username) == 0 {
 		app.Log(app.Debug, "Could not find user: "+username, err)
 		return nil, fmt.Errorf("Could not find a user with the supplied username: %v.", username)
@@ -93,7 +92,7 @@ func (u User) FindByUsername(username string) (*User, error) {
 // FindByUsernameAndPassword used to find a user in order to perform a login.
 func (u User) FindByUsernameAndPassword(username, password string) (*User, error) {
 	var found User
-	err := DB.SelectOne(&found, fmt.Sprintf("select * from users where username = '%v'", username))
+	err := newQuery("select * from users where username = $1 and "+notDeleted, username).selectOne(&found)
 	if err != nil {
 		app.Log(app.Debug, "Could not find user: "+username, err)
 		return nil, fmt.Errorf("Could not find a user with the supplied username: %v.", username)
@@ -103,15 +102,83 @@ func (u User) FindByUsernameAndPassword(username, password string) (*User, error
 		app.Log(app.Debug, "Passwords did not match", err)
 		return nil, errors.New("Username and password do not match.")
 	}
+	if !found.Active {
+		return nil, errors.New("This account has been disabled.")
+	}
 	return &found, nil
 }
 
 // CountActive counts the number of active users in the system.
 func (u User) CountActive() uint32 {
-	return count("select count(*) from users where active = 't'")
+	return newQuery("select count(*) from users where active = $1 and "+notDeleted, true).count()
 }
 
 // Count counts the number of users in the system.
 func (u User) Count() uint32 {
-	return count("select count(*) from users")
+	return newQuery("select count(*) from users where " + notDeleted).count()
+}
+
+// UpdateUser saves changes to the user, ending their session if they are not
+// active. It fails with a conflict if the user was changed after it was read.
+func UpdateUser(user *User) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	if err = updateUser(tx, user); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// updateUser saves changes to the user within the transaction.
+func updateUser(tx *Transaction, user *User) error {
+	if _, err := tx.Update(user); err != nil {
+		return err
+	}
+	if !user.Active {
+		if _, err := tx.Exec("delete from sessions where user_id = $1", user.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeactivateUser deactivates a user who has not been deleted, ending any
+// session they have.
+func DeactivateUser(userID int64) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	if err = deactivateUser(tx, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// deactivateUser deactivates a user within the transaction.
+func deactivateUser(tx *Transaction, userID int64) error {
+	var user User
+	if err := tx.SelectOne(&user, "select * from users where id = $1 and "+notDeleted, userID); err != nil {
+		return fmt.Errorf("Could not find user %v.", userID)
+	}
+	user.Active = false
+	return updateUser(tx, &user)
+}
+
+// DeleteUser soft deletes the user and ends their sessions.
+func DeleteUser(userID int64) error {
+	if err := softDelete("users", userID); err != nil {
+		return err
+	}
+	_, err := newQuery("delete from sessions where user_id = $1", userID).exec()
+	return err
+}
+
+// RestoreUser restores a deleted user.
+func RestoreUser(userID int64) error {
+	return restore("users", userID)
 }
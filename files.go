@@ -0,0 +1,9 @@
+package main
+
+import "embed"
+
+// embedded the configuration, migrations and web assets, built into the
+// binary so that it can run from any directory.
+//
+//go:embed app/application.yaml app/oauth_config.yaml sql assets
+var embedded embed.FS
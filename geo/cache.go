@@ -0,0 +1,64 @@
+package geo
+
+import "container/list"
+
+// tileCache a least recently used cache of tile results holding at most size
+// entries. It is not safe for concurrent use, callers hold their own lock.
+type tileCache struct {
+	size    int
+	order   *list.List
+	entries map[interface{}]*list.Element
+}
+
+type cacheEntry struct {
+	key   interface{}
+	value interface{}
+}
+
+func newTileCache(size int) *tileCache {
+	return &tileCache{size, list.New(), make(map[interface{}]*list.Element, size)}
+}
+
+// get gets the value cached under the key, marking it as recently used.
+func (c *tileCache) get(key interface{}) (interface{}, bool) {
+	if e, found := c.entries[key]; found {
+		c.order.MoveToFront(e)
+		return e.Value.(*cacheEntry).value, true
+	}
+	return nil, false
+}
+
+// add caches the value under the key, evicting the least recently used
+// entry when the cache is full.
+func (c *tileCache) add(key, value interface{}) {
+	if e, found := c.entries[key]; found {
+		e.Value.(*cacheEntry).value = value
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key, value})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// remove removes the value cached under the key.
+func (c *tileCache) remove(key interface{}) {
+	if e, found := c.entries[key]; found {
+		c.order.Remove(e)
+		delete(c.entries, key)
+	}
+}
+
+// len gets the number of cached values.
+func (c *tileCache) len() int {
+	return c.order.Len()
+}
+
+// clear removes every cached value.
+func (c *tileCache) clear() {
+	c.order.Init()
+	c.entries = make(map[interface{}]*list.Element, c.size)
+}
@@ -0,0 +1,183 @@
+package geo
+
+import (
+	"sync"
+)
+
+const (
+	defaultCellSize       = 64
+	defaultMinClusterSize = 3
+	defaultMaxClusterZoom = 16
+	defaultMaxTiles       = 256
+	// clusterCacheSize the most tiles kept in the cache, the least recently
+	// used are dropped first.
+	clusterCacheSize = 20000
+)
+
+// Marker a display shown on the map.
+type Marker struct {
+	ID int64
+	Point
+}
+
+// Cluster a group of markers shown as one, with the markers' centroid, count
+// and the area they cover.
+type Cluster struct {
+	Centroid Point
+	Count    int
+	Bounds   BoundingBox
+}
+
+// Clusters the clusters and the individual markers shown in an area of the map.
+type Clusters struct {
+	Zoom     int
+	Clusters []Cluster
+	Markers  []Marker
+}
+
+// MarkerSource finds the markers inside an area of the map.
+type MarkerSource func(box BoundingBox) []Marker
+
+// Clusterer groups markers which are close together at a given zoom level.
+// Markers are grouped on a grid of CellSize pixels within each tile, and the
+// result for each tile is cached until markers inside it change. Grid cells
+// with fewer than MinClusterSize markers, and every marker beyond
+// MaxClusterZoom, are returned as individual markers. A request may cover at
+// most MaxTiles tiles.
+type Clusterer struct {
+	CellSize       int
+	MinClusterSize int
+	MaxClusterZoom int
+	MaxTiles       int
+
+	source MarkerSource
+	lock   sync.Mutex
+	cache  *tileCache
+	// generation counts the invalidations, so that a tile clustered while
+	// markers changed is not cached.
+	generation uint64
+}
+
+// NewClusterer creates a clusterer which reads markers from the source.
+func NewClusterer(source MarkerSource) *Clusterer {
+	return &Clusterer{
+		CellSize:       defaultCellSize,
+		MinClusterSize: defaultMinClusterSize,
+		MaxClusterZoom: defaultMaxClusterZoom,
+		MaxTiles:       defaultMaxTiles,
+		source:         source,
+		cache:          newTileCache(clusterCacheSize),
+	}
+}
+
+// Cluster gets the clusters and markers inside the box at the zoom level. An
+// error is returned if the box covers more than MaxTiles tiles at the zoom.
+func (c *Clusterer) Cluster(box BoundingBox, zoom int) (*Clusters, error) {
+	if err := box.Validate(); err != nil {
+		return nil, err
+	}
+	if zoom < 0 {
+		zoom = 0
+	} else if zoom > MaxZoom {
+		zoom = MaxZoom
+	}
+	tiles, err := TilesIn(box, zoom, c.MaxTiles)
+	if err != nil {
+		return nil, err
+	}
+	result := &Clusters{Zoom: zoom, Clusters: make([]Cluster, 0, 10), Markers: make([]Marker, 0, 10)}
+	if zoom > c.MaxClusterZoom {
+		result.Markers = append(result.Markers, c.source(box)...)
+		return result, nil
+	}
+	for _, tile := range tiles {
+		tc := c.ClusterTile(tile)
+		for _, cl := range tc.Clusters {
+			if box.Contains(cl.Centroid) {
+				result.Clusters = append(result.Clusters, cl)
+			}
+		}
+		for _, m := range tc.Markers {
+			if box.Contains(m.Point) {
+				result.Markers = append(result.Markers, m)
+			}
+		}
+	}
+	return result, nil
+}
+
+// ClusterTile gets the clusters and markers of a single tile, using the
+// cached result if there is one. The result is only cached if nothing was
+// invalidated while it was clustered.
+func (c *Clusterer) ClusterTile(tile Tile) *Clusters {
+	c.lock.Lock()
+	cached, found := c.cache.get(tile)
+	generation := c.generation
+	c.lock.Unlock()
+	if found {
+		return cached.(*Clusters)
+	}
+
+	result := c.clusterTile(tile)
+	c.lock.Lock()
+	if c.generation == generation {
+		c.cache.add(tile, result)
+	}
+	c.lock.Unlock()
+	return result
+}
+
+// Invalidate removes the cached tiles containing the point, at every zoom
+// level. Call it when a marker is added, moved or removed, for both its old
+// and its new position.
+func (c *Clusterer) Invalidate(p Point) {
+	c.lock.Lock()
+	c.generation++
+	for zoom := 0; zoom <= MaxZoom; zoom++ {
+		c.cache.remove(TileAt(p, zoom))
+	}
+	c.lock.Unlock()
+}
+
+// InvalidateAll removes every cached tile.
+func (c *Clusterer) InvalidateAll() {
+	c.lock.Lock()
+	c.generation++
+	c.cache.clear()
+	c.lock.Unlock()
+}
+
+func (c *Clusterer) clusterTile(tile Tile) *Clusters {
+	markers := c.source(tile.Bounds())
+	result := &Clusters{Zoom: tile.Z, Clusters: make([]Cluster, 0, 10), Markers: make([]Marker, 0, 10)}
+	cells := make(map[[2]int][]Marker, 16)
+	for _, m := range markers {
+		if TileAt(m.Point, tile.Z) != tile {
+			// markers on a shared edge belong to only one tile.
+			continue
+		}
+		x, y := tile.Pixel(m.Point)
+		cell := [2]int{int(x) / c.CellSize, int(y) / c.CellSize}
+		cells[cell] = append(cells[cell], m)
+	}
+	for _, group := range cells {
+		if len(group) < c.MinClusterSize {
+			result.Markers = append(result.Markers, group...)
+			continue
+		}
+		result.Clusters = append(result.Clusters, newCluster(group))
+	}
+	return result
+}
+
+func newCluster(markers []Marker) Cluster {
+	bounds := BoundingBox{markers[0].Latitude, markers[0].Longitude, markers[0].Latitude, markers[0].Longitude}
+	var lat, lon float64
+	for _, m := range markers {
+		lat += m.Latitude
+		lon += m.Longitude
+		bounds = bounds.Extend(m.Point)
+	}
+	n := float64(len(markers))
+	return Cluster{Centroid: Point{lat / n, lon / n}, Count: len(markers), Bounds: bounds}
+}
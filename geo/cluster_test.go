@@ -0,0 +1,81 @@
+package geo
+
+import "testing"
+
+func TestClusterLimitsTiles(t *testing.T) {
+	index := NewIndex()
+	index.Load(randomMarkers(1000))
+	c := NewClusterer(index.InBox)
+
+	world := BoundingBox{South: -85, West: -180, North: 85, East: 180}
+	if _, err := c.Cluster(world, 2); err != nil {
+		t.Errorf("Cluster(world, 2) failed: %v", err)
+	}
+	for _, zoom := range []int{8, c.MaxClusterZoom, MaxZoom} {
+		if _, err := c.Cluster(world, zoom); err == nil {
+			t.Errorf("Cluster(world, %v) should cover too many tiles", zoom)
+		}
+	}
+	if _, err := c.Cluster(benchmarkBox, 14); err != nil {
+		t.Errorf("Cluster(viewport, 14) failed: %v", err)
+	}
+}
+
+func TestClusterCacheIsBounded(t *testing.T) {
+	c := NewClusterer(func(box BoundingBox) []Marker { return nil })
+	for x := 0; x < clusterCacheSize+100; x++ {
+		c.ClusterTile(Tile{16, x, 100})
+	}
+	if n := c.cache.len(); n != clusterCacheSize {
+		t.Errorf("The cache holds %v tiles, expected %v", n, clusterCacheSize)
+	}
+	// the oldest tiles are dropped first.
+	if _, found := c.cache.get(Tile{16, 0, 100}); found {
+		t.Error("The least recently used tile should have been evicted")
+	}
+	if _, found := c.cache.get(Tile{16, clusterCacheSize + 99, 100}); !found {
+		t.Error("The most recently used tile should be cached")
+	}
+}
+
+func TestClusterInvalidatedByIndex(t *testing.T) {
+	index := NewIndex()
+	c := NewClusterer(index.InBox)
+	index.OnChange(c.Invalidate)
+
+	tile := TileAt(benchmarkCenter, 12)
+	if n := len(c.ClusterTile(tile).Markers); n != 0 {
+		t.Fatalf("Found %v markers in an empty index", n)
+	}
+	index.Put(Marker{ID: 1, Point: benchmarkCenter})
+	if n := len(c.ClusterTile(tile).Markers); n != 1 {
+		t.Errorf("Found %v markers after adding one, expected 1", n)
+	}
+	index.Remove(1)
+	if n := len(c.ClusterTile(tile).Markers); n != 0 {
+		t.Errorf("Found %v markers after removing it, expected 0", n)
+	}
+}
+
+func TestClusterTileChangedWhileClusteringIsNotCached(t *testing.T) {
+	index := NewIndex()
+	changed := false
+	c := NewClusterer(func(box BoundingBox) []Marker {
+		markers := index.InBox(box)
+		if !changed {
+			// a marker added after the tile's markers were read.
+			changed = true
+			index.Put(Marker{ID: 1, Point: benchmarkCenter})
+		}
+		return markers
+	})
+	index.OnChange(c.Invalidate)
+
+	tile := TileAt(benchmarkCenter, 12)
+	if n := len(c.ClusterTile(tile).Markers); n != 0 {
+		t.Fatalf("Found %v markers read before the change", n)
+	}
+	if n := len(c.ClusterTile(tile).Markers); n != 1 {
+		t.Errorf("Found %v markers after the change, the stale tile was cached", n)
+	}
+}
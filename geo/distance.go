@@ -0,0 +1,21 @@
+package geo
+
+import (
+	"math"
+)
+
+// EarthRadiusKm the mean radius of the earth in kilometers.
+const EarthRadiusKm = 6371.0088
+
+// Distance calculates the great-circle distance between two points in
+// kilometers, using the haversine formula.
+func Distance(a, b Point) float64 {
+	lat1 := radians(a.Latitude)
+	lat2 := radians(b.Latitude)
+	dLat := lat2 - lat1
+	dLon := radians(b.Longitude - a.Longitude)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EarthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
@@ -0,0 +1,194 @@
+package geo
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// Export formats and their content types.
+const (
+	GeoJSON = "geojson"
+	KML     = "kml"
+	GPX     = "gpx"
+
+	GeoJSONContentType = "application/geo+json"
+	KMLContentType     = "application/vnd.google-earth.kml+xml"
+	GPXContentType     = "application/gpx+xml"
+)
+
+// Place a named point to be exported.
+type Place struct {
+	Name        string
+	Description string
+	Point
+}
+
+// Export a set of places, and optionally the route between them, to be
+// written out in one of the export formats.
+type Export struct {
+	Name   string
+	Places []Place
+	Route  []Point
+}
+
+// ContentType gets the content type of the export format.
+func ContentType(format string) (string, error) {
+	switch format {
+	case GeoJSON:
+		return GeoJSONContentType, nil
+	case KML:
+		return KMLContentType, nil
+	case GPX:
+		return GPXContentType, nil
+	}
+	return "", fmt.Errorf("%v is not a valid export format.", format)
+}
+
+// Write writes the export to the writer in the given format.
+func (e *Export) Write(w io.Writer, format string) error {
+	switch format {
+	case GeoJSON:
+		return e.WriteGeoJSON(w)
+	case KML:
+		return e.WriteKML(w)
+	case GPX:
+		return e.WriteGPX(w)
+	}
+	return fmt.Errorf("%v is not a valid export format.", format)
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   geoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// WriteGeoJSON writes the export as a GeoJSON FeatureCollection, with the
+// route, if any, as a LineString feature.
+func (e *Export) WriteGeoJSON(w io.Writer) error {
+	features := make([]geoJSONFeature, 0, len(e.Places)+1)
+	for _, p := range e.Places {
+		features = append(features, geoJSONFeature{
+			Type:       "Feature",
+			Geometry:   geoJSONGeometry{"Point", lonLat(p.Point)},
+			Properties: map[string]interface{}{"name": p.Name, "description": p.Description},
+		})
+	}
+	if len(e.Route) > 1 {
+		coords := make([][]float64, len(e.Route))
+		for i, p := range e.Route {
+			coords[i] = lonLat(p)
+		}
+		features = append(features, geoJSONFeature{
+			Type:       "Feature",
+			Geometry:   geoJSONGeometry{"LineString", coords},
+			Properties: map[string]interface{}{"name": e.Name},
+		})
+	}
+	return json.NewEncoder(w).Encode(map[string]interface{}{
+		"type":     "FeatureCollection",
+		"features": features,
+	})
+}
+
+type kmlDocument struct {
+	XMLName   xml.Name       `xml:"http://www.opengis.net/kml/2.2 kml"`
+	Name      string         `xml:"Document>name"`
+	Placemark []kmlPlacemark `xml:"Document>Placemark"`
+}
+
+type kmlPlacemark struct {
+	Name        string     `xml:"name"`
+	Description string     `xml:"description,omitempty"`
+	Point       *kmlCoords `xml:"Point"`
+	LineString  *kmlCoords `xml:"LineString"`
+}
+
+type kmlCoords struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+// WriteKML writes the export as a KML document, with the route, if any, as
+// a LineString placemark.
+func (e *Export) WriteKML(w io.Writer) error {
+	doc := kmlDocument{Name: e.Name, Placemark: make([]kmlPlacemark, 0, len(e.Places)+1)}
+	for _, p := range e.Places {
+		doc.Placemark = append(doc.Placemark, kmlPlacemark{
+			Name:        p.Name,
+			Description: p.Description,
+			Point:       &kmlCoords{kmlCoordinate(p.Point)},
+		})
+	}
+	if len(e.Route) > 1 {
+		coords := ""
+		for i, p := range e.Route {
+			if i > 0 {
+				coords += " "
+			}
+			coords += kmlCoordinate(p)
+		}
+		doc.Placemark = append(doc.Placemark, kmlPlacemark{Name: e.Name, LineString: &kmlCoords{coords}})
+	}
+	return writeXML(w, doc)
+}
+
+type gpxDocument struct {
+	XMLName  xml.Name   `xml:"http://www.topografix.com/GPX/1/1 gpx"`
+	Version  string     `xml:"version,attr"`
+	Creator  string     `xml:"creator,attr"`
+	Name     string     `xml:"metadata>name"`
+	Waypoint []gpxPoint `xml:"wpt"`
+	Route    *gpxRoute  `xml:"rte"`
+}
+
+type gpxRoute struct {
+	Name  string     `xml:"name"`
+	Point []gpxPoint `xml:"rtept"`
+}
+
+type gpxPoint struct {
+	Latitude    float64 `xml:"lat,attr"`
+	Longitude   float64 `xml:"lon,attr"`
+	Name        string  `xml:"name,omitempty"`
+	Description string  `xml:"desc,omitempty"`
+}
+
+// WriteGPX writes the export as a GPX document of waypoints, with the route,
+// if any, as a GPX route.
+func (e *Export) WriteGPX(w io.Writer) error {
+	doc := gpxDocument{Version: "1.1", Creator: "Localiday", Name: e.Name, Waypoint: make([]gpxPoint, len(e.Places))}
+	for i, p := range e.Places {
+		doc.Waypoint[i] = gpxPoint{p.Latitude, p.Longitude, p.Name, p.Description}
+	}
+	if len(e.Route) > 1 {
+		doc.Route = &gpxRoute{Name: e.Name, Point: make([]gpxPoint, len(e.Route))}
+		for i, p := range e.Route {
+			doc.Route.Point[i] = gpxPoint{Latitude: p.Latitude, Longitude: p.Longitude}
+		}
+	}
+	return writeXML(w, doc)
+}
+
+func writeXML(w io.Writer, doc interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(doc)
+}
+
+func lonLat(p Point) []float64 {
+	return []float64{p.Longitude, p.Latitude}
+}
+
+func kmlCoordinate(p Point) string {
+	return fmt.Sprintf("%v,%v", p.Longitude, p.Latitude)
+}
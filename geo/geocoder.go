@@ -0,0 +1,296 @@
+package geo
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Gazetteer entry types, given in the first column of each gazetteer row.
+const (
+	gazetteerPostal = "postal"
+	gazetteerCity   = "city"
+	gazetteerStreet = "street"
+)
+
+var (
+	postalCodePattern    = regexp.MustCompile(`^[0-9]{5}(-[0-9]{4})?$`)
+	streetAddressPattern = regexp.MustCompile(`^([0-9]+)\s+(.+)$`)
+)
+
+// Location the result of a geocoding request.
+type Location struct {
+	Address    string
+	City       string
+	Region     string
+	Country    string
+	PostalCode string
+	Point
+}
+
+// Geocoder converts between addresses and points.
+type Geocoder interface {
+	Geocode(address string) (*Location, error)
+	ReverseGeocode(p Point) (*Location, error)
+}
+
+// Gazetteer a geocoder backed by a locally loaded list of postal codes,
+// cities and street address ranges.
+type Gazetteer struct {
+	lock    sync.RWMutex
+	postals map[string]Location
+	cities  map[string]Location
+	streets map[string][]streetRange
+}
+
+type streetRange struct {
+	street   string
+	city     string
+	region   string
+	fromNum  int
+	toNum    int
+	from, to Point
+}
+
+// NewGazetteer creates an empty gazetteer.
+func NewGazetteer() *Gazetteer {
+	return &Gazetteer{
+		postals: make(map[string]Location, 1000),
+		cities:  make(map[string]Location, 1000),
+		streets: make(map[string][]streetRange, 1000),
+	}
+}
+
+// LoadGazetteerFile creates a gazetteer from the given file.
+func LoadGazetteerFile(filename string) (*Gazetteer, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	g := NewGazetteer()
+	return g, g.Load(file)
+}
+
+// Load reads gazetteer entries from CSV data. Each row starts with its type:
+//
+//	postal,<code>,<city>,<region>,<country>,<lat>,<lon>
+//	city,<name>,<region>,<country>,<lat>,<lon>
+//	street,<name>,<city>,<region>,<from number>,<to number>,<from lat>,<from lon>,<to lat>,<to lon>
+//
+// Blank lines and rows starting with # are ignored.
+func (g *Gazetteer) Load(reader io.Reader) error {
+	r := csv.NewReader(reader)
+	r.FieldsPerRecord = -1
+	r.Comment = '#'
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	for entry := 1; ; entry++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = g.addRow(row); err != nil {
+			return fmt.Errorf("Invalid gazetteer entry %v: %v", entry, err)
+		}
+	}
+}
+
+func (g *Gazetteer) addRow(row []string) error {
+	for i := range row {
+		row[i] = strings.TrimSpace(row[i])
+	}
+	switch row[0] {
+	case gazetteerPostal:
+		if len(row) != 7 {
+			return errors.New("a postal code needs 7 columns")
+		}
+		p, err := parsePoint(row[5], row[6])
+		if err != nil {
+			return err
+		}
+		g.postals[row[1]] = Location{PostalCode: row[1], City: row[2], Region: row[3], Country: row[4], Point: p}
+	case gazetteerCity:
+		if len(row) != 6 {
+			return errors.New("a city needs 6 columns")
+		}
+		p, err := parsePoint(row[4], row[5])
+		if err != nil {
+			return err
+		}
+		g.cities[cityKey(row[1], row[2])] = Location{City: row[1], Region: row[2], Country: row[3], Point: p}
+	case gazetteerStreet:
+		if len(row) != 10 {
+			return errors.New("a street range needs 10 columns")
+		}
+		from, err := parsePoint(row[6], row[7])
+		if err != nil {
+			return err
+		}
+		to, err := parsePoint(row[8], row[9])
+		if err != nil {
+			return err
+		}
+		fromNum, err := strconv.Atoi(row[4])
+		if err != nil {
+			return err
+		}
+		toNum, err := strconv.Atoi(row[5])
+		if err != nil {
+			return err
+		}
+		key := streetKey(row[1], row[2], row[3])
+		g.streets[key] = append(g.streets[key], streetRange{row[1], row[2], row[3], fromNum, toNum, from, to})
+	default:
+		return fmt.Errorf("unknown type %v", row[0])
+	}
+	return nil
+}
+
+// Geocode finds the point for a postal code, a "city, region" or a street
+// address in the form "123 Main St, City, Region". Street addresses are
+// interpolated along the matching address range.
+func (g *Gazetteer) Geocode(address string) (*Location, error) {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	address = strings.TrimSpace(address)
+	if postalCodePattern.MatchString(address) {
+		if l, found := g.postals[address[:5]]; found {
+			return &l, nil
+		}
+		return nil, fmt.Errorf("Could not find postal code %v.", address)
+	}
+
+	parts := strings.Split(address, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	if len(parts) == 3 {
+		if m := streetAddressPattern.FindStringSubmatch(parts[0]); m != nil {
+			number, _ := strconv.Atoi(m[1])
+			if l, found := g.interpolate(number, m[2], parts[1], parts[2]); found {
+				return l, nil
+			}
+		}
+		parts = parts[1:]
+	}
+	if len(parts) == 2 {
+		if l, found := g.cities[cityKey(parts[0], parts[1])]; found {
+			return &l, nil
+		}
+	}
+	return nil, fmt.Errorf("Could not geocode address %v.", address)
+}
+
+func (g *Gazetteer) interpolate(number int, street, city, region string) (*Location, bool) {
+	for _, r := range g.streets[streetKey(street, city, region)] {
+		low, high := r.fromNum, r.toNum
+		if low > high {
+			low, high = high, low
+		}
+		if number < low || number > high || low == high && number != low {
+			continue
+		}
+		f := 0.0
+		if r.toNum != r.fromNum {
+			f = float64(number-r.fromNum) / float64(r.toNum-r.fromNum)
+		}
+		p := Point{
+			Latitude:  r.from.Latitude + f*(r.to.Latitude-r.from.Latitude),
+			Longitude: r.from.Longitude + f*(r.to.Longitude-r.from.Longitude),
+		}
+		l := &Location{Address: fmt.Sprintf("%v %v", number, r.street), City: r.city, Region: r.region, Point: p}
+		if c, found := g.cities[cityKey(city, region)]; found {
+			l.Country = c.Country
+		}
+		return l, true
+	}
+	return nil, false
+}
+
+// ReverseGeocode finds the city and region closest to the point, along with
+// the closest postal code.
+func (g *Gazetteer) ReverseGeocode(p Point) (*Location, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	var closest *Location
+	best := math.MaxFloat64
+	for _, l := range g.cities {
+		if d := Distance(p, l.Point); d < best {
+			c := l
+			closest, best = &c, d
+		}
+	}
+	var postal *Location
+	best = math.MaxFloat64
+	for _, l := range g.postals {
+		if d := Distance(p, l.Point); d < best {
+			c := l
+			postal, best = &c, d
+		}
+	}
+	if closest == nil {
+		closest = postal
+	}
+	if closest == nil {
+		return nil, errors.New("The gazetteer is empty.")
+	}
+	result := &Location{City: closest.City, Region: closest.Region, Country: closest.Country, Point: p}
+	if postal != nil {
+		result.PostalCode = postal.PostalCode
+	}
+	return result, nil
+}
+
+func parsePoint(lat, lon string) (Point, error) {
+	var p Point
+	var err error
+	if p.Latitude, err = strconv.ParseFloat(lat, 64); err != nil {
+		return p, err
+	}
+	if p.Longitude, err = strconv.ParseFloat(lon, 64); err != nil {
+		return p, err
+	}
+	return p, p.Validate()
+}
+
+func cityKey(city, region string) string {
+	return strings.ToLower(city + "|" + region)
+}
+
+func streetKey(street, city, region string) string {
+	return strings.ToLower(normalizeStreet(street) + "|" + city + "|" + region)
+}
+
+var streetSuffixes = map[string]string{
+	"street": "st", "avenue": "ave", "road": "rd", "drive": "dr", "lane": "ln",
+	"boulevard": "blvd", "court": "ct", "place": "pl", "circle": "cir",
+}
+
+// normalizeStreet shortens common street suffixes so that "Main Street" and
+// "Main St." match.
+func normalizeStreet(street string) string {
+	words := strings.Fields(strings.ToLower(strings.Replace(street, ".", "", -1)))
+	if n := len(words); n > 0 {
+		if short, found := streetSuffixes[words[n-1]]; found {
+			words[n-1] = short
+		}
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,300 @@
+package geo
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/rchargel/localiday/app"
+	"github.com/robfig/cron"
+)
+
+// Heatmap weightings.
+const (
+	WeightCount      = "count"
+	WeightRating     = "rating"
+	WeightPopularity = "popularity"
+
+	// heatBins the number of bins across each side of a tile which displays
+	// are aggregated into.
+	heatBins = 32
+	// heatRadius the radius, in pixels, each bin spreads over a rendered tile.
+	heatRadius = 12
+	// heatCacheSize the most tiles kept in the cache, the least recently used
+	// are dropped first.
+	heatCacheSize = 5000
+	// heatTileMaxAge how old a cached tile may be before it is regenerated,
+	// for weightings which change without displays moving.
+	heatTileMaxAge = time.Hour
+)
+
+// HeatSample a display to be counted in the heatmap along with the values
+// it may be weighted by.
+type HeatSample struct {
+	Point
+	Rating     float64
+	Popularity float64
+}
+
+// HeatPoint a pre-aggregated point of the heatmap.
+type HeatPoint struct {
+	Point
+	Weight float64
+}
+
+// HeatTile the aggregated density of a tile.
+type HeatTile struct {
+	Tile      Tile
+	Weight    string
+	Points    []HeatPoint
+	Generated time.Time
+
+	render sync.Once
+	png    []byte
+	err    error
+}
+
+// HeatSource finds the samples inside an area of the map.
+type HeatSource func(box BoundingBox) []HeatSample
+
+// Heatmap generates and caches density tiles. Tiles touched by changed data,
+// or older than an hour, are marked dirty and regenerated by a background
+// job, until then the previous tile continues to be served.
+type Heatmap struct {
+	source      HeatSource
+	lock        sync.Mutex
+	tiles       *tileCache
+	dirty       map[heatKey]bool
+	regenerator *cron.Cron
+	// generation counts the changes marked dirty, so that a tile generated
+	// while the data changed is not cached.
+	generation uint64
+}
+
+type heatKey struct {
+	tile   Tile
+	weight string
+}
+
+// NewHeatmap creates a heatmap which reads samples from the source.
+func NewHeatmap(source HeatSource) *Heatmap {
+	return &Heatmap{
+		source: source,
+		tiles:  newTileCache(heatCacheSize),
+		dirty:  make(map[heatKey]bool, 100),
+	}
+}
+
+// ValidateWeight checks that the weighting is supported.
+func ValidateWeight(weight string) error {
+	switch weight {
+	case WeightCount, WeightRating, WeightPopularity:
+		return nil
+	}
+	return fmt.Errorf("%v is not a valid heatmap weight.", weight)
+}
+
+// Tile gets the density of the tile with the given weighting, generating it
+// if it has not been cached. A generated tile is only cached if nothing was
+// marked dirty while it was generated.
+func (h *Heatmap) Tile(tile Tile, weight string) (*HeatTile, error) {
+	if err := tile.Validate(); err != nil {
+		return nil, err
+	}
+	if err := ValidateWeight(weight); err != nil {
+		return nil, err
+	}
+	key := heatKey{tile, weight}
+	h.lock.Lock()
+	cached, found := h.tiles.get(key)
+	if found && time.Since(cached.(*HeatTile).Generated) > heatTileMaxAge {
+		h.dirty[key] = true
+	}
+	generation := h.generation
+	h.lock.Unlock()
+	if found {
+		return cached.(*HeatTile), nil
+	}
+
+	generated := h.generate(key)
+	h.lock.Lock()
+	if h.generation == generation {
+		h.tiles.add(key, generated)
+	}
+	h.lock.Unlock()
+	return generated, nil
+}
+
+// MarkDirty marks the cached tiles containing the point, at every zoom level
+// and weighting, to be regenerated.
+func (h *Heatmap) MarkDirty(p Point) {
+	h.lock.Lock()
+	h.generation++
+	for zoom := 0; zoom <= MaxZoom; zoom++ {
+		tile := TileAt(p, zoom)
+		for _, weight := range []string{WeightCount, WeightRating, WeightPopularity} {
+			key := heatKey{tile, weight}
+			if _, found := h.tiles.get(key); found {
+				h.dirty[key] = true
+			}
+		}
+	}
+	h.lock.Unlock()
+}
+
+// Regenerate regenerates every dirty tile which is still cached.
+func (h *Heatmap) Regenerate() {
+	start := time.Now()
+	h.lock.Lock()
+	keys := make([]heatKey, 0, len(h.dirty))
+	for key := range h.dirty {
+		keys = append(keys, key)
+	}
+	h.dirty = make(map[heatKey]bool, 100)
+	h.lock.Unlock()
+
+	for _, key := range keys {
+		h.lock.Lock()
+		_, cached := h.tiles.get(key)
+		h.lock.Unlock()
+		if !cached {
+			continue
+		}
+		generated := h.generate(key)
+		h.lock.Lock()
+		h.tiles.add(key, generated)
+		h.lock.Unlock()
+	}
+	if len(keys) > 0 {
+		app.Log(app.Debug, "Regenerated %v heatmap tiles in %v.", len(keys), time.Since(start))
+	}
+}
+
+// StartRegeneration starts a background job which regenerates dirty tiles on
+// the cron schedule, until StopRegeneration is called.
+func (h *Heatmap) StartRegeneration(spec string) error {
+	c := cron.New()
+	if err := c.AddFunc(spec, h.Regenerate); err != nil {
+		return err
+	}
+	h.lock.Lock()
+	if h.regenerator != nil {
+		h.regenerator.Stop()
+	}
+	h.regenerator = c
+	h.lock.Unlock()
+	c.Start()
+	return nil
+}
+
+// StopRegeneration stops the background job regenerating dirty tiles.
+func (h *Heatmap) StopRegeneration() {
+	h.lock.Lock()
+	if h.regenerator != nil {
+		h.regenerator.Stop()
+		h.regenerator = nil
+	}
+	h.lock.Unlock()
+}
+
+func (h *Heatmap) generate(key heatKey) *HeatTile {
+	bounds := key.tile.Bounds()
+	var bins [heatBins][heatBins]float64
+	var sums [heatBins][heatBins][2]float64
+	for _, s := range h.source(bounds) {
+		if TileAt(s.Point, key.tile.Z) != key.tile {
+			continue
+		}
+		w := weigh(s, key.weight)
+		if w <= 0 {
+			continue
+		}
+		x, y := key.tile.Pixel(s.Point)
+		bx := clamp(int(x*heatBins/TileSize), heatBins-1)
+		by := clamp(int(y*heatBins/TileSize), heatBins-1)
+		bins[bx][by] += w
+		sums[bx][by][0] += s.Latitude * w
+		sums[bx][by][1] += s.Longitude * w
+	}
+
+	points := make([]HeatPoint, 0, 64)
+	for x := 0; x < heatBins; x++ {
+		for y := 0; y < heatBins; y++ {
+			if w := bins[x][y]; w > 0 {
+				points = append(points, HeatPoint{Point{sums[x][y][0] / w, sums[x][y][1] / w}, w})
+			}
+		}
+	}
+	return &HeatTile{Tile: key.tile, Weight: key.weight, Points: points, Generated: time.Now()}
+}
+
+func weigh(s HeatSample, weight string) float64 {
+	switch weight {
+	case WeightRating:
+		return s.Rating
+	case WeightPopularity:
+		return s.Popularity
+	}
+	return 1
+}
+
+// PNG renders the tile as a transparent PNG heat image. The image is rendered
+// once and kept with the tile.
+func (t *HeatTile) PNG() ([]byte, error) {
+	t.render.Do(func() { t.png, t.err = t.renderPNG() })
+	return t.png, t.err
+}
+
+func (t *HeatTile) renderPNG() ([]byte, error) {
+	var intensity [TileSize][TileSize]float64
+	max := 0.0
+	for _, p := range t.Points {
+		cx, cy := t.Tile.Pixel(p.Point)
+		for x := int(cx) - heatRadius; x <= int(cx)+heatRadius; x++ {
+			for y := int(cy) - heatRadius; y <= int(cy)+heatRadius; y++ {
+				if x < 0 || y < 0 || x >= TileSize || y >= TileSize {
+					continue
+				}
+				d2 := (float64(x)-cx)*(float64(x)-cx) + (float64(y)-cy)*(float64(y)-cy)
+				intensity[x][y] += p.Weight * math.Exp(-d2/(2*heatRadius*heatRadius/9))
+				max = math.Max(max, intensity[x][y])
+			}
+		}
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, TileSize, TileSize))
+	if max > 0 {
+		for x := 0; x < TileSize; x++ {
+			for y := 0; y < TileSize; y++ {
+				if v := intensity[x][y] / max; v > 0.01 {
+					img.SetNRGBA(x, y, heatColor(v))
+				}
+			}
+		}
+	}
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, img); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// heatColor maps an intensity between 0 and 1 onto a blue, green, yellow, red
+// ramp which becomes more opaque as the intensity rises.
+func heatColor(v float64) color.NRGBA {
+	ramp := []color.NRGBA{{0, 0, 255, 0}, {0, 255, 255, 0}, {0, 255, 0, 0}, {255, 255, 0, 0}, {255, 0, 0, 0}}
+	pos := v * float64(len(ramp)-1)
+	i := int(pos)
+	if i >= len(ramp)-1 {
+		i = len(ramp) - 2
+	}
+	f := pos - float64(i)
+	a, b := ramp[i], ramp[i+1]
+	mix := func(x, y uint8) uint8 { return uint8(float64(x) + f*(float64(y)-float64(x))) }
+	return color.NRGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), uint8(math.Min(255, 60+v*195))}
+}
@@ -0,0 +1,70 @@
+package geo
+
+import "testing"
+
+func TestHeatmapCacheIsBounded(t *testing.T) {
+	h := NewHeatmap(func(box BoundingBox) []HeatSample { return nil })
+	for x := 0; x < heatCacheSize+10; x++ {
+		if _, err := h.Tile(Tile{16, x, 100}, WeightCount); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := h.tiles.len(); n != heatCacheSize {
+		t.Errorf("The cache holds %v tiles, expected %v", n, heatCacheSize)
+	}
+}
+
+func TestHeatmapRegeneratesDirtyTiles(t *testing.T) {
+	samples := []HeatSample{}
+	h := NewHeatmap(func(box BoundingBox) []HeatSample { return samples })
+	tile := TileAt(benchmarkCenter, 10)
+	if heat, _ := h.Tile(tile, WeightCount); len(heat.Points) != 0 {
+		t.Fatalf("Found %v points in an empty heatmap", len(heat.Points))
+	}
+
+	samples = append(samples, HeatSample{Point: benchmarkCenter})
+	h.MarkDirty(benchmarkCenter)
+	if heat, _ := h.Tile(tile, WeightCount); len(heat.Points) != 0 {
+		t.Error("The previous tile should be served until it is regenerated")
+	}
+	h.Regenerate()
+	if heat, _ := h.Tile(tile, WeightCount); len(heat.Points) != 1 {
+		t.Errorf("Found %v points after regenerating, expected 1", len(heat.Points))
+	}
+}
+
+func TestHeatmapStopRegeneration(t *testing.T) {
+	h := NewHeatmap(func(box BoundingBox) []HeatSample { return nil })
+	if err := h.StartRegeneration("not a schedule"); err == nil {
+		t.Error("An invalid schedule should not start")
+	}
+	if err := h.StartRegeneration("0 * * * * *"); err != nil {
+		t.Fatal(err)
+	}
+	h.StopRegeneration()
+	if h.regenerator != nil {
+		t.Error("The regeneration job should be stopped")
+	}
+	h.StopRegeneration()
+}
+
+func TestHeatmapTileChangedWhileGeneratingIsNotCached(t *testing.T) {
+	var h *Heatmap
+	samples := []HeatSample{}
+	h = NewHeatmap(func(box BoundingBox) []HeatSample {
+		read := samples
+		if len(samples) == 0 {
+			// a sample added after the tile's samples were read.
+			samples = append(samples, HeatSample{Point: benchmarkCenter})
+			h.MarkDirty(benchmarkCenter)
+		}
+		return read
+	})
+	tile := TileAt(benchmarkCenter, 10)
+	if heat, _ := h.Tile(tile, WeightCount); len(heat.Points) != 0 {
+		t.Fatalf("Found %v points read before the change", len(heat.Points))
+	}
+	if heat, _ := h.Tile(tile, WeightCount); len(heat.Points) != 1 {
+		t.Errorf("Found %v points after the change, the stale tile was cached", len(heat.Points))
+	}
+}
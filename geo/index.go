@@ -0,0 +1,249 @@
+package geo
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// indexCellDegrees the size, in degrees, of each cell of the spatial index.
+// Roughly 5.5km north to south, which keeps a city viewport to a few hundred
+// cells.
+const indexCellDegrees = 0.05
+
+// Index an in-memory spatial index of markers for viewport and radius
+// queries. Markers are bucketed into a fixed grid of latitude and longitude
+// cells. The index is safe for concurrent use, and is kept current by calling
+// Put and Remove as markers change.
+type Index struct {
+	lock     sync.RWMutex
+	cells    map[indexCell]map[int64]Marker
+	markers  map[int64]Marker
+	onChange []func(p Point)
+}
+
+type indexCell struct {
+	lat int
+	lon int
+}
+
+// Nearby a marker found by a radius query, with its distance in kilometers.
+type Nearby struct {
+	Marker
+	Distance float64
+}
+
+// NewIndex creates an empty spatial index.
+func NewIndex() *Index {
+	return &Index{
+		cells:   make(map[indexCell]map[int64]Marker, 1000),
+		markers: make(map[int64]Marker, 1000),
+	}
+}
+
+// OnChange registers a function to be called with the old and new positions
+// of every marker Put or Removed, so caches of the area may be invalidated.
+func (i *Index) OnChange(f func(p Point)) {
+	i.lock.Lock()
+	i.onChange = append(i.onChange, f)
+	i.lock.Unlock()
+}
+
+// Load adds all of the markers to the index, replacing any with the same ID.
+// Changes made by loading are not passed to the OnChange functions.
+func (i *Index) Load(markers []Marker) {
+	i.lock.Lock()
+	for _, m := range markers {
+		i.put(m)
+	}
+	i.lock.Unlock()
+}
+
+// Put adds the marker to the index, or moves it if it is already indexed.
+func (i *Index) Put(m Marker) {
+	i.lock.Lock()
+	old, found := i.markers[m.ID]
+	i.put(m)
+	onChange := i.onChange
+	i.lock.Unlock()
+	for _, f := range onChange {
+		if found {
+			f(old.Point)
+		}
+		f(m.Point)
+	}
+}
+
+// Remove removes the marker with the ID from the index.
+func (i *Index) Remove(id int64) {
+	i.lock.Lock()
+	old, found := i.markers[id]
+	i.remove(id)
+	onChange := i.onChange
+	i.lock.Unlock()
+	if found {
+		for _, f := range onChange {
+			f(old.Point)
+		}
+	}
+}
+
+// Get gets the marker with the ID.
+func (i *Index) Get(id int64) (Marker, bool) {
+	i.lock.RLock()
+	m, found := i.markers[id]
+	i.lock.RUnlock()
+	return m, found
+}
+
+// Len gets the number of markers in the index.
+func (i *Index) Len() int {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	return len(i.markers)
+}
+
+// InBox finds the markers inside the box. It may be used as the
+// MarkerSource of a Clusterer.
+func (i *Index) InBox(box BoundingBox) []Marker {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	found := make([]Marker, 0, 64)
+	// the cells are clamped to the grid, so no box can walk more cells than
+	// the earth has.
+	south, north := clampCell(cellOf(box.South), latCells), clampCell(cellOf(box.North), latCells)
+	ranges := lonCellRanges(box)
+	for i := range ranges {
+		ranges[i] = [2]int{clampCell(ranges[i][0], lonCells), clampCell(ranges[i][1], lonCells)}
+	}
+	if covered := cellsCovered(south, north, ranges); covered > len(i.cells) {
+		// a large box covers more cells than are filled, so scan the filled ones.
+		for _, bucket := range i.cells {
+			for _, m := range bucket {
+				if box.Contains(m.Point) {
+					found = append(found, m)
+				}
+			}
+		}
+		return found
+	}
+	for _, lons := range ranges {
+		for lat := south; lat <= north; lat++ {
+			for lon := lons[0]; lon <= lons[1]; lon++ {
+				for _, m := range i.cells[indexCell{lat, lon}] {
+					if box.Contains(m.Point) {
+						found = append(found, m)
+					}
+				}
+			}
+		}
+	}
+	return found
+}
+
+// WithinRadius finds the markers within the radius, in kilometers, of the
+// point, closest first.
+func (i *Index) WithinRadius(p Point, radiusKm float64) []Nearby {
+	box := RadiusBox(p, radiusKm)
+	found := make([]Nearby, 0, 64)
+	for _, m := range i.InBox(box) {
+		if d := Distance(p, m.Point); d <= radiusKm {
+			found = append(found, Nearby{m, d})
+		}
+	}
+	sort.Sort(nearbySorter(found))
+	return found
+}
+
+func (i *Index) put(m Marker) {
+	i.remove(m.ID)
+	cell := indexCell{cellOf(m.Latitude), cellOf(m.Longitude)}
+	bucket, found := i.cells[cell]
+	if !found {
+		bucket = make(map[int64]Marker, 8)
+		i.cells[cell] = bucket
+	}
+	bucket[m.ID] = m
+	i.markers[m.ID] = m
+}
+
+func (i *Index) remove(id int64) {
+	old, found := i.markers[id]
+	if !found {
+		return
+	}
+	cell := indexCell{cellOf(old.Latitude), cellOf(old.Longitude)}
+	delete(i.cells[cell], id)
+	if len(i.cells[cell]) == 0 {
+		delete(i.cells, cell)
+	}
+	delete(i.markers, id)
+}
+
+func cellOf(degrees float64) int {
+	return int(math.Floor(degrees / indexCellDegrees))
+}
+
+// the range of cells covering the latitudes and longitudes.
+var (
+	latCells = [2]int{cellOf(-90), cellOf(90)}
+	lonCells = [2]int{cellOf(-180), cellOf(180)}
+)
+
+// clampCell limits the cell to the range.
+func clampCell(cell int, cells [2]int) int {
+	if cell < cells[0] {
+		return cells[0]
+	}
+	if cell > cells[1] {
+		return cells[1]
+	}
+	return cell
+}
+
+// lonCellRanges gets the ranges of longitude cells covered by the box, split
+// in two when it crosses the antimeridian.
+func lonCellRanges(box BoundingBox) [][2]int {
+	if box.West <= box.East {
+		return [][2]int{{cellOf(box.West), cellOf(box.East)}}
+	}
+	return [][2]int{{cellOf(box.West), cellOf(180)}, {cellOf(-180), cellOf(box.East)}}
+}
+
+func cellsCovered(south, north int, ranges [][2]int) int {
+	covered := 0
+	for _, lons := range ranges {
+		covered += (north - south + 1) * (lons[1] - lons[0] + 1)
+	}
+	return covered
+}
+
+// RadiusBox gets a box which contains the circle of the radius around the point.
+func RadiusBox(p Point, radiusKm float64) BoundingBox {
+	dLat := degrees(radiusKm / EarthRadiusKm)
+	south := math.Max(-90, p.Latitude-dLat)
+	north := math.Min(90, p.Latitude+dLat)
+	if south == -90 || north == 90 {
+		return BoundingBox{South: south, West: -180, North: north, East: 180}
+	}
+	dLon := degrees(radiusKm / (EarthRadiusKm * math.Cos(radians(math.Max(math.Abs(south), math.Abs(north))))))
+	if dLon >= 180 {
+		return BoundingBox{South: south, West: -180, North: north, East: 180}
+	}
+	west := p.Longitude - dLon
+	if west < -180 {
+		west += 360
+	}
+	east := p.Longitude + dLon
+	if east > 180 {
+		east -= 360
+	}
+	return BoundingBox{South: south, West: west, North: north, East: east}
+}
+
+type nearbySorter []Nearby
+
+func (l nearbySorter) Len() int           { return len(l) }
+func (l nearbySorter) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l nearbySorter) Less(i, j int) bool { return l[i].Distance < l[j].Distance }
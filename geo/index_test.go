@@ -0,0 +1,162 @@
+package geo
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+const benchmarkMarkers = 100000
+
+// a neighbourhood sized viewport and a search radius.
+var (
+	benchmarkBox    = BoundingBox{South: 41.85, West: -87.68, North: 41.91, East: -87.6}
+	benchmarkCenter = Point{Latitude: 41.88, Longitude: -87.63}
+)
+
+// randomMarkers scatters markers over the lower 48 states, with a quarter of
+// them packed into the Chicago area.
+func randomMarkers(n int) []Marker {
+	r := rand.New(rand.NewSource(1225))
+	markers := make([]Marker, n)
+	for i := range markers {
+		p := Point{Latitude: 25 + r.Float64()*24, Longitude: -124 + r.Float64()*57}
+		if i%4 == 0 {
+			p = Point{Latitude: 41.5 + r.Float64()*0.7, Longitude: -88.2 + r.Float64()*0.8}
+		}
+		markers[i] = Marker{ID: int64(i + 1), Point: p}
+	}
+	return markers
+}
+
+func linearInBox(markers []Marker, box BoundingBox) []Marker {
+	found := make([]Marker, 0, 64)
+	for _, m := range markers {
+		if box.Contains(m.Point) {
+			found = append(found, m)
+		}
+	}
+	return found
+}
+
+func linearWithinRadius(markers []Marker, p Point, radiusKm float64) []Nearby {
+	found := make([]Nearby, 0, 64)
+	for _, m := range markers {
+		if d := Distance(p, m.Point); d <= radiusKm {
+			found = append(found, Nearby{m, d})
+		}
+	}
+	sort.Sort(nearbySorter(found))
+	return found
+}
+
+func markerIDs(markers []Marker) []int64 {
+	ids := make([]int64, len(markers))
+	for i, m := range markers {
+		ids[i] = m.ID
+	}
+	sort.Sort(int64Sorter(ids))
+	return ids
+}
+
+func TestIndexMatchesLinearScan(t *testing.T) {
+	markers := randomMarkers(10000)
+	index := NewIndex()
+	index.Load(markers)
+
+	boxes := []BoundingBox{
+		benchmarkBox,
+		{South: 20, West: -130, North: 50, East: -60},
+		{South: 40, West: 170, North: 45, East: -80},
+	}
+	for _, box := range boxes {
+		expected, actual := markerIDs(linearInBox(markers, box)), markerIDs(index.InBox(box))
+		if len(expected) != len(actual) {
+			t.Fatalf("InBox(%v) found %v markers, expected %v", box, len(actual), len(expected))
+		}
+		for i := range expected {
+			if expected[i] != actual[i] {
+				t.Fatalf("InBox(%v) found marker %v, expected %v", box, actual[i], expected[i])
+			}
+		}
+	}
+
+	expected, actual := linearWithinRadius(markers, benchmarkCenter, 10), index.WithinRadius(benchmarkCenter, 10)
+	if len(expected) != len(actual) {
+		t.Fatalf("WithinRadius found %v markers, expected %v", len(actual), len(expected))
+	}
+	for i := range expected {
+		if expected[i].Distance != actual[i].Distance {
+			t.Fatalf("WithinRadius found %v at %v, expected %v", actual[i].ID, actual[i].Distance, expected[i].Distance)
+		}
+	}
+}
+
+func TestInBoxStaysOnTheGrid(t *testing.T) {
+	index := NewIndex()
+	index.Load(randomMarkers(1000))
+	nan, inf := math.NaN(), math.Inf(1)
+	boxes := []BoundingBox{
+		{South: nan, West: -88, North: 42, East: -87},
+		{South: 41, West: -88, North: inf, East: -87},
+		{South: -inf, West: -inf, North: inf, East: inf},
+		{South: -1e300, West: -1e300, North: 1e300, East: 1e300},
+	}
+	// each box would walk more cells than there are if it was not clamped.
+	for _, box := range boxes {
+		index.InBox(box)
+	}
+}
+
+func TestValidateRejectsNonFiniteCoordinates(t *testing.T) {
+	nan, inf := math.NaN(), math.Inf(1)
+	for _, p := range []Point{{nan, 0}, {0, nan}, {inf, 0}, {0, -inf}} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("%v should not be a valid point", p)
+		}
+	}
+	if err := (BoundingBox{South: nan, West: -88, North: 42, East: -87}).Validate(); err == nil {
+		t.Error("A box with a NaN side should not be valid")
+	}
+}
+
+func BenchmarkIndexInBox(b *testing.B) {
+	index := NewIndex()
+	index.Load(randomMarkers(benchmarkMarkers))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		index.InBox(benchmarkBox)
+	}
+}
+
+func BenchmarkLinearInBox(b *testing.B) {
+	markers := randomMarkers(benchmarkMarkers)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		linearInBox(markers, benchmarkBox)
+	}
+}
+
+func BenchmarkIndexWithinRadius(b *testing.B) {
+	index := NewIndex()
+	index.Load(randomMarkers(benchmarkMarkers))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		index.WithinRadius(benchmarkCenter, 10)
+	}
+}
+
+func BenchmarkLinearWithinRadius(b *testing.B) {
+	markers := randomMarkers(benchmarkMarkers)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		linearWithinRadius(markers, benchmarkCenter, 10)
+	}
+}
+
+type int64Sorter []int64
+
+func (l int64Sorter) Len() int           { return len(l) }
+func (l int64Sorter) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l int64Sorter) Less(i, j int) bool { return l[i] < l[j] }
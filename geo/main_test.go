@@ -0,0 +1,14 @@
+package geo
+
+import (
+	"os"
+	"testing"
+
+	"github.com/rchargel/localiday/app"
+)
+
+func TestMain(m *testing.M) {
+	// the configuration is read from the project directory.
+	app.SetFiles(os.DirFS(".."), "")
+	os.Exit(m.Run())
+}
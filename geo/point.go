@@ -0,0 +1,35 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+)
+
+// Point a location on the earth in decimal degrees.
+type Point struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Validate checks that the point's coordinates are numbers in range.
+func (p Point) Validate() error {
+	if !isFinite(p.Latitude) || !isFinite(p.Longitude) {
+		return fmt.Errorf("Point %v is not a valid location.", p)
+	}
+	if p.Latitude < -90 || p.Latitude > 90 {
+		return fmt.Errorf("Latitude %v is out of range.", p.Latitude)
+	}
+	if p.Longitude < -180 || p.Longitude > 180 {
+		return fmt.Errorf("Longitude %v is out of range.", p.Longitude)
+	}
+	return nil
+}
+
+func isFinite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+// String prints out a string representation of the point.
+func (p Point) String() string {
+	return fmt.Sprintf("%.6f,%.6f", p.Latitude, p.Longitude)
+}
@@ -0,0 +1,118 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Zenith angles, in degrees, of the sun for the solar events.
+const (
+	sunsetZenith    = 90.833
+	civilDuskZenith = 96.0
+
+	julianUnixEpoch = 2440587.5
+	julianJ2000     = 2451545.0
+)
+
+// Sunrise calculates the time of sunrise at the point on the calendar date of
+// the given time. The result is in UTC.
+func Sunrise(p Point, date time.Time) (time.Time, error) {
+	return solarEvent(p, date, sunsetZenith, true)
+}
+
+// Sunset calculates the time of sunset at the point on the calendar date of
+// the given time. The result is in UTC.
+func Sunset(p Point, date time.Time) (time.Time, error) {
+	return solarEvent(p, date, sunsetZenith, false)
+}
+
+// CivilDusk calculates the end of civil twilight, when the sun is six degrees
+// below the horizon, at the point on the calendar date of the given time. The
+// result is in UTC.
+func CivilDusk(p Point, date time.Time) (time.Time, error) {
+	return solarEvent(p, date, civilDuskZenith, false)
+}
+
+// solarEvent uses the NOAA solar position equations to find the moment in the
+// morning, if rising, or the evening when the sun reaches the given zenith.
+// The estimate is refined once using the sun's position at the first
+// estimate, which is accurate to about a minute outside of the polar regions.
+func solarEvent(p Point, date time.Time, zenith float64, rising bool) (time.Time, error) {
+	if err := p.Validate(); err != nil {
+		return time.Time{}, err
+	}
+	y, m, d := date.Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+
+	// start from local solar noon, then refine at the estimated event.
+	minutes := 720 - 4*p.Longitude
+	for i := 0; i < 2; i++ {
+		t := midnight.Add(time.Duration(minutes * float64(time.Minute)))
+		eqTime, declination := solarPosition(julianCentury(t))
+		hourAngle, err := hourAngle(p.Latitude, declination, zenith)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("No solar event at %v on %v: %v", p, midnight.Format("2006-01-02"), err)
+		}
+		if rising {
+			hourAngle = -hourAngle
+		}
+		minutes = 720 - 4*(p.Longitude-hourAngle) - eqTime
+	}
+	return midnight.Add(time.Duration(minutes * float64(time.Minute))).Round(time.Second), nil
+}
+
+func julianCentury(t time.Time) float64 {
+	jd := float64(t.Unix())/86400 + julianUnixEpoch
+	return (jd - julianJ2000) / 36525
+}
+
+// solarPosition gets the equation of time, in minutes, and the sun's
+// declination, in degrees.
+func solarPosition(t float64) (float64, float64) {
+	meanLong := math.Mod(280.46646+t*(36000.76983+t*0.0003032), 360)
+	meanAnomaly := 357.52911 + t*(35999.05029-0.0001537*t)
+	eccentricity := 0.016708634 - t*(0.000042037+0.0000001267*t)
+
+	ma := radians(meanAnomaly)
+	center := math.Sin(ma)*(1.914602-t*(0.004817+0.000014*t)) +
+		math.Sin(2*ma)*(0.019993-0.000101*t) +
+		math.Sin(3*ma)*0.000289
+	omega := radians(125.04 - 1934.136*t)
+	apparentLong := meanLong + center - 0.00569 - 0.00478*math.Sin(omega)
+
+	meanObliquity := 23 + (26+(21.448-t*(46.815+t*(0.00059-t*0.001813)))/60)/60
+	obliquity := radians(meanObliquity + 0.00256*math.Cos(omega))
+	declination := degrees(math.Asin(math.Sin(obliquity) * math.Sin(radians(apparentLong))))
+
+	yy := math.Pow(math.Tan(obliquity/2), 2)
+	l0 := radians(meanLong)
+	eqTime := yy*math.Sin(2*l0) -
+		2*eccentricity*math.Sin(ma) +
+		4*eccentricity*yy*math.Sin(ma)*math.Cos(2*l0) -
+		0.5*yy*yy*math.Sin(4*l0) -
+		1.25*eccentricity*eccentricity*math.Sin(2*ma)
+
+	return 4 * degrees(eqTime), declination
+}
+
+func hourAngle(latitude, declination, zenith float64) (float64, error) {
+	lat := radians(latitude)
+	decl := radians(declination)
+	cos := math.Cos(radians(zenith))/(math.Cos(lat)*math.Cos(decl)) - math.Tan(lat)*math.Tan(decl)
+	if cos > 1 {
+		return 0, fmt.Errorf("the sun stays below %v degrees all day", zenith)
+	}
+	if cos < -1 {
+		return 0, fmt.Errorf("the sun stays above %v degrees all day", zenith)
+	}
+	return degrees(math.Acos(cos)), nil
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
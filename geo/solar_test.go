@@ -0,0 +1,99 @@
+package geo
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// almanacTolerance how far the calculated times may be from the almanac,
+// which gives them to the minute.
+const almanacTolerance = 2 * time.Minute
+
+// Sunrise and sunset in local time from the NOAA solar calculator.
+var almanac = []struct {
+	place   string
+	point   Point
+	zone    string
+	date    string
+	sunrise string
+	sunset  string
+}{
+	{"London, summer solstice", Point{51.5074, -0.1278}, "Europe/London", "2024-06-21", "04:43", "21:21"},
+	{"London, winter solstice", Point{51.5074, -0.1278}, "Europe/London", "2024-12-21", "08:04", "15:54"},
+	{"New York, winter solstice", Point{40.7128, -74.006}, "America/New_York", "2024-12-21", "07:17", "16:32"},
+	{"Chicago, Christmas", Point{41.8781, -87.6298}, "America/Chicago", "2024-12-25", "07:18", "16:25"},
+	{"Sydney, summer solstice", Point{-33.8688, 151.2093}, "Australia/Sydney", "2024-12-21", "05:41", "20:05"},
+	{"Quito, equinox", Point{-0.1807, -78.4678}, "America/Guayaquil", "2024-03-20", "06:16", "18:23"},
+}
+
+func TestSunriseAndSunset(t *testing.T) {
+	for _, a := range almanac {
+		loc, err := time.LoadLocation(a.zone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		date, _ := time.ParseInLocation("2006-01-02", a.date, loc)
+		rise, err := Sunrise(a.point, date)
+		if err != nil {
+			t.Errorf("%v: no sunrise: %v", a.place, err)
+			continue
+		}
+		set, err := Sunset(a.point, date)
+		if err != nil {
+			t.Errorf("%v: no sunset: %v", a.place, err)
+			continue
+		}
+		checkAlmanac(t, a.place+" sunrise", rise.In(loc), a.date+" "+a.sunrise, loc)
+		checkAlmanac(t, a.place+" sunset", set.In(loc), a.date+" "+a.sunset, loc)
+	}
+}
+
+func TestCivilDuskFollowsSunset(t *testing.T) {
+	chicago := Point{41.8781, -87.6298}
+	date := time.Date(2024, time.December, 25, 12, 0, 0, 0, time.UTC)
+	set, _ := Sunset(chicago, date)
+	dusk, err := CivilDusk(chicago, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// civil twilight lasts about half an hour at this latitude in winter.
+	if d := dusk.Sub(set); d < 25*time.Minute || d > 35*time.Minute {
+		t.Errorf("Civil dusk is %v after sunset, expected about 30m", d)
+	}
+}
+
+func TestPolarDayAndNight(t *testing.T) {
+	tromso := Point{69.6492, 18.9553}
+	tests := []struct {
+		name  string
+		date  time.Time
+		stays string
+	}{
+		{"polar day", time.Date(2024, time.June, 21, 12, 0, 0, 0, time.UTC), "above"},
+		{"polar night", time.Date(2024, time.December, 21, 12, 0, 0, 0, time.UTC), "below"},
+	}
+	for _, test := range tests {
+		if _, err := Sunrise(tromso, test.date); err == nil || !strings.Contains(err.Error(), test.stays) {
+			t.Errorf("%v: expected no sunrise as the sun stays %v the horizon, got %v", test.name, test.stays, err)
+		}
+		if _, err := Sunset(tromso, test.date); err == nil || !strings.Contains(err.Error(), test.stays) {
+			t.Errorf("%v: expected no sunset as the sun stays %v the horizon, got %v", test.name, test.stays, err)
+		}
+	}
+
+	// the sun still rises and sets at the end of the polar night.
+	if _, err := Sunset(tromso, time.Date(2024, time.January, 20, 12, 0, 0, 0, time.UTC)); err != nil {
+		t.Errorf("Expected a sunset in Tromso on January 20th: %v", err)
+	}
+}
+
+func checkAlmanac(t *testing.T, event string, actual time.Time, expected string, loc *time.Location) {
+	want, err := time.ParseInLocation("2006-01-02 15:04", expected, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := actual.Sub(want); d < -almanacTolerance || d > almanacTolerance {
+		t.Errorf("%v at %v, expected %v", event, actual.Format("15:04:05"), want.Format("15:04"))
+	}
+}
@@ -0,0 +1,151 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// TileSize the width and height of a map tile in pixels.
+	TileSize = 256
+	// MaxZoom the deepest zoom level supported by the map.
+	MaxZoom = 21
+
+	maxMercatorLatitude = 85.05112878
+)
+
+// BoundingBox an area of the map given by its south west and north east
+// corners. A box whose west edge is east of its east edge crosses the
+// antimeridian.
+type BoundingBox struct {
+	South float64
+	West  float64
+	North float64
+	East  float64
+}
+
+// Tile a web mercator map tile.
+type Tile struct {
+	Z int
+	X int
+	Y int
+}
+
+// Contains checks to see if the point is inside the box.
+func (b BoundingBox) Contains(p Point) bool {
+	if p.Latitude < b.South || p.Latitude > b.North {
+		return false
+	}
+	if b.West <= b.East {
+		return p.Longitude >= b.West && p.Longitude <= b.East
+	}
+	return p.Longitude >= b.West || p.Longitude <= b.East
+}
+
+// Extend grows the box to include the point.
+func (b BoundingBox) Extend(p Point) BoundingBox {
+	return BoundingBox{
+		South: math.Min(b.South, p.Latitude),
+		West:  math.Min(b.West, p.Longitude),
+		North: math.Max(b.North, p.Latitude),
+		East:  math.Max(b.East, p.Longitude),
+	}
+}
+
+// Validate checks that the box's coordinates are in range.
+func (b BoundingBox) Validate() error {
+	if err := (Point{b.South, b.West}).Validate(); err != nil {
+		return err
+	}
+	if err := (Point{b.North, b.East}).Validate(); err != nil {
+		return err
+	}
+	if b.South > b.North {
+		return fmt.Errorf("South %v is north of %v.", b.South, b.North)
+	}
+	return nil
+}
+
+// TileAt gets the tile containing the point at the given zoom level.
+func TileAt(p Point, zoom int) Tile {
+	x, y := pixel(p, zoom)
+	n := 1 << uint(zoom)
+	return Tile{zoom, clamp(int(x/TileSize), n-1), clamp(int(y/TileSize), n-1)}
+}
+
+// TilesIn gets the tiles covering the box at the given zoom level. An error
+// is returned if the box covers more than max tiles.
+func TilesIn(b BoundingBox, zoom, max int) ([]Tile, error) {
+	nw := TileAt(Point{b.North, b.West}, zoom)
+	se := TileAt(Point{b.South, b.East}, zoom)
+	n := 1 << uint(zoom)
+	cols := se.X - nw.X + 1
+	if cols <= 0 {
+		cols += n
+	}
+	rows := se.Y - nw.Y + 1
+	if cols > max || rows > max || cols*rows > max {
+		return nil, fmt.Errorf("The box covers more than %v tiles at zoom %v.", max, zoom)
+	}
+	tiles := make([]Tile, 0, cols*rows)
+	for i := 0; i < cols; i++ {
+		for y := nw.Y; y <= se.Y; y++ {
+			tiles = append(tiles, Tile{zoom, (nw.X + i) % n, y})
+		}
+	}
+	return tiles, nil
+}
+
+// Validate checks that the tile exists.
+func (t Tile) Validate() error {
+	n := 1 << uint(t.Z)
+	if t.Z < 0 || t.Z > MaxZoom || t.X < 0 || t.X >= n || t.Y < 0 || t.Y >= n {
+		return fmt.Errorf("Tile %v is not a valid tile.", t)
+	}
+	return nil
+}
+
+// Bounds gets the area covered by the tile.
+func (t Tile) Bounds() BoundingBox {
+	nw := tileCorner(t.X, t.Y, t.Z)
+	se := tileCorner(t.X+1, t.Y+1, t.Z)
+	return BoundingBox{South: se.Latitude, West: nw.Longitude, North: nw.Latitude, East: se.Longitude}
+}
+
+// Pixel gets the position of the point within the tile in pixels.
+func (t Tile) Pixel(p Point) (float64, float64) {
+	x, y := pixel(p, t.Z)
+	return x - float64(t.X*TileSize), y - float64(t.Y*TileSize)
+}
+
+// String prints out the tile as z/x/y.
+func (t Tile) String() string {
+	return fmt.Sprintf("%v/%v/%v", t.Z, t.X, t.Y)
+}
+
+// pixel gets the world pixel coordinates of the point at the zoom level.
+func pixel(p Point, zoom int) (float64, float64) {
+	lat := math.Max(-maxMercatorLatitude, math.Min(maxMercatorLatitude, p.Latitude))
+	scale := float64(TileSize) * math.Exp2(float64(zoom))
+	x := (p.Longitude + 180) / 360 * scale
+	s := math.Sin(radians(lat))
+	y := (0.5 - math.Log((1+s)/(1-s))/(4*math.Pi)) * scale
+	return x, y
+}
+
+func tileCorner(x, y, zoom int) Point {
+	n := math.Exp2(float64(zoom))
+	lon := float64(x)/n*360 - 180
+	lat := degrees(math.Atan(math.Sinh(math.Pi * (1 - 2*float64(y)/n))))
+	return Point{lat, lon}
+}
+
+func clamp(v, max int) int {
+	if v < 0 {
+		return 0
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
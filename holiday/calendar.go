@@ -0,0 +1,133 @@
+package holiday
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// hebrewEpochOffset the offset between hebrew elapsed days and days since
+	// January 1st of the year 1 in the proleptic gregorian calendar.
+	hebrewEpochOffset = -1373428
+	// unixEpochOrdinal the day number of January 1st 1970 counting January 1st
+	// of the year 1 as day one.
+	unixEpochOrdinal = 719163
+)
+
+// Rule tables for holidays which follow the hindu and chinese lunisolar
+// calendars. These depend on observed astronomy and are published ahead of
+// time, so they are listed here rather than computed.
+var (
+	diwaliDates = map[int]monthDay{
+		2020: {time.November, 14}, 2021: {time.November, 4}, 2022: {time.October, 24},
+		2023: {time.November, 12}, 2024: {time.October, 31}, 2025: {time.October, 20},
+		2026: {time.November, 8}, 2027: {time.October, 29}, 2028: {time.October, 17},
+		2029: {time.November, 5}, 2030: {time.October, 26},
+	}
+	lunarNewYearDates = map[int]monthDay{
+		2020: {time.January, 25}, 2021: {time.February, 12}, 2022: {time.February, 1},
+		2023: {time.January, 22}, 2024: {time.February, 10}, 2025: {time.January, 29},
+		2026: {time.February, 17}, 2027: {time.February, 6}, 2028: {time.January, 26},
+		2029: {time.February, 13}, 2030: {time.February, 3}, 2031: {time.January, 23},
+		2032: {time.February, 11}, 2033: {time.January, 31}, 2034: {time.February, 19},
+		2035: {time.February, 8},
+	}
+)
+
+type monthDay struct {
+	month time.Month
+	day   int
+}
+
+// rule calculates the date of a holiday in the given year.
+type rule func(year int) (time.Time, error)
+
+func fixed(month time.Month, day int) rule {
+	return func(year int) (time.Time, error) {
+		return date(year, month, day), nil
+	}
+}
+
+// nthWeekday the nth occurrence of a weekday in the month, for example the
+// fourth thursday of november.
+func nthWeekday(month time.Month, weekday time.Weekday, n int) rule {
+	return func(year int) (time.Time, error) {
+		first := date(year, month, 1)
+		offset := (int(weekday) - int(first.Weekday()) + 7) % 7
+		return first.AddDate(0, 0, offset+7*(n-1)), nil
+	}
+}
+
+func table(name string, dates map[int]monthDay) rule {
+	return func(year int) (time.Time, error) {
+		if md, found := dates[year]; found {
+			return date(year, md.month, md.day), nil
+		}
+		return time.Time{}, fmt.Errorf("The date of %v is not known for %v.", name, year)
+	}
+}
+
+// easter calculates western easter sunday with the anonymous gregorian
+// computus.
+func easter(year int) (time.Time, error) {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return date(year, time.Month(month), day), nil
+}
+
+// hanukkah calculates the first full day of hanukkah, the 25th of kislev. The
+// first candle is lit on the evening before.
+func hanukkah(year int) (time.Time, error) {
+	hebrewYear := year + 3761
+	roshHashanah := hebrewElapsedDays(hebrewYear)
+	cheshvan := 29
+	if (hebrewElapsedDays(hebrewYear+1)-roshHashanah)%10 == 5 {
+		cheshvan = 30
+	}
+	return fromOrdinal(roshHashanah + hebrewEpochOffset + 30 + cheshvan + 24), nil
+}
+
+// hebrewElapsedDays the number of days from the hebrew epoch to rosh hashanah
+// of the given year, including the postponement rules.
+func hebrewElapsedDays(year int) int {
+	y := year - 1
+	months := 235*(y/19) + 12*(y%19) + (7*(y%19)+1)/19
+	parts := 204 + 793*(months%1080)
+	hours := 5 + 12*months + 793*(months/1080) + parts/1080
+	day := 1 + 29*months + hours/24
+	parts = 1080*(hours%24) + parts%1080
+
+	if parts >= 19440 ||
+		(day%7 == 2 && parts >= 9924 && !isHebrewLeapYear(year)) ||
+		(day%7 == 1 && parts >= 16789 && isHebrewLeapYear(year-1)) {
+		day++
+	}
+	if day%7 == 0 || day%7 == 3 || day%7 == 5 {
+		day++
+	}
+	return day
+}
+
+func isHebrewLeapYear(year int) bool {
+	return (7*year+1)%19 < 7
+}
+
+func fromOrdinal(ordinal int) time.Time {
+	return date(1970, time.January, 1).AddDate(0, 0, ordinal-unixEpochOrdinal)
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
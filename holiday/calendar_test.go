@@ -0,0 +1,88 @@
+package holiday
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDates(t *testing.T) {
+	tests := []struct {
+		key      string
+		year     int
+		expected string
+	}{
+		{Easter, 2000, "2000-04-23"},
+		{Easter, 2008, "2008-03-23"},
+		{Easter, 2011, "2011-04-24"},
+		{Easter, 2024, "2024-03-31"},
+		{Easter, 2025, "2025-04-20"},
+		{Easter, 2026, "2026-04-05"},
+		{Easter, 2038, "2038-04-25"},
+		// the first full day, the first candle is lit on the evening before.
+		{Hanukkah, 2022, "2022-12-19"},
+		{Hanukkah, 2023, "2023-12-08"},
+		{Hanukkah, 2024, "2024-12-26"},
+		{Hanukkah, 2025, "2025-12-15"},
+		{Hanukkah, 2026, "2026-12-05"},
+		{Hanukkah, 2027, "2027-12-25"},
+		{Thanksgiving, 2024, "2024-11-28"},
+		{Thanksgiving, 2025, "2025-11-27"},
+		{Thanksgiving, 2026, "2026-11-26"},
+		// november starts on a thursday.
+		{Thanksgiving, 2029, "2029-11-22"},
+		{Diwali, 2026, "2026-11-08"},
+		{LunarNewYear, 2026, "2026-02-17"},
+	}
+	for _, test := range tests {
+		h, err := Get(test.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := h.Date(test.year)
+		if err != nil || d.Format("2006-01-02") != test.expected {
+			t.Errorf("%v in %v is %v, %v, expected %v", test.key, test.year, d.Format("2006-01-02"), err, test.expected)
+		}
+	}
+}
+
+func TestNthWeekday(t *testing.T) {
+	tests := []struct {
+		month    time.Month
+		weekday  time.Weekday
+		n        int
+		year     int
+		expected string
+	}{
+		{time.November, time.Thursday, 4, 2026, "2026-11-26"},
+		{time.November, time.Sunday, 1, 2026, "2026-11-01"},
+		{time.May, time.Monday, 2, 2026, "2026-05-11"},
+		{time.September, time.Monday, 1, 2025, "2025-09-01"},
+	}
+	for _, test := range tests {
+		d, _ := nthWeekday(test.month, test.weekday, test.n)(test.year)
+		if d.Format("2006-01-02") != test.expected {
+			t.Errorf("%v %v of %v %v is %v, expected %v", test.n, test.weekday, test.month, test.year, d.Format("2006-01-02"), test.expected)
+		}
+	}
+}
+
+func TestDatesOutsideTheTables(t *testing.T) {
+	tests := []struct {
+		key  string
+		year int
+	}{
+		{Diwali, 2019},
+		{Diwali, 2031},
+		{LunarNewYear, 2019},
+		{LunarNewYear, 2036},
+	}
+	for _, test := range tests {
+		h, _ := Get(test.key)
+		if _, err := h.Date(test.year); err == nil {
+			t.Errorf("%v in %v should not be known", test.key, test.year)
+		}
+		if _, err := h.Season(test.year); err == nil {
+			t.Errorf("The %v season in %v should not be known", test.key, test.year)
+		}
+	}
+}
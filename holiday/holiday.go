@@ -0,0 +1,151 @@
+package holiday
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Defines the set of holidays displays may be assigned to.
+const (
+	NewYear         = "NEW_YEAR"
+	LunarNewYear    = "LUNAR_NEW_YEAR"
+	Valentines      = "VALENTINES"
+	StPatricks      = "ST_PATRICKS"
+	Easter          = "EASTER"
+	IndependenceDay = "INDEPENDENCE_DAY"
+	Halloween       = "HALLOWEEN"
+	Diwali          = "DIWALI"
+	Thanksgiving    = "THANKSGIVING"
+	Hanukkah        = "HANUKKAH"
+	Christmas       = "CHRISTMAS"
+)
+
+// Holiday a holiday which people put up displays for. The display season runs
+// from SeasonBefore days before the holiday until SeasonAfter days after its
+// last day.
+type Holiday struct {
+	Key          string
+	Name         string
+	Days         int
+	SeasonBefore int
+	SeasonAfter  int
+	rule         rule
+}
+
+// Season the dates of a holiday and its display season in a given year.
+type Season struct {
+	Key         string
+	Name        string
+	Year        int
+	Date        time.Time
+	SeasonStart time.Time
+	SeasonEnd   time.Time
+}
+
+var holidays = []Holiday{
+	{NewYear, "New Year", 1, 3, 0, fixed(time.January, 1)},
+	{LunarNewYear, "Lunar New Year", 15, 10, 0, table("Lunar New Year", lunarNewYearDates)},
+	{Valentines, "Valentine's Day", 1, 14, 0, fixed(time.February, 14)},
+	{StPatricks, "St. Patrick's Day", 1, 7, 0, fixed(time.March, 17)},
+	{Easter, "Easter", 1, 14, 1, easter},
+	{IndependenceDay, "Independence Day", 1, 3, 0, fixed(time.July, 4)},
+	{Halloween, "Halloween", 1, 30, 1, fixed(time.October, 31)},
+	{Diwali, "Diwali", 5, 7, 2, table("Diwali", diwaliDates)},
+	{Thanksgiving, "Thanksgiving", 1, 7, 1, nthWeekday(time.November, time.Thursday, 4)},
+	{Hanukkah, "Hanukkah", 8, 7, 1, hanukkah},
+	{Christmas, "Christmas", 1, 35, 12, fixed(time.December, 25)},
+}
+
+// All gets every holiday in the order they fall in the year.
+func All() []Holiday {
+	all := make([]Holiday, len(holidays))
+	copy(all, holidays)
+	return all
+}
+
+// Get gets a holiday by its key.
+func Get(key string) (Holiday, error) {
+	for _, h := range holidays {
+		if h.Key == key {
+			return h, nil
+		}
+	}
+	return Holiday{}, fmt.Errorf("%v is not a valid holiday.", key)
+}
+
+// Date calculates the date of the holiday in the given year. For holidays
+// lasting several days, this is the first day.
+func (h Holiday) Date(year int) (time.Time, error) {
+	return h.rule(year)
+}
+
+// Season calculates the dates of the holiday's display season in the given
+// year.
+func (h Holiday) Season(year int) (Season, error) {
+	d, err := h.Date(year)
+	if err != nil {
+		return Season{}, err
+	}
+	return Season{
+		Key:         h.Key,
+		Name:        h.Name,
+		Year:        year,
+		Date:        d,
+		SeasonStart: d.AddDate(0, 0, -h.SeasonBefore),
+		SeasonEnd:   d.AddDate(0, 0, h.Days-1+h.SeasonAfter),
+	}, nil
+}
+
+// Calendar gets the seasons of every holiday in the given year, ordered by
+// the date of the holiday. Holidays whose date is not known for the year are
+// left out.
+func Calendar(year int) []Season {
+	seasons := make([]Season, 0, len(holidays))
+	for _, h := range holidays {
+		if s, err := h.Season(year); err == nil {
+			seasons = append(seasons, s)
+		}
+	}
+	sort.Sort(seasonSorter(seasons))
+	return seasons
+}
+
+// ActiveOn gets the holiday seasons which are running on the calendar date of
+// the given time.
+func ActiveOn(t time.Time) []Season {
+	y, m, d := t.Date()
+	day := date(y, m, d)
+	active := make([]Season, 0, 2)
+	// seasons may start in the year before or run into the year after.
+	for year := y - 1; year <= y+1; year++ {
+		for _, s := range Calendar(year) {
+			if s.IsActiveOn(day) {
+				active = append(active, s)
+			}
+		}
+	}
+	return active
+}
+
+// ActiveKeys gets the keys of the holiday seasons running on the calendar
+// date of the given time, for use as a default search filter.
+func ActiveKeys(t time.Time) []string {
+	active := ActiveOn(t)
+	keys := make([]string, len(active))
+	for i, s := range active {
+		keys[i] = s.Key
+	}
+	return keys
+}
+
+// IsActiveOn checks to see if the season is running on the given date.
+func (s Season) IsActiveOn(day time.Time) bool {
+	return !day.Before(s.SeasonStart) && !day.After(s.SeasonEnd)
+}
+
+type seasonSorter []Season
+
+func (l seasonSorter) Len() int           { return len(l) }
+func (l seasonSorter) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l seasonSorter) Less(i, j int) bool { return l[i].Date.Before(l[j].Date) }
@@ -0,0 +1,272 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rchargel/localiday/geo"
+)
+
+// Events a time of day may be relative to.
+const (
+	Clock     = ""
+	Sunset    = "SUNSET"
+	CivilDusk = "CIVIL_DUSK"
+)
+
+// Schedule describes when a display is lit: a yearly season, the nightly
+// on/off times and any per-weekday exceptions, all in the display's time zone.
+// The position is used to work out sunset for times relative to the sun.
+type Schedule struct {
+	TimeZone    string
+	Position    geo.Point
+	SeasonStart MonthDay
+	SeasonEnd   MonthDay
+	Nightly     Hours
+	Exceptions  map[time.Weekday]Hours
+}
+
+// MonthDay a day of the year which recurs every year.
+type MonthDay struct {
+	Month time.Month
+	Day   int
+}
+
+// TimeOfDay a wall clock time in the schedule's time zone, or a time relative
+// to a solar event, such as 15 minutes after sunset.
+type TimeOfDay struct {
+	Hour   int
+	Minute int
+	Event  string
+	Offset time.Duration
+}
+
+// Hours the times a display turns on and off on a given night. An off time
+// earlier than the on time means the display stays lit past midnight.
+type Hours struct {
+	On     TimeOfDay
+	Off    TimeOfDay
+	Closed bool
+}
+
+// Validate checks that the schedule can be evaluated.
+func (s *Schedule) Validate() error {
+	if _, err := s.Location(); err != nil {
+		return err
+	}
+	if err := s.SeasonStart.validate(); err != nil {
+		return err
+	}
+	if err := s.SeasonEnd.validate(); err != nil {
+		return err
+	}
+	if err := s.Position.Validate(); err != nil {
+		return err
+	}
+	if err := s.Nightly.validate(); err != nil {
+		return err
+	}
+	for day, hours := range s.Exceptions {
+		if err := hours.validate(); err != nil {
+			return fmt.Errorf("Invalid hours for %v: %v", day, err)
+		}
+	}
+	return nil
+}
+
+// IsLitNow checks to see if the display is lit right now.
+func (s *Schedule) IsLitNow() (bool, error) {
+	return s.IsLitAt(time.Now())
+}
+
+// IsLitAt checks to see if the display is lit at the given instant. The
+// instant is converted into the schedule's time zone, so callers may pass
+// times in any location.
+func (s *Schedule) IsLitAt(t time.Time) (bool, error) {
+	loc, err := s.Location()
+	if err != nil {
+		return false, err
+	}
+	local := t.In(loc)
+	// a night which started yesterday may still be running this morning.
+	for _, offset := range []int{0, -1} {
+		y, m, d := local.Date()
+		night := time.Date(y, m, d+offset, 12, 0, 0, 0, loc)
+		if on, off, lit := s.window(night); lit && !local.Before(on) && local.Before(off) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Window gets the instants the display turns on and off on the night which
+// starts on the given date. If the display is dark that night, false is returned.
+func (s *Schedule) Window(date time.Time) (time.Time, time.Time, bool) {
+	loc, err := s.Location()
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	y, m, d := date.In(loc).Date()
+	return s.window(time.Date(y, m, d, 12, 0, 0, 0, loc))
+}
+
+func (s *Schedule) window(night time.Time) (time.Time, time.Time, bool) {
+	if !s.InSeason(night) {
+		return time.Time{}, time.Time{}, false
+	}
+	hours := s.HoursFor(night.Weekday())
+	if hours.Closed {
+		return time.Time{}, time.Time{}, false
+	}
+	on, err := s.resolve(hours.On, night)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	off, err := s.resolve(hours.Off, night)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	if !off.After(on) {
+		// only a morning clock time runs past midnight, otherwise the sun set
+		// too late for the display to come on before it is turned off.
+		if hours.Off.Event != Clock || hours.Off.Hour >= 12 {
+			return time.Time{}, time.Time{}, false
+		}
+		next := time.Date(night.Year(), night.Month(), night.Day()+1, 12, 0, 0, 0, night.Location())
+		if off, err = s.resolve(hours.Off, next); err != nil {
+			return time.Time{}, time.Time{}, false
+		}
+	}
+	return on, off, true
+}
+
+// resolve works out the instant for the time of day on the given date.
+func (s *Schedule) resolve(t TimeOfDay, date time.Time) (time.Time, error) {
+	var at time.Time
+	var err error
+	switch t.Event {
+	case Sunset:
+		at, err = geo.Sunset(s.Position, date)
+	case CivilDusk:
+		at, err = geo.CivilDusk(s.Position, date)
+	default:
+		y, m, d := date.Date()
+		at = time.Date(y, m, d, t.Hour, t.Minute, 0, 0, date.Location())
+	}
+	if err != nil {
+		return at, err
+	}
+	return at.Add(t.Offset).In(date.Location()), nil
+}
+
+// HoursFor gets the hours for a given weekday, taking exceptions into account.
+func (s *Schedule) HoursFor(day time.Weekday) Hours {
+	if hours, found := s.Exceptions[day]; found {
+		return hours
+	}
+	return s.Nightly
+}
+
+// InSeason checks to see if the date falls within the display's season. A
+// season may wrap around the new year, for example from November 25th to
+// January 6th.
+func (s *Schedule) InSeason(date time.Time) bool {
+	if s.SeasonStart.isZero() && s.SeasonEnd.isZero() {
+		return true
+	}
+	md := MonthDay{date.Month(), date.Day()}
+	if s.SeasonStart.after(s.SeasonEnd) {
+		return !md.before(s.SeasonStart) || !md.after(s.SeasonEnd)
+	}
+	return !md.before(s.SeasonStart) && !md.after(s.SeasonEnd)
+}
+
+// Location gets the time zone the schedule is evaluated in.
+func (s *Schedule) Location() (*time.Location, error) {
+	if len(s.TimeZone) == 0 {
+		return nil, errors.New("No time zone defined for schedule.")
+	}
+	loc, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid time zone %v: %v", s.TimeZone, err)
+	}
+	return loc, nil
+}
+
+func (h Hours) validate() error {
+	if h.Closed {
+		return nil
+	}
+	if err := h.On.validate(); err != nil {
+		return err
+	}
+	return h.Off.validate()
+}
+
+// String prints out a string representation of the time of day.
+func (t TimeOfDay) String() string {
+	if t.Event == Clock {
+		return fmt.Sprintf("%02d:%02d", t.Hour, t.Minute)
+	}
+	if t.Offset < 0 {
+		return fmt.Sprintf("%v - %v", t.Event, -t.Offset)
+	}
+	return fmt.Sprintf("%v + %v", t.Event, t.Offset)
+}
+
+func (t TimeOfDay) validate() error {
+	switch t.Event {
+	case Clock:
+	case Sunset, CivilDusk:
+		return nil
+	default:
+		return fmt.Errorf("Invalid event %v.", t.Event)
+	}
+	if t.Hour < 0 || t.Hour > 23 || t.Minute < 0 || t.Minute > 59 {
+		return fmt.Errorf("Invalid time of day %02d:%02d.", t.Hour, t.Minute)
+	}
+	return nil
+}
+
+func (md MonthDay) validate() error {
+	if md.isZero() {
+		return nil
+	}
+	if md.Month < time.January || md.Month > time.December || md.Day < 1 || md.Day > 31 {
+		return fmt.Errorf("Invalid season date %v %v.", md.Month, md.Day)
+	}
+	return nil
+}
+
+func (md MonthDay) isZero() bool {
+	return md.Month == 0 && md.Day == 0
+}
+
+func (md MonthDay) before(o MonthDay) bool {
+	return md.Month < o.Month || (md.Month == o.Month && md.Day < o.Day)
+}
+
+func (md MonthDay) after(o MonthDay) bool {
+	return o.before(md)
+}
+
+// Scheduled defines types which have a lighting schedule.
+type Scheduled interface {
+	LightingSchedule() *Schedule
+}
+
+// FilterLitAt filters the items down to those which are lit at the given
+// instant. Items without a schedule, or with a schedule that cannot be
+// evaluated, are treated as dark.
+func FilterLitAt(items []Scheduled, t time.Time) []Scheduled {
+	lit := make([]Scheduled, 0, len(items))
+	for _, item := range items {
+		if s := item.LightingSchedule(); s != nil {
+			if on, err := s.IsLitAt(t); err == nil && on {
+				lit = append(lit, item)
+			}
+		}
+	}
+	return lit
+}
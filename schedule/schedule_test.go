@@ -0,0 +1,105 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rchargel/localiday/geo"
+)
+
+var newYork = geo.Point{Latitude: 40.7128, Longitude: -74.006}
+
+// nightly a schedule in New York lit every night of the year.
+func nightly(on, off TimeOfDay) *Schedule {
+	return &Schedule{TimeZone: "America/New_York", Position: newYork, Nightly: Hours{On: on, Off: off}}
+}
+
+func clock(hour, minute int) TimeOfDay {
+	return TimeOfDay{Hour: hour, Minute: minute}
+}
+
+func TestIsLitAt(t *testing.T) {
+	pastMidnight := nightly(clock(18, 0), clock(1, 0))
+	// the clocks go forward at 2:00 on March 8th and back at 2:00 on November
+	// 1st 2026.
+	overnight := nightly(clock(20, 0), clock(3, 0))
+	// New York's sunset on December 21st 2024 is at 16:32.
+	afterSunset := nightly(TimeOfDay{Event: Sunset, Offset: 30 * time.Minute}, clock(22, 0))
+	tests := []struct {
+		name     string
+		schedule *Schedule
+		at       string
+		lit      bool
+	}{
+		{"before the window", pastMidnight, "2026-12-10T17:59:00-05:00", false},
+		{"before midnight", pastMidnight, "2026-12-10T23:30:00-05:00", true},
+		{"after midnight", pastMidnight, "2026-12-11T00:30:00-05:00", true},
+		{"after the window", pastMidnight, "2026-12-11T01:00:00-05:00", false},
+		{"given in another zone", pastMidnight, "2026-12-11T05:30:00Z", true},
+		{"spring forward, before the change", overnight, "2026-03-08T01:59:00-05:00", true},
+		{"spring forward, after the change", overnight, "2026-03-08T03:30:00-04:00", false},
+		{"spring forward, the last minute", overnight, "2026-03-08T02:59:00-04:00", true},
+		{"fall back, the first 1:30", overnight, "2026-11-01T01:30:00-04:00", true},
+		{"fall back, the second 1:30", overnight, "2026-11-01T01:30:00-05:00", true},
+		{"fall back, the last minute", overnight, "2026-11-01T02:59:00-05:00", true},
+		{"fall back, after the window", overnight, "2026-11-01T03:00:00-05:00", false},
+		{"before sunset", afterSunset, "2024-12-21T16:20:00-05:00", false},
+		{"just after sunset", afterSunset, "2024-12-21T16:50:00-05:00", false},
+		{"half an hour after sunset", afterSunset, "2024-12-21T17:10:00-05:00", true},
+		{"turned off", afterSunset, "2024-12-21T22:00:00-05:00", false},
+	}
+	for _, test := range tests {
+		at, err := time.Parse(time.RFC3339, test.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lit, err := test.schedule.IsLitAt(at); err != nil || lit != test.lit {
+			t.Errorf("%v: IsLitAt(%v) = %v, %v, expected %v", test.name, test.at, lit, err, test.lit)
+		}
+	}
+}
+
+func TestWindow(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	overnight := nightly(clock(20, 0), clock(3, 0))
+	tests := []struct {
+		name     string
+		schedule *Schedule
+		night    time.Time
+		on       string
+		length   time.Duration
+	}{
+		{"an ordinary night", overnight, time.Date(2026, 12, 10, 0, 0, 0, 0, loc), "20:00", 7 * time.Hour},
+		{"spring forward", overnight, time.Date(2026, 3, 7, 0, 0, 0, 0, loc), "20:00", 6 * time.Hour},
+		{"fall back", overnight, time.Date(2026, 10, 31, 0, 0, 0, 0, loc), "20:00", 8 * time.Hour},
+		{"past midnight", nightly(clock(18, 0), clock(1, 0)), time.Date(2026, 12, 10, 0, 0, 0, 0, loc), "18:00", 7 * time.Hour},
+		{"after sunset", nightly(TimeOfDay{Event: Sunset, Offset: 30 * time.Minute}, clock(22, 0)),
+			time.Date(2024, 12, 21, 0, 0, 0, 0, loc), "17:02", 4*time.Hour + 58*time.Minute},
+	}
+	for _, test := range tests {
+		on, off, lit := test.schedule.Window(test.night)
+		if !lit {
+			t.Errorf("%v: not lit", test.name)
+			continue
+		}
+		// sunset is only known to the minute.
+		expected, _ := time.ParseInLocation("2006-01-02 15:04", test.night.Format("2006-01-02 ")+test.on, loc)
+		if d := on.Sub(expected); d < -2*time.Minute || d > 2*time.Minute {
+			t.Errorf("%v: on at %v, expected %v", test.name, on, expected)
+		}
+		if d := off.Sub(on) - test.length; d < -2*time.Minute || d > 2*time.Minute {
+			t.Errorf("%v: lit for %v, expected %v", test.name, off.Sub(on), test.length)
+		}
+	}
+}
+
+func TestSunsetLaterThanTheOffTime(t *testing.T) {
+	// in June the sun sets in New York after 20:00, so the display never comes on.
+	s := nightly(TimeOfDay{Event: Sunset}, clock(20, 0))
+	if _, _, lit := s.Window(time.Date(2026, 6, 21, 12, 0, 0, 0, time.UTC)); lit {
+		t.Error("A display turned off before sunset should not be lit")
+	}
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rchargel/localiday/app"
+	"github.com/rchargel/localiday/db"
+	"github.com/rchargel/localiday/geo"
+)
+
+const (
+	// CheckInRadiusKm how close a device must be to a display to verify a
+	// check-in.
+	CheckInRadiusKm = 0.2
+	// nightLength check-ins within this long count as one night out, and a
+	// display may be checked in at only once in it.
+	nightLength = 12 * time.Hour
+)
+
+// DisplayLocator finds where a display is.
+type DisplayLocator func(displayID int64) (geo.Point, bool)
+
+// BadgeRule a badge and the rule for earning it, which is checked after each
+// of a user's check-ins.
+type BadgeRule struct {
+	Key         string
+	Name        string
+	Description string
+	Earned      func(checkIn *db.CheckIn) (bool, error) `json:"-"`
+}
+
+// badgeRules the rules of every badge, in the order they are checked.
+var badgeRules = struct {
+	sync.RWMutex
+	rules []BadgeRule
+}{rules: []BadgeRule{
+	{"NIGHT_OUT", "Night Out", "Visited 10 displays in one night.", func(c *db.CheckIn) (bool, error) {
+		return db.CountDisplaysVisitedSince(c.UserID, c.Created.Add(-nightLength)) >= 10, nil
+	}},
+	{"CHAMPIONS_TOUR", "Champions Tour", "Visited every winner of a contest.", func(c *db.CheckIn) (bool, error) {
+		return db.CountContestsWithWinnersVisited(c.UserID) > 0, nil
+	}},
+	{"HOLIDAY_HOPPER", "Holiday Hopper", "Visited displays for 3 holidays.", func(c *db.CheckIn) (bool, error) {
+		keys, err := db.FindHolidaysVisited(c.UserID)
+		return len(keys) >= 3, err
+	}},
+}}
+
+// RegisterBadgeRule adds a badge to those awarded for check-ins.
+func RegisterBadgeRule(rule BadgeRule) {
+	badgeRules.Lock()
+	badgeRules.rules = append(badgeRules.rules, rule)
+	badgeRules.Unlock()
+}
+
+// BadgeRules gets the rules of every badge.
+func BadgeRules() []BadgeRule {
+	badgeRules.RLock()
+	defer badgeRules.RUnlock()
+	return append([]BadgeRule{}, badgeRules.rules...)
+}
+
+// CheckInResult a check-in and the badges it earned.
+type CheckInResult struct {
+	CheckIn *db.CheckIn
+	Badges  []BadgeRule
+}
+
+// CheckInService defines a set of functions for checking in at displays and
+// awarding badges.
+type CheckInService struct {
+	repos  *db.Repositories
+	locate DisplayLocator
+}
+
+// NewCheckInService creates a pointer to the check-in service using the
+// repositories. Without a locator displays are located by reading them from
+// the database.
+func NewCheckInService(repos *db.Repositories, locate DisplayLocator) *CheckInService {
+	if locate == nil {
+		locate = locateDisplay
+	}
+	return &CheckInService{repos, locate}
+}
+
+// locateDisplay finds where a display is by reading it from the database.
+func locateDisplay(displayID int64) (geo.Point, bool) {
+	display, err := db.Display{}.Get(displayID)
+	if err != nil {
+		return geo.Point{}, false
+	}
+	return display.Point(), true
+}
+
+// CheckIn checks the user in at a display, recording the holidays the display
+// is put up for. If the device's location is given it must be within
+// CheckInRadiusKm of the display, and the check-in is verified. A display
+// which cannot be located is checked in at unverified. Badges earned by the
+// check-in are awarded before returning.
+func (s *CheckInService) CheckIn(userID, displayID int64, device *geo.Point) (*CheckInResult, error) {
+	display, err := db.Display{}.Get(displayID)
+	if err != nil {
+		return nil, err
+	}
+	if db.CountCheckInsSince(userID, displayID, time.Now().Add(-nightLength)) > 0 {
+		return nil, errors.New("You have already checked in at this display tonight.")
+	}
+	checkIn := &db.CheckIn{UserID: userID, DisplayID: displayID}
+	if p, found := s.locate(displayID); found {
+		checkIn.Located, checkIn.Latitude, checkIn.Longitude = true, p.Latitude, p.Longitude
+	}
+	if device != nil {
+		if err = device.Validate(); err != nil {
+			return nil, err
+		}
+		if checkIn.Located {
+			if geo.Distance(*device, geo.Point{Latitude: checkIn.Latitude, Longitude: checkIn.Longitude}) > CheckInRadiusKm {
+				return nil, errors.New("You are too far from this display to check in.")
+			}
+			checkIn.Verified = true
+		} else {
+			app.Log(app.Debug, "Display %v could not be located, the check-in by user %v is not verified.", displayID, userID)
+		}
+	}
+	if err = db.CreateCheckIn(checkIn, display.HolidayKeys()); err != nil {
+		return nil, err
+	}
+	badges, err := s.awardBadges(checkIn)
+	return &CheckInResult{checkIn, badges}, err
+}
+
+// awardBadges checks every badge the user does not have against the new
+// check-in, awarding and notifying them of the ones they have earned.
+func (s *CheckInService) awardBadges(checkIn *db.CheckIn) ([]BadgeRule, error) {
+	awarded := make([]BadgeRule, 0, 1)
+	for _, rule := range BadgeRules() {
+		if db.HasBadge(checkIn.UserID, rule.Key) {
+			continue
+		}
+		earned, err := rule.Earned(checkIn)
+		if err != nil {
+			return awarded, err
+		}
+		if !earned {
+			continue
+		}
+		if ok, err := db.AwardBadge(checkIn.UserID, rule.Key); err != nil {
+			return awarded, err
+		} else if !ok {
+			continue
+		}
+		app.Log(app.Debug, "Awarded badge %v to user %v.", rule.Key, checkIn.UserID)
+		awarded = append(awarded, rule)
+		if err = NewNotificationService(s.repos).Notify(checkIn.UserID, db.NotifyBadge, "You earned the "+rule.Name+" badge.",
+			rule.Description, db.ItemUser, checkIn.UserID); err != nil {
+			return awarded, err
+		}
+	}
+	return awarded, nil
+}
+
+// History gets a page of the user's check-ins, newest first.
+func (s *CheckInService) History(userID int64, offset, max int) ([]db.CheckIn, error) {
+	return db.FindCheckIns(userID, offset, max)
+}
+
+// VisitMap gets the user's located check-ins as places for a map export.
+func (s *CheckInService) VisitMap(userID int64) (*geo.Export, error) {
+	checkIns, err := db.FindLocatedCheckIns(userID)
+	if err != nil {
+		return nil, err
+	}
+	e := &geo.Export{Name: "Localiday visits", Places: make([]geo.Place, len(checkIns))}
+	for i, c := range checkIns {
+		e.Places[i] = geo.Place{
+			Name:        fmt.Sprintf("Display %v", c.DisplayID),
+			Description: "Visited " + c.Created.Format("Jan 2, 2006 3:04 PM"),
+			Point:       geo.Point{Latitude: c.Latitude, Longitude: c.Longitude},
+		}
+	}
+	return e, nil
+}
+
+// Badges gets the badges the user has earned.
+func (s *CheckInService) Badges(userID int64) ([]db.UserBadge, error) {
+	return db.FindBadges(userID)
+}
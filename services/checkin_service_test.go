@@ -0,0 +1,155 @@
+//go:build sqlite
+// +build sqlite
+
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rchargel/localiday/db"
+	"github.com/rchargel/localiday/geo"
+	"github.com/rchargel/localiday/holiday"
+)
+
+func TestCheckInRecordsTheDisplaysHolidays(t *testing.T) {
+	useTestDatabase(t)
+	user := createTestUser(t)
+	display := createTestDisplay(t, user.ID, 41.88, -87.63, holiday.Hanukkah+","+holiday.Christmas)
+
+	result, err := NewCheckInService(db.NewDBRepositories(), nil).CheckIn(user.ID, display.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := result.CheckIn.HolidayKeys()
+	if len(keys) != 2 || keys[0] != holiday.Hanukkah || keys[1] != holiday.Christmas {
+		t.Errorf("Recorded holidays %v, expected the display's %v", keys, display.HolidayKeys())
+	}
+	if !result.CheckIn.Located || result.CheckIn.Verified {
+		t.Errorf("Expected a located, unverified check-in, got %+v", result.CheckIn)
+	}
+}
+
+func TestCheckInVerifiedByDevice(t *testing.T) {
+	useTestDatabase(t)
+	user := createTestUser(t)
+	display := createTestDisplay(t, user.ID, 41.89, -87.62, "")
+	s := NewCheckInService(db.NewDBRepositories(), nil)
+
+	far := &geo.Point{Latitude: 41.95, Longitude: -87.62}
+	if _, err := s.CheckIn(user.ID, display.ID, far); err == nil {
+		t.Error("A check-in from several kilometers away should be refused")
+	}
+	near := &geo.Point{Latitude: 41.8905, Longitude: -87.6205}
+	result, err := s.CheckIn(user.ID, display.ID, near)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.CheckIn.Verified {
+		t.Error("A check-in from beside the display should be verified")
+	}
+	if _, err = s.CheckIn(user.ID, display.ID, near); err == nil {
+		t.Error("A second check-in the same night should be refused")
+	}
+}
+
+func TestCheckInUnverifiedWhenDisplayCannotBeLocated(t *testing.T) {
+	useTestDatabase(t)
+	user := createTestUser(t)
+	display := createTestDisplay(t, user.ID, 41.87, -87.64, "")
+	lost := func(displayID int64) (geo.Point, bool) { return geo.Point{}, false }
+
+	result, err := NewCheckInService(db.NewDBRepositories(), lost).CheckIn(user.ID, display.ID, &geo.Point{Latitude: 41.87, Longitude: -87.64})
+	if err != nil {
+		t.Fatalf("Expected an unverified check-in, got %v", err)
+	}
+	if result.CheckIn.Verified || result.CheckIn.Located {
+		t.Errorf("Expected an unlocated, unverified check-in, got %+v", result.CheckIn)
+	}
+}
+
+func TestCheckInAtMissingDisplay(t *testing.T) {
+	useTestDatabase(t)
+	user := createTestUser(t)
+	if _, err := NewCheckInService(db.NewDBRepositories(), nil).CheckIn(user.ID, 999999, nil); err == nil {
+		t.Error("A check-in at a display which does not exist should be refused")
+	}
+}
+
+func TestNightOutBadge(t *testing.T) {
+	useTestDatabase(t)
+	user := createTestUser(t)
+	s := NewCheckInService(db.NewDBRepositories(), nil)
+	for i := 0; i < 10; i++ {
+		display := createTestDisplay(t, user.ID, 40+float64(i)*0.01, -88, "")
+		result, err := s.CheckIn(user.ID, display.ID, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if earned := hasEarned(result, "NIGHT_OUT"); earned != (i == 9) {
+			t.Errorf("Night Out earned %v after %v displays", earned, i+1)
+		}
+	}
+}
+
+func TestHolidayHopperBadge(t *testing.T) {
+	useTestDatabase(t)
+	user := createTestUser(t)
+	s := NewCheckInService(db.NewDBRepositories(), nil)
+	for i, holidays := range []string{holiday.Halloween, holiday.Halloween, holiday.Diwali, holiday.Christmas} {
+		display := createTestDisplay(t, user.ID, 39+float64(i)*0.01, -88, holidays)
+		result, err := s.CheckIn(user.ID, display.ID, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if earned := hasEarned(result, "HOLIDAY_HOPPER"); earned != (i == 3) {
+			t.Errorf("Holiday Hopper earned %v after visiting %v", earned, holidays)
+		}
+	}
+}
+
+func TestChampionsTourBadge(t *testing.T) {
+	useTestDatabase(t)
+	user := createTestUser(t)
+	winner := createTestDisplay(t, user.ID, 38, -88, "")
+	loser := createTestDisplay(t, user.ID, 38.01, -88, "")
+	now := time.Now()
+	contest := &db.Contest{Name: "Best on the block", Holiday: holiday.Christmas, Latitude: 38, Longitude: -88,
+		RadiusKm: 5, VotingStart: now.Add(-time.Hour), VotingEnd: now.Add(time.Hour), CreatedBy: user.ID}
+	if err := db.CreateContest(contest, []int64{winner.ID, loser.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CastVote(&db.ContestVote{ContestID: contest.ID, DisplayID: winner.ID, UserID: user.ID, IPAddress: "10.0.0.1"}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := contest.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewCheckInService(db.NewDBRepositories(), nil)
+	result, err := s.CheckIn(user.ID, loser.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasEarned(result, "CHAMPIONS_TOUR") {
+		t.Error("Champions Tour earned without visiting the winner")
+	}
+	if result, err = s.CheckIn(user.ID, winner.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !hasEarned(result, "CHAMPIONS_TOUR") {
+		t.Error("Champions Tour not earned after visiting every winner")
+	}
+	if !db.HasBadge(user.ID, "CHAMPIONS_TOUR") {
+		t.Error("Champions Tour was not awarded")
+	}
+}
+
+func hasEarned(result *CheckInResult, badge string) bool {
+	for _, b := range result.Badges {
+		if b.Key == badge {
+			return true
+		}
+	}
+	return false
+}
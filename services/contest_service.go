@@ -0,0 +1,147 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rchargel/localiday/app"
+	"github.com/rchargel/localiday/db"
+	"github.com/rchargel/localiday/geo"
+	"github.com/rchargel/localiday/holiday"
+)
+
+const (
+	// minVoterAccountAge how old an account must be before it may vote.
+	minVoterAccountAge = 3 * 24 * time.Hour
+	// maxVotesPerIP how many votes may be cast in a contest from one address.
+	maxVotesPerIP = 3
+	// newAccountAge accounts younger than this are watched for vote bursts.
+	newAccountAge = 14 * 24 * time.Hour
+	// voteBurstWindow and voteBurstSize a burst is this many votes for one
+	// display from new accounts inside the window.
+	voteBurstWindow = 15 * time.Minute
+	voteBurstSize   = 5
+)
+
+// ContestService defines a set of functions for running display contests.
+type ContestService struct {
+	repos *db.Repositories
+}
+
+// NewContestService creates a pointer to the contest service using the
+// repositories.
+func NewContestService(repos *db.Repositories) *ContestService {
+	return &ContestService{repos}
+}
+
+// CreateContest creates a contest for the displays in an area. Every display
+// must be inside the area and put up for the contest's holiday.
+func (c *ContestService) CreateContest(contest *db.Contest, displayIDs []int64) error {
+	if len(contest.Name) == 0 {
+		return errors.New("A contest must have a name.")
+	}
+	if _, err := holiday.Get(contest.Holiday); err != nil {
+		return err
+	}
+	if err := (geo.Point{Latitude: contest.Latitude, Longitude: contest.Longitude}).Validate(); err != nil {
+		return err
+	}
+	if !(contest.RadiusKm > 0) {
+		return errors.New("A contest area must have a radius.")
+	}
+	if err := checkEntries(contest, displayIDs); err != nil {
+		return err
+	}
+	return db.CreateContest(contest, displayIDs)
+}
+
+// checkEntries checks that each of the displays may be entered in the contest.
+func checkEntries(contest *db.Contest, displayIDs []int64) error {
+	displays, err := db.Display{}.FindByIDs(displayIDs)
+	if err != nil {
+		return err
+	}
+	byID := make(map[int64]*db.Display, len(displays))
+	for i := range displays {
+		byID[displays[i].ID] = &displays[i]
+	}
+	center := geo.Point{Latitude: contest.Latitude, Longitude: contest.Longitude}
+	for _, id := range displayIDs {
+		display, found := byID[id]
+		if !found {
+			return fmt.Errorf("Could not find display %v.", id)
+		}
+		if geo.Distance(center, display.Point()) > contest.RadiusKm {
+			return fmt.Errorf("Display %v is outside the contest's area.", id)
+		}
+		if !app.Contains(display.HolidayKeys(), contest.Holiday) {
+			return fmt.Errorf("Display %v is not put up for %v.", id, contest.Holiday)
+		}
+	}
+	return nil
+}
+
+// Vote casts a user's vote for a display. The account must be old enough and
+// only a few votes are accepted from each address. A vote which completes a
+// burst of votes from new accounts is accepted, but the burst is flagged and
+// left out of the results.
+func (c *ContestService) Vote(contestID, displayID, userID int64, ip string) (*db.ContestVote, error) {
+	contest, err := db.Contest{}.Get(contestID)
+	if err != nil {
+		return nil, fmt.Errorf("Could not find contest %v.", contestID)
+	}
+	now := time.Now()
+	if !contest.IsVotingOpen(now) {
+		return nil, errors.New("Voting is not open for this contest.")
+	}
+	if !contest.IsEligible(displayID) {
+		return nil, fmt.Errorf("Display %v is not entered in this contest.", displayID)
+	}
+	user, err := c.repos.Users.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	if now.Sub(user.Created) < minVoterAccountAge {
+		return nil, errors.New("Your account is too new to vote.")
+	}
+	if contest.HasVoted(userID) {
+		return nil, errors.New("You have already voted in this contest.")
+	}
+	vote := &db.ContestVote{ContestID: contestID, DisplayID: displayID, UserID: userID, IPAddress: ip}
+	if err = db.CastVote(vote, maxVotesPerIP); err != nil {
+		return nil, err
+	}
+
+	since, accountsSince := now.Add(-voteBurstWindow), now.Add(-newAccountAge)
+	if contest.CountNewAccountVotes(displayID, since, accountsSince) >= voteBurstSize {
+		flagged, err := contest.FlagNewAccountVotes(displayID, since, accountsSince)
+		if err != nil {
+			return vote, err
+		}
+		app.Log(app.Info, "Flagged %v votes from new accounts for display %v in contest %v.", flagged, displayID, contestID)
+	}
+	return vote, nil
+}
+
+// Leaderboard gets the contest and its leaderboard.
+func (c *ContestService) Leaderboard(contestID int64) (*db.Contest, []db.ContestEntry, error) {
+	contest, err := db.Contest{}.Get(contestID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Could not find contest %v.", contestID)
+	}
+	entries, err := contest.Leaderboard()
+	return contest, entries, err
+}
+
+// Close closes a contest early, freezing its results.
+func (c *ContestService) Close(contestID int64) (*db.Contest, error) {
+	contest, err := db.Contest{}.Get(contestID)
+	if err != nil {
+		return nil, fmt.Errorf("Could not find contest %v.", contestID)
+	}
+	if contest.Status == db.ContestClosed {
+		return nil, fmt.Errorf("Contest %v is already closed.", contestID)
+	}
+	return contest, contest.Close()
+}
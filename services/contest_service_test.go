@@ -0,0 +1,102 @@
+//go:build sqlite
+// +build sqlite
+
+package services
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rchargel/localiday/db"
+	"github.com/rchargel/localiday/holiday"
+)
+
+func TestVotesPerAddressLimitedAtOnce(t *testing.T) {
+	useTestDatabase(t)
+	owner := createTestUser(t)
+	display := createTestDisplay(t, owner.ID, 34, -90, holiday.Christmas)
+	now := time.Now()
+	contest := &db.Contest{Name: "Brightest block", Holiday: holiday.Christmas, Latitude: 34, Longitude: -90,
+		RadiusKm: 5, VotingStart: now.Add(-time.Hour), VotingEnd: now.Add(time.Hour), CreatedBy: owner.ID}
+	if err := db.CreateContest(contest, []int64{display.ID}); err != nil {
+		t.Fatal(err)
+	}
+	voters := make([]*db.User, 3*maxVotesPerIP)
+	for i := range voters {
+		voters[i] = createTestUser(t)
+	}
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	accepted := 0
+	for _, voter := range voters {
+		wg.Add(1)
+		go func(userID int64) {
+			defer wg.Done()
+			err := db.CastVote(&db.ContestVote{ContestID: contest.ID, DisplayID: display.ID, UserID: userID, IPAddress: "198.51.100.1"}, maxVotesPerIP)
+			if err == nil {
+				mutex.Lock()
+				accepted++
+				mutex.Unlock()
+			}
+		}(voter.ID)
+	}
+	wg.Wait()
+	if accepted != maxVotesPerIP {
+		t.Errorf("Accepted %v votes from one address, expected %v", accepted, maxVotesPerIP)
+	}
+	if err := db.CastVote(&db.ContestVote{ContestID: contest.ID, DisplayID: display.ID, UserID: owner.ID, IPAddress: "198.51.100.2"}, maxVotesPerIP); err != nil {
+		t.Errorf("A vote from another address should be accepted: %v", err)
+	}
+}
+
+func TestContestEntriesMustBeInTheAreaAndHoliday(t *testing.T) {
+	useTestDatabase(t)
+	owner := createTestUser(t)
+	inside := createTestDisplay(t, owner.ID, 34.5, -90, holiday.Christmas+","+holiday.Hanukkah)
+	outside := createTestDisplay(t, owner.ID, 35, -90, holiday.Christmas)
+	halloween := createTestDisplay(t, owner.ID, 34.51, -90, holiday.Halloween)
+	now := time.Now()
+	s := NewContestService(db.NewDBRepositories())
+	tests := []struct {
+		displayIDs []int64
+		ok         bool
+	}{
+		{[]int64{inside.ID}, true},
+		{[]int64{inside.ID, outside.ID}, false},
+		{[]int64{inside.ID, halloween.ID}, false},
+		{[]int64{inside.ID, 999999}, false},
+	}
+	for _, test := range tests {
+		contest := &db.Contest{Name: "Entries", Holiday: holiday.Christmas, Latitude: 34.5, Longitude: -90,
+			RadiusKm: 10, VotingStart: now, VotingEnd: now.Add(time.Hour), CreatedBy: owner.ID}
+		if err := s.CreateContest(contest, test.displayIDs); (err == nil) != test.ok {
+			t.Errorf("Creating a contest of %v: %v", test.displayIDs, err)
+		}
+	}
+}
+
+func TestVotesRejectedOnceVotingCloses(t *testing.T) {
+	useTestDatabase(t)
+	owner, voter := createTestUser(t), createTestUser(t)
+	display := createTestDisplay(t, owner.ID, 34.8, -90, holiday.Christmas)
+	now := time.Now()
+	closed := &db.Contest{Name: "Closed early", Holiday: holiday.Christmas, Latitude: 34.8, Longitude: -90,
+		RadiusKm: 5, VotingStart: now.Add(-time.Hour), VotingEnd: now.Add(time.Hour), CreatedBy: owner.ID}
+	ended := &db.Contest{Name: "Ended", Holiday: holiday.Christmas, Latitude: 34.8, Longitude: -90,
+		RadiusKm: 5, VotingStart: now.Add(-2 * time.Hour), VotingEnd: now.Add(-time.Hour), CreatedBy: owner.ID}
+	for _, contest := range []*db.Contest{closed, ended} {
+		if err := db.CreateContest(contest, []int64{display.ID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := closed.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, contest := range []*db.Contest{closed, ended} {
+		if err := db.CastVote(&db.ContestVote{ContestID: contest.ID, DisplayID: display.ID, UserID: voter.ID, IPAddress: "198.51.100.3"}, maxVotesPerIP); err == nil {
+			t.Errorf("Voted in %v after voting closed", contest.Name)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+//go:build sqlite
+// +build sqlite
+
+package services
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/rchargel/localiday/db"
+)
+
+var testDatabase struct {
+	sync.Once
+	err error
+}
+
+// useTestDatabase points the db package at an in-memory SQLite database
+// migrated to the newest version, shared by every test in the package.
+func useTestDatabase(t *testing.T) {
+	testDatabase.Do(func() {
+		testDatabase.err = openTestDatabase()
+	})
+	if testDatabase.err != nil {
+		t.Fatal(testDatabase.err)
+	}
+}
+
+func openTestDatabase() error {
+	config, err := db.LoadConfig("sqlite::memory:")
+	if err != nil {
+		return err
+	}
+	conn, err := db.Open(config)
+	if err != nil {
+		return err
+	}
+	migrator, err := db.NewMigrator(conn, config.Dialect)
+	if err != nil {
+		return err
+	}
+	if err = migrator.MigrateUp(migrator.Latest()); err != nil {
+		return err
+	}
+	db.NewDatabase(conn, config)
+	return db.BootStrap()
+}
+
+var testUsers int
+
+// createTestUser creates a user no other test has used.
+func createTestUser(t *testing.T) *db.User {
+	testUsers++
+	name := fmt.Sprintf("tester%v", testUsers)
+	user, err := db.CreateNewUser(name, "secret", "Test User", name, name+"@localiday.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// createTestDisplay creates a display owned by the user at the point.
+func createTestDisplay(t *testing.T, userID int64, latitude, longitude float64, holidays string) *db.Display {
+	display := &db.Display{
+		UserID:    userID,
+		Title:     fmt.Sprintf("Display at %v, %v", latitude, longitude),
+		Latitude:  latitude,
+		Longitude: longitude,
+		Holidays:  holidays,
+	}
+	if err := db.CreateDisplay(display); err != nil {
+		t.Fatal(err)
+	}
+	return display
+}
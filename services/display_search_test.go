@@ -0,0 +1,139 @@
+//go:build sqlite
+// +build sqlite
+
+package services
+
+import (
+	"testing"
+
+	"github.com/rchargel/localiday/db"
+	"github.com/rchargel/localiday/geo"
+	"github.com/rchargel/localiday/holiday"
+)
+
+func TestTextSearch(t *testing.T) {
+	useTestDatabase(t)
+	user := createTestUser(t)
+	create := func(title, tags, description, holidays string, latitude float64) *db.Display {
+		d := &db.Display{UserID: user.ID, Title: title, Tags: tags, Description: description, Holidays: holidays,
+			Latitude: latitude, Longitude: -90}
+		if err := db.CreateDisplay(d); err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	inTitle := create("Reindeer Row", "", "Animated deer", holiday.Christmas, 36)
+	inTags := create("Oak Street", "reindeer,inflatables", "", holiday.Christmas, 36.01)
+	inDescription := create("Pine Court", "", "Eight reindeer on the roof", holiday.Hanukkah, 36.02)
+	create("Elm Street", "", "Deer in the yard", holiday.Christmas, 36.03)
+	s := NewDisplayService(nil)
+
+	results, err := s.Search(DisplaySearch{Text: "Reindeer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectDisplays(t, "reindeer", results, inTitle, inTags, inDescription)
+	if results[0].Snippet != "<mark>Reindeer</mark> Row" || results[2].Snippet != "Eight <mark>reindeer</mark> on the roof" {
+		t.Errorf("Unexpected snippets %q and %q", results[0].Snippet, results[2].Snippet)
+	}
+
+	results, err = s.Search(DisplaySearch{Text: "reindeer", Holiday: holiday.Christmas})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectDisplays(t, "reindeer at Christmas", results, inTitle, inTags)
+
+	box := geo.BoundingBox{South: 36.005, West: -91, North: 37, East: -89}
+	results, err = s.Search(DisplaySearch{Text: "reindeer", Box: &box})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectDisplays(t, "reindeer in the box", results, inTags, inDescription)
+
+	// like wildcards are matched as they are.
+	if results, err = s.Search(DisplaySearch{Text: "%"}); err != nil || len(results) != 0 {
+		t.Errorf("Searching for %% found %v displays: %v", len(results), err)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	useTestDatabase(t)
+	user := createTestUser(t)
+	for _, title := range []string{"Snowflake Drive", "snow globe", "Snowy_Lane", "Frosty"} {
+		if err := db.CreateDisplay(&db.Display{UserID: user.ID, Title: title, Latitude: 35, Longitude: -90}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := NewDisplayService(nil)
+	titles, err := s.Complete("SNOW")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(titles) != 3 || titles[0] != "Snowflake Drive" || titles[1] != "Snowy_Lane" || titles[2] != "snow globe" {
+		t.Errorf("Completed SNOW as %v", titles)
+	}
+	if titles, _ = s.Complete("snowy_"); len(titles) != 1 {
+		t.Errorf("Completed snowy_ as %v", titles)
+	}
+	if titles, _ = s.Complete("snow_"); len(titles) != 0 {
+		t.Errorf("The underscore should not match any character, completed snow_ as %v", titles)
+	}
+}
+
+func expectDisplays(t *testing.T, search string, results []DisplayResult, expected ...*db.Display) {
+	if len(results) != len(expected) {
+		t.Errorf("Searching for %v found %v displays, expected %v", search, len(results), len(expected))
+		return
+	}
+	for i := range expected {
+		if results[i].ID != expected[i].ID {
+			t.Errorf("Searching for %v found %v in place %v, expected %v", search, results[i].Title, i+1, expected[i].Title)
+		}
+	}
+}
+
+func TestCreateAddsDisplayToFeeds(t *testing.T) {
+	useTestDatabase(t)
+	user := createTestUser(t)
+	index := geo.NewIndex()
+	d := &db.Display{UserID: user.ID, Title: "Feed Lane", Latitude: 37, Longitude: -90}
+	if err := NewDisplayService(index).Create(d); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := index.Get(d.ID); !found {
+		t.Error("The new display was not indexed")
+	}
+	activities, err := db.FindFeedActivities([]int64{user.ID}, nil, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(activities) != 1 || activities[0].ActivityType != db.ItemDisplay || activities[0].ItemID != d.ID || !activities[0].Located {
+		t.Errorf("Found activities %+v, expected the new display", activities)
+	}
+}
+
+func TestUpdateKeepsIndexCurrent(t *testing.T) {
+	useTestDatabase(t)
+	user := createTestUser(t)
+	index := geo.NewIndex()
+	s := NewDisplayService(index)
+	d := &db.Display{UserID: user.ID, Title: "Moving Lane", Latitude: 38, Longitude: -90}
+	if err := s.Create(d); err != nil {
+		t.Fatal(err)
+	}
+	stale := *d
+	d.Latitude = 38.5
+	if err := s.Update(d); err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := index.Get(d.ID); m.Latitude != 38.5 {
+		t.Errorf("The index has the display at %v, expected it moved", m.Point)
+	}
+	stale.Latitude = 39
+	if err := s.Update(&stale); !db.IsConflict(err) {
+		t.Errorf("Updating a stale display should conflict, got %v", err)
+	}
+	if m, _ := index.Get(d.ID); m.Latitude != 38.5 {
+		t.Errorf("A failed update moved the display in the index to %v", m.Point)
+	}
+}
@@ -0,0 +1,466 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rchargel/localiday/app"
+	"github.com/rchargel/localiday/db"
+	"github.com/rchargel/localiday/geo"
+	"github.com/rchargel/localiday/schedule"
+)
+
+const (
+	// MaxSearchResults the most displays a search returns.
+	MaxSearchResults = 500
+	// MaxCompletions the most titles an autocomplete returns.
+	MaxCompletions = 10
+	// snippetSize about how many characters of a display are shown around
+	// the first word matching a text search.
+	snippetSize = 160
+)
+
+// DisplaySearch the criteria of a display search, either a box or the radius
+// in kilometers around a center, and optionally text to match, the holiday
+// the displays are put up for and a time they must be lit at. A text search
+// need not be limited to an area.
+type DisplaySearch struct {
+	Box      *geo.BoundingBox
+	Center   *geo.Point
+	RadiusKm float64
+	Text     string
+	Holiday  string
+	LitAt    *time.Time
+}
+
+// DisplayResult a display found by a search, with its distance in kilometers
+// from the center of a radius search. For a text search it has its rank and a
+// snippet of HTML with the matching words marked.
+type DisplayResult struct {
+	db.Display
+	Distance float64
+	Rank     float64
+	Snippet  string
+}
+
+// DisplayService defines a set of functions for creating and removing
+// displays, keeping the spatial index of displays current.
+type DisplayService struct {
+	index *geo.Index
+}
+
+// NewDisplayService creates a pointer to the display service. The index may
+// be nil if displays are not indexed.
+func NewDisplayService(index *geo.Index) *DisplayService {
+	return &DisplayService{index}
+}
+
+// LoadDisplayIndex creates a spatial index of every display.
+func LoadDisplayIndex() (*geo.Index, error) {
+	displays, err := db.Display{}.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	markers := make([]geo.Marker, len(displays))
+	for i := range displays {
+		markers[i] = displays[i].Marker()
+	}
+	index := geo.NewIndex()
+	index.Load(markers)
+	app.Log(app.Info, "Indexed %v displays.", index.Len())
+	return index, nil
+}
+
+// DisplayHeatSource creates a heatmap source of the displays in the index,
+// rated by the votes they have won in contests and as popular as the number
+// of check-ins at them.
+func DisplayHeatSource(index *geo.Index) geo.HeatSource {
+	return func(box geo.BoundingBox) []geo.HeatSample {
+		markers := index.InBox(box)
+		samples := make([]geo.HeatSample, 0, len(markers))
+		for start := 0; start < len(markers); start += MaxSearchResults {
+			page := markers[start:]
+			if len(page) > MaxSearchResults {
+				page = page[:MaxSearchResults]
+			}
+			ids := make([]int64, len(page))
+			for i, m := range page {
+				ids[i] = m.ID
+			}
+			votes, err := db.CountVotesByDisplay(ids)
+			if err != nil {
+				app.Log(app.Error, "Could not count votes for the heatmap: %v", err)
+			}
+			checkIns, err := db.CountCheckInsByDisplay(ids)
+			if err != nil {
+				app.Log(app.Error, "Could not count check-ins for the heatmap: %v", err)
+			}
+			for _, m := range page {
+				samples = append(samples, geo.HeatSample{Point: m.Point, Rating: float64(votes[m.ID]), Popularity: float64(checkIns[m.ID])})
+			}
+		}
+		return samples
+	}
+}
+
+// Search finds the displays matching the search, best match first for a text
+// search or closest first for a radius search, up to MaxSearchResults of
+// them. The index is used to find the displays in the area, or the database
+// if there is no index or the search has text.
+func (s *DisplayService) Search(search DisplaySearch) ([]DisplayResult, error) {
+	if err := search.validate(); err != nil {
+		return nil, err
+	}
+	if len(search.Text) > 0 {
+		return s.searchText(search)
+	}
+	if s.index != nil {
+		return s.searchIndex(search)
+	}
+	return s.searchDatabase(search)
+}
+
+// searchIndex reads the displays found in the index a page at a time, until
+// there are enough which match the search.
+func (s *DisplayService) searchIndex(search DisplaySearch) ([]DisplayResult, error) {
+	var nearby []geo.Nearby
+	if search.Box != nil {
+		markers := s.index.InBox(*search.Box)
+		nearby = make([]geo.Nearby, len(markers))
+		for i, m := range markers {
+			nearby[i] = geo.Nearby{Marker: m}
+		}
+	} else {
+		nearby = s.index.WithinRadius(*search.Center, search.RadiusKm)
+	}
+
+	results := make([]DisplayResult, 0, 64)
+	for start := 0; start < len(nearby) && len(results) < MaxSearchResults; start += MaxSearchResults {
+		page := nearby[start:]
+		if len(page) > MaxSearchResults {
+			page = page[:MaxSearchResults]
+		}
+		ids := make([]int64, len(page))
+		for i, n := range page {
+			ids[i] = n.ID
+		}
+		displays, err := db.Display{}.FindByIDs(ids)
+		if err != nil {
+			return nil, err
+		}
+		byID := make(map[int64]db.Display, len(displays))
+		for _, d := range displays {
+			byID[d.ID] = d
+		}
+		matched := make([]DisplayResult, 0, len(page))
+		for _, n := range page {
+			if d, found := byID[n.ID]; found && search.matches(&d) {
+				matched = append(matched, DisplayResult{Display: d, Distance: n.Distance})
+			}
+		}
+		for _, r := range search.filterLit(matched) {
+			if len(results) < MaxSearchResults {
+				results = append(results, r)
+			}
+		}
+	}
+	return results, nil
+}
+
+func (s *DisplayService) searchDatabase(search DisplaySearch) ([]DisplayResult, error) {
+	box := search.Box
+	if box == nil {
+		radius := geo.RadiusBox(*search.Center, search.RadiusKm)
+		box = &radius
+	}
+	displays, err := db.Display{}.FindInBox(*box)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]DisplayResult, 0, len(displays))
+	for i := range displays {
+		d := &displays[i]
+		if !search.matches(d) {
+			continue
+		}
+		if search.Box != nil {
+			results = append(results, DisplayResult{Display: *d})
+		} else if distance := geo.Distance(*search.Center, d.Point()); distance <= search.RadiusKm {
+			results = append(results, DisplayResult{Display: *d, Distance: distance})
+		}
+	}
+	if search.Box == nil {
+		sort.Sort(displayResultSorter(results))
+	}
+	results = search.filterLit(results)
+	if len(results) > MaxSearchResults {
+		results = results[:MaxSearchResults]
+	}
+	return results, nil
+}
+
+// searchText finds the displays matching the search's text, in the search's
+// area if it has one.
+func (s *DisplayService) searchText(search DisplaySearch) ([]DisplayResult, error) {
+	box := search.Box
+	if search.Center != nil {
+		radius := geo.RadiusBox(*search.Center, search.RadiusKm)
+		box = &radius
+	}
+	matches, err := db.Display{}.SearchText(search.Text, search.Holiday, box, MaxSearchResults)
+	if err != nil {
+		return nil, err
+	}
+	terms := strings.Fields(strings.ToLower(search.Text))
+	results := make([]DisplayResult, 0, len(matches))
+	for _, m := range matches {
+		r := DisplayResult{Display: m.Display, Rank: m.Rank, Snippet: snippet(&m.Display, terms)}
+		if search.Center != nil {
+			if r.Distance = geo.Distance(*search.Center, m.Point()); r.Distance > search.RadiusKm {
+				continue
+			}
+		}
+		results = append(results, r)
+	}
+	return search.filterLit(results), nil
+}
+
+// Complete finds the titles of displays starting with the prefix, for
+// autocomplete.
+func (s *DisplayService) Complete(prefix string) ([]string, error) {
+	if len(strings.TrimSpace(prefix)) == 0 {
+		return []string{}, nil
+	}
+	return db.Display{}.CompleteTitle(prefix, MaxCompletions)
+}
+
+// snippet gets the part of the display's description, or of its title if the
+// description does not match, around the first matching word, escaped as HTML
+// with each matching word in a mark element.
+func snippet(d *db.Display, terms []string) string {
+	text := d.Description
+	start, _ := findTerm(text, terms)
+	if start < 0 {
+		text, start = d.Title, 0
+	}
+	if len(text) > snippetSize {
+		start -= snippetSize / 4
+		if start < 0 {
+			start = 0
+		}
+		end := start + snippetSize
+		if end > len(text) {
+			end, start = len(text), len(text)-snippetSize
+		}
+		cut := strings.ToValidUTF8(text[start:end], "")
+		if start > 0 {
+			cut = "…" + cut
+		}
+		if end < len(text) {
+			cut += "…"
+		}
+		text = cut
+	}
+	return markTerms(text, terms)
+}
+
+// markTerms escapes the text as HTML, marking each appearance of the terms.
+func markTerms(text string, terms []string) string {
+	var b strings.Builder
+	for len(text) > 0 {
+		i, n := findTerm(text, terms)
+		if i < 0 {
+			b.WriteString(html.EscapeString(text))
+			break
+		}
+		b.WriteString(html.EscapeString(text[:i]))
+		b.WriteString("<mark>" + html.EscapeString(text[i:i+n]) + "</mark>")
+		text = text[i+n:]
+	}
+	return b.String()
+}
+
+// findTerm finds the first of the terms in the text, ignoring case, returning
+// where it starts and its length in the text, or -1 if none are found.
+func findTerm(text string, terms []string) (int, int) {
+	for i := range text {
+		longest := 0
+		for _, term := range terms {
+			if n := prefixFold(text[i:], term); n > longest {
+				longest = n
+			}
+		}
+		if longest > 0 {
+			return i, longest
+		}
+	}
+	return -1, 0
+}
+
+// prefixFold gets the length of the prefix of the text which matches the
+// term, ignoring case, or 0 if the text does not start with the term.
+func prefixFold(text, term string) int {
+	n := 0
+	for _, t := range term {
+		r, size := utf8.DecodeRuneInString(text[n:])
+		if size == 0 || !strings.EqualFold(string(r), string(t)) {
+			return 0
+		}
+		n += size
+	}
+	return n
+}
+
+// matches checks to see if the display passes the search's filters.
+func (d DisplaySearch) matches(display *db.Display) bool {
+	return len(d.Holiday) == 0 || app.Contains(display.HolidayKeys(), d.Holiday)
+}
+
+// filterLit filters the results down to the displays lit at the search's
+// time, if it has one.
+func (d DisplaySearch) filterLit(results []DisplayResult) []DisplayResult {
+	if d.LitAt == nil {
+		return results
+	}
+	items := make([]schedule.Scheduled, len(results))
+	for i := range results {
+		items[i] = &results[i]
+	}
+	lit := schedule.FilterLitAt(items, *d.LitAt)
+	filtered := make([]DisplayResult, len(lit))
+	for i, item := range lit {
+		filtered[i] = *item.(*DisplayResult)
+	}
+	return filtered
+}
+
+func (d DisplaySearch) validate() error {
+	if d.Box != nil {
+		return d.Box.Validate()
+	}
+	if d.Center == nil && len(d.Text) > 0 {
+		return nil
+	}
+	if d.Center == nil {
+		return errors.New("A search needs a box or a center and radius.")
+	}
+	if !(d.RadiusKm > 0) || math.IsInf(d.RadiusKm, 0) {
+		return errors.New("The search radius must be a number greater than zero.")
+	}
+	return d.Center.Validate()
+}
+
+type displayResultSorter []DisplayResult
+
+func (l displayResultSorter) Len() int           { return len(l) }
+func (l displayResultSorter) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l displayResultSorter) Less(i, j int) bool { return l[i].Distance < l[j].Distance }
+
+// Create creates a new display, adding it to the index and to the feeds of
+// the owner's followers and of the area. A display which cannot be added to
+// the feeds is still created.
+func (s *DisplayService) Create(display *db.Display) error {
+	if err := db.CreateDisplay(display); err != nil {
+		return err
+	}
+	if s.index != nil {
+		s.index.Put(display.Marker())
+	}
+	err := db.RecordActivity(&db.Activity{
+		UserID:       display.UserID,
+		ActivityType: db.ItemDisplay,
+		ItemID:       display.ID,
+		Located:      true,
+		Latitude:     display.Latitude,
+		Longitude:    display.Longitude,
+		Summary:      fmt.Sprintf("Added display %v.", display.Title),
+	})
+	if err != nil {
+		app.Log(app.Error, "Could not add display %v to the feeds.", display.ID, err)
+	}
+	return nil
+}
+
+// Update saves the changes to a display, failing with a conflict if it has
+// been changed since it was read, and updates it in the index.
+func (s *DisplayService) Update(display *db.Display) error {
+	if err := db.UpdateDisplay(display); err != nil {
+		return err
+	}
+	if s.index != nil {
+		s.index.Put(display.Marker())
+	}
+	return nil
+}
+
+// Delete deletes a display, taking it out of the index.
+func (s *DisplayService) Delete(id int64) error {
+	if err := db.DeleteDisplay(id); err != nil {
+		return err
+	}
+	if s.index != nil {
+		s.index.Remove(id)
+	}
+	return nil
+}
+
+// Reindex puts the display in the index, or takes it out if it has been
+// deleted or hidden by moderation.
+func (s *DisplayService) Reindex(id int64) {
+	if s.index == nil {
+		return
+	}
+	if display, err := (db.Display{}).Get(id); err == nil {
+		s.index.Put(display.Marker())
+	} else {
+		s.index.Remove(id)
+	}
+}
+
+// Export creates an export of the displays, each a place named by its title.
+func (s *DisplayService) Export(name string, displays []db.Display) *geo.Export {
+	e := &geo.Export{Name: name, Places: make([]geo.Place, len(displays))}
+	for i := range displays {
+		description := displays[i].Description
+		if len(displays[i].Address) > 0 {
+			description = displays[i].Address + "\n" + description
+		}
+		e.Places[i] = geo.Place{Name: displays[i].Title, Description: description, Point: displays[i].Point()}
+	}
+	return e
+}
+
+// displayImportTarget imports displays through the display service.
+type displayImportTarget struct {
+	displays *DisplayService
+}
+
+// NewDisplayImportTarget creates an import target which creates displays
+// through the display service.
+func NewDisplayImportTarget(displays *DisplayService) ImportTarget {
+	return displayImportTarget{displays}
+}
+
+// Exists checks to see if there is already a display with the record's title
+// at about the same place.
+func (t displayImportTarget) Exists(record ImportRecord) (bool, error) {
+	return db.Display{}.Exists(record.Title, record.Point), nil
+}
+
+// Create creates a display from the record, owned by the user.
+func (t displayImportTarget) Create(userID int64, record ImportRecord) error {
+	return t.displays.Create(&db.Display{
+		UserID:      userID,
+		Title:       record.Title,
+		Description: record.Description,
+		Address:     record.Address,
+		Latitude:    record.Latitude,
+		Longitude:   record.Longitude,
+	})
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/rchargel/localiday/db"
+	"github.com/rchargel/localiday/geo"
+)
+
+func TestSnippet(t *testing.T) {
+	tests := []struct {
+		display  db.Display
+		terms    []string
+		expected string
+	}{
+		{db.Display{Title: "Candy Cane Lane", Description: "Lots of lights"}, []string{"candy", "lane"}, "<mark>Candy</mark> Cane <mark>Lane</mark>"},
+		{db.Display{Title: "Maple Ave", Description: "Lights & music <b>nightly</b>"}, []string{"music"}, "Lights &amp; <mark>music</mark> &lt;b&gt;nightly&lt;/b&gt;"},
+		{db.Display{Title: "Über Lights", Description: "Twelve thousand LEDs"}, []string{"led"}, "Twelve thousand <mark>LED</mark>s"},
+		{db.Display{Title: "ÜBER Lights", Description: "Twelve thousand LEDs"}, []string{"über"}, "<mark>ÜBER</mark> Lights"},
+	}
+	for _, test := range tests {
+		if actual := snippet(&test.display, test.terms); actual != test.expected {
+			t.Errorf("snippet(%v, %v) = %q, expected %q", test.display.Title, test.terms, actual, test.expected)
+		}
+	}
+}
+
+func TestSnippetOfLongDescription(t *testing.T) {
+	d := db.Display{Title: "Long", Description: strings.Repeat("tinsel ", 50) + "snowman " + strings.Repeat("garland ", 50)}
+	actual := snippet(&d, []string{"snowman"})
+	if !strings.HasPrefix(actual, "…") || !strings.HasSuffix(actual, "…") {
+		t.Errorf("A snippet from the middle should be cut at both ends: %q", actual)
+	}
+	if !strings.Contains(actual, "<mark>snowman</mark>") {
+		t.Errorf("The snippet should mark the match: %q", actual)
+	}
+	if n := len([]rune(actual)); n > snippetSize+len("<mark></mark>")+2 {
+		t.Errorf("The snippet is %v characters long", n)
+	}
+}
+
+func TestSearchRejectsNonFiniteAreas(t *testing.T) {
+	nan, inf := math.NaN(), math.Inf(1)
+	center := &geo.Point{Latitude: 41.88, Longitude: -87.63}
+	searches := []DisplaySearch{
+		{Box: &geo.BoundingBox{South: nan, West: -88, North: 42, East: -87}},
+		{Center: &geo.Point{Latitude: nan, Longitude: -87.63}, RadiusKm: 5},
+		{Center: center, RadiusKm: nan},
+		{Center: center, RadiusKm: inf},
+	}
+	for _, search := range searches {
+		if err := search.validate(); err == nil {
+			t.Errorf("Search %+v should not be valid", search)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rchargel/localiday/db"
+	"github.com/rchargel/localiday/geo"
+)
+
+const (
+	// FeedPageSize the number of activities in a page of a feed.
+	FeedPageSize = 25
+
+	feedCacheTTL       = 30 * time.Second
+	maxCachedFeedPages = 10000
+	// maxFeedQueries how many times a page is read while area matches are
+	// being filtered out, before a short page is returned.
+	maxFeedQueries = 5
+)
+
+// FeedPage a page of a user's activity feed. Next is the cursor of the
+// following page, or zero if there are no more activities.
+type FeedPage struct {
+	Activities []db.Activity
+	Next       int64
+}
+
+type feedKey struct {
+	userID int64
+	before int64
+}
+
+type cachedFeedPage struct {
+	page    *FeedPage
+	expires time.Time
+}
+
+// feedCache pages of feeds which have been read recently. Feeds are built
+// when they are read, from the users and areas being followed, so the cache
+// keeps paging and refreshing cheap.
+var feedCache = struct {
+	sync.RWMutex
+	pages map[feedKey]cachedFeedPage
+}{pages: make(map[feedKey]cachedFeedPage, 100)}
+
+// FeedService defines a set of functions for following users and areas, and
+// reading the activity of what is followed.
+type FeedService struct {
+	repos *db.Repositories
+}
+
+// NewFeedService creates a pointer to the feed service using the repositories.
+func NewFeedService(repos *db.Repositories) *FeedService {
+	return &FeedService{repos}
+}
+
+// FollowUser makes the user follow another user.
+func (f *FeedService) FollowUser(userID, followedID int64) error {
+	defer invalidateFeed(userID)
+	if err := db.FollowUser(userID, followedID); err != nil {
+		return err
+	}
+	follower, err := f.repos.Users.Get(userID)
+	if err != nil {
+		return err
+	}
+	return NewNotificationService(f.repos).Notify(followedID, db.NotifyFollowed, follower.NickName+" is now following you.",
+		follower.NickName+" will see your displays, photos and reviews in their feed.", db.ItemUser, userID)
+}
+
+// UnfollowUser stops the user following another user.
+func (f *FeedService) UnfollowUser(userID, followedID int64) error {
+	defer invalidateFeed(userID)
+	return db.UnfollowUser(userID, followedID)
+}
+
+// FollowArea makes the user follow an area.
+func (f *FeedService) FollowArea(area *db.FollowedArea) error {
+	defer invalidateFeed(area.UserID)
+	return db.FollowArea(area)
+}
+
+// UnfollowArea stops the user following an area.
+func (f *FeedService) UnfollowArea(userID, areaID int64) error {
+	defer invalidateFeed(userID)
+	return db.UnfollowArea(userID, areaID)
+}
+
+// Feed gets the page of the user's feed before the cursor, which is zero for
+// the first page.
+func (f *FeedService) Feed(userID, before int64) (*FeedPage, error) {
+	key := feedKey{userID, before}
+	feedCache.RLock()
+	cached, found := feedCache.pages[key]
+	feedCache.RUnlock()
+	if found && time.Now().Before(cached.expires) {
+		return cached.page, nil
+	}
+
+	page, err := f.buildFeed(userID, before)
+	if err != nil {
+		return nil, err
+	}
+	cacheFeedPage(key, page)
+	return page, nil
+}
+
+// cacheFeedPage caches the page, making room in a full cache by removing the
+// expired pages or, if none have expired, the oldest.
+func cacheFeedPage(key feedKey, page *FeedPage) {
+	feedCache.Lock()
+	if len(feedCache.pages) >= maxCachedFeedPages {
+		removeExpiredFeedPages()
+	}
+	if len(feedCache.pages) >= maxCachedFeedPages {
+		removeOldestFeedPage()
+	}
+	feedCache.pages[key] = cachedFeedPage{page, time.Now().Add(feedCacheTTL)}
+	feedCache.Unlock()
+}
+
+func (f *FeedService) buildFeed(userID, before int64) (*FeedPage, error) {
+	userIDs, err := db.FindFollowedUserIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+	areas, err := db.FindFollowedAreas(userID)
+	if err != nil {
+		return nil, err
+	}
+	boxes := make([]geo.BoundingBox, len(areas))
+	for i, a := range areas {
+		boxes[i] = geo.RadiusBox(a.Point(), a.RadiusKm)
+	}
+	followed := make(map[int64]bool, len(userIDs))
+	for _, id := range userIDs {
+		followed[id] = true
+	}
+
+	page := &FeedPage{Activities: make([]db.Activity, 0, FeedPageSize)}
+	for i := 0; i < maxFeedQueries && len(page.Activities) < FeedPageSize; i++ {
+		activities, err := db.FindFeedActivities(userIDs, boxes, before, FeedPageSize)
+		if err != nil {
+			return nil, err
+		}
+		read := 0
+		for _, a := range activities {
+			if len(page.Activities) == FeedPageSize {
+				break
+			}
+			read++
+			before = a.ID
+			if a.UserID != userID && (followed[a.UserID] || inAreas(a, areas)) {
+				page.Activities = append(page.Activities, a)
+			}
+		}
+		if read == len(activities) && len(activities) < FeedPageSize {
+			// there is nothing older.
+			return page, nil
+		}
+	}
+	page.Next = before
+	return page, nil
+}
+
+// inAreas checks to see if the activity is within one of the areas. The
+// database only matches an area's bounding box.
+func inAreas(a db.Activity, areas []db.FollowedArea) bool {
+	if !a.Located {
+		return false
+	}
+	for _, area := range areas {
+		if geo.Distance(a.Point(), area.Point()) <= area.RadiusKm {
+			return true
+		}
+	}
+	return false
+}
+
+// invalidateFeed removes the user's cached feed pages, and any which have
+// expired.
+func invalidateFeed(userID int64) {
+	feedCache.Lock()
+	for key := range feedCache.pages {
+		if key.userID == userID {
+			delete(feedCache.pages, key)
+		}
+	}
+	removeExpiredFeedPages()
+	feedCache.Unlock()
+}
+
+// removeOldestFeedPage must be called holding the cache's lock.
+func removeOldestFeedPage() {
+	var oldest feedKey
+	var expires time.Time
+	for key, cached := range feedCache.pages {
+		if expires.IsZero() || cached.expires.Before(expires) {
+			oldest, expires = key, cached.expires
+		}
+	}
+	delete(feedCache.pages, oldest)
+}
+
+// removeExpiredFeedPages must be called holding the cache's lock.
+func removeExpiredFeedPages() {
+	now := time.Now()
+	for key, cached := range feedCache.pages {
+		if now.After(cached.expires) {
+			delete(feedCache.pages, key)
+		}
+	}
+}
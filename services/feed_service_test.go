@@ -0,0 +1,43 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFeedCacheEvictsOldestPage(t *testing.T) {
+	feedCache.Lock()
+	now := time.Now()
+	feedCache.pages = make(map[feedKey]cachedFeedPage, maxCachedFeedPages)
+	for i := 0; i < maxCachedFeedPages; i++ {
+		// none have expired, the page for user 0 is the oldest.
+		feedCache.pages[feedKey{int64(i), 0}] = cachedFeedPage{&FeedPage{}, now.Add(time.Minute + time.Duration(i)*time.Millisecond)}
+	}
+	feedCache.Unlock()
+	defer func() {
+		feedCache.Lock()
+		feedCache.pages = make(map[feedKey]cachedFeedPage, 100)
+		feedCache.Unlock()
+	}()
+
+	cacheFeedPage(feedKey{-1, 0}, &FeedPage{})
+	if n := len(feedCache.pages); n != maxCachedFeedPages {
+		t.Errorf("The cache has %v pages, expected at most %v", n, maxCachedFeedPages)
+	}
+	if _, found := feedCache.pages[feedKey{0, 0}]; found {
+		t.Error("The oldest page should have been evicted")
+	}
+	if _, found := feedCache.pages[feedKey{-1, 0}]; !found {
+		t.Error("The new page was not cached")
+	}
+
+	expired := feedKey{1, 0}
+	feedCache.pages[expired] = cachedFeedPage{&FeedPage{}, now.Add(-time.Second)}
+	cacheFeedPage(feedKey{-2, 0}, &FeedPage{})
+	if _, found := feedCache.pages[expired]; found || len(feedCache.pages) != maxCachedFeedPages {
+		t.Errorf("An expired page should be evicted first, the cache has %v pages", len(feedCache.pages))
+	}
+	if _, found := feedCache.pages[feedKey{2, 0}]; !found {
+		t.Error("Evicted a current page when one had expired")
+	}
+}
@@ -0,0 +1,338 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rchargel/localiday/app"
+	"github.com/rchargel/localiday/geo"
+)
+
+// Import fields which may be mapped to CSV columns.
+const (
+	ImportTitle       = "Title"
+	ImportDescription = "Description"
+	ImportAddress     = "Address"
+	ImportLatitude    = "Latitude"
+	ImportLongitude   = "Longitude"
+)
+
+// importJobExpiry how long a finished import job's report is kept.
+const importJobExpiry = time.Hour
+
+// Import row statuses.
+const (
+	ImportCreated = "CREATED"
+	ImportSkipped = "SKIPPED"
+	ImportFailed  = "FAILED"
+	ImportValid   = "VALID"
+)
+
+// ImportRecord a display read from an import file.
+type ImportRecord struct {
+	Row         int
+	Title       string
+	Description string
+	Address     string
+	geo.Point
+}
+
+// ImportRowResult the outcome of importing a single row.
+type ImportRowResult struct {
+	Row    int
+	Title  string
+	Status string
+	Reason string
+}
+
+// ImportTarget the store displays are imported into, owned by the user who
+// started the import.
+type ImportTarget interface {
+	Exists(record ImportRecord) (bool, error)
+	Create(userID int64, record ImportRecord) error
+}
+
+// ColumnMapping maps each import field to the CSV column header it is read from.
+type ColumnMapping map[string]string
+
+// ImportJob an import running in the background.
+type ImportJob struct {
+	ID      string
+	UserID  int64
+	DryRun  bool
+	Started time.Time
+
+	lock     sync.Mutex
+	total    int
+	finished time.Time
+	results  []ImportRowResult
+}
+
+// ImportProgress a snapshot of an import job's progress.
+type ImportProgress struct {
+	ID        string
+	DryRun    bool
+	Total     int
+	Processed int
+	Created   int
+	Skipped   int
+	Failed    int
+	Done      bool
+	Results   []ImportRowResult
+}
+
+// ImportService defines a set of functions for bulk importing displays.
+type ImportService struct {
+	target ImportTarget
+	lock   sync.Mutex
+	jobs   map[string]*ImportJob
+}
+
+// NewImportService creates a pointer to the import service.
+func NewImportService(target ImportTarget) *ImportService {
+	return &ImportService{target: target, jobs: make(map[string]*ImportJob, 10)}
+}
+
+// ParseCSV reads records from CSV data with a header row, using the mapping
+// to find the column for each field. Rows which cannot be read are returned
+// as failed results.
+func (s *ImportService) ParseCSV(reader io.Reader, mapping ColumnMapping) ([]ImportRecord, []ImportRowResult, error) {
+	r := csv.NewReader(reader)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("Could not read CSV header: %v", err)
+	}
+	columns := make(map[string]int, len(mapping))
+	for field, column := range mapping {
+		found := false
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), column) {
+				columns[field] = i
+				found = true
+			}
+		}
+		if !found {
+			return nil, nil, fmt.Errorf("Column %v mapped to %v is not in the CSV header.", column, field)
+		}
+	}
+	for _, field := range []string{ImportTitle, ImportLatitude, ImportLongitude} {
+		if _, found := columns[field]; !found {
+			return nil, nil, fmt.Errorf("No column mapped to the required field %v.", field)
+		}
+	}
+
+	records := make([]ImportRecord, 0, 100)
+	failed := make([]ImportRowResult, 0, 10)
+	for row := 2; ; row++ {
+		line, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			failed = append(failed, ImportRowResult{Row: row, Status: ImportFailed, Reason: err.Error()})
+			continue
+		}
+		value := func(field string) string {
+			if i, found := columns[field]; found && i < len(line) {
+				return strings.TrimSpace(line[i])
+			}
+			return ""
+		}
+		record := ImportRecord{Row: row, Title: value(ImportTitle), Description: value(ImportDescription), Address: value(ImportAddress)}
+		if record.Latitude, err = strconv.ParseFloat(value(ImportLatitude), 64); err != nil {
+			failed = append(failed, ImportRowResult{Row: row, Title: record.Title, Status: ImportFailed, Reason: "Invalid latitude."})
+			continue
+		}
+		if record.Longitude, err = strconv.ParseFloat(value(ImportLongitude), 64); err != nil {
+			failed = append(failed, ImportRowResult{Row: row, Title: record.Title, Status: ImportFailed, Reason: "Invalid longitude."})
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, failed, nil
+}
+
+// ParseGeoJSON reads records from the Point features of a GeoJSON
+// FeatureCollection. The title, description and address are read from the
+// feature properties, rows are numbered by feature starting at one.
+func (s *ImportService) ParseGeoJSON(reader io.Reader) ([]ImportRecord, []ImportRowResult, error) {
+	var collection struct {
+		Type     string
+		Features []struct {
+			Geometry struct {
+				Type        string
+				Coordinates []float64
+			}
+			Properties map[string]interface{}
+		}
+	}
+	if err := json.NewDecoder(reader).Decode(&collection); err != nil {
+		return nil, nil, fmt.Errorf("Could not read GeoJSON: %v", err)
+	}
+	if collection.Type != "FeatureCollection" {
+		return nil, nil, errors.New("GeoJSON import must be a FeatureCollection.")
+	}
+
+	records := make([]ImportRecord, 0, len(collection.Features))
+	failed := make([]ImportRowResult, 0, 10)
+	for i, feature := range collection.Features {
+		property := func(names ...string) string {
+			for _, name := range names {
+				if v, found := feature.Properties[name]; found && v != nil {
+					return strings.TrimSpace(app.ToStringValue(v))
+				}
+			}
+			return ""
+		}
+		record := ImportRecord{
+			Row:         i + 1,
+			Title:       property("title", "name", "Title", "Name"),
+			Description: property("description", "Description"),
+			Address:     property("address", "Address"),
+		}
+		g := feature.Geometry
+		if g.Type != "Point" || len(g.Coordinates) < 2 {
+			failed = append(failed, ImportRowResult{Row: record.Row, Title: record.Title, Status: ImportFailed, Reason: "Feature is not a point."})
+			continue
+		}
+		record.Longitude, record.Latitude = g.Coordinates[0], g.Coordinates[1]
+		records = append(records, record)
+	}
+	return records, failed, nil
+}
+
+// Start starts importing the records in the background for the user. Rows
+// which could not be parsed are included in the job's report. In a dry run
+// every row is validated but nothing is created.
+func (s *ImportService) Start(userID int64, records []ImportRecord, parseFailures []ImportRowResult, dryRun bool) *ImportJob {
+	job := &ImportJob{
+		ID:      createJobID(),
+		UserID:  userID,
+		DryRun:  dryRun,
+		Started: time.Now(),
+		total:   len(records) + len(parseFailures),
+		results: append(make([]ImportRowResult, 0, len(records)+len(parseFailures)), parseFailures...),
+	}
+	s.lock.Lock()
+	s.expire(job.Started)
+	s.jobs[job.ID] = job
+	s.lock.Unlock()
+
+	go s.run(job, records)
+	return job
+}
+
+// GetJob gets an import job by its ID.
+func (s *ImportService) GetJob(id string) (*ImportJob, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.expire(time.Now())
+	if job, found := s.jobs[id]; found {
+		return job, nil
+	}
+	return nil, fmt.Errorf("Could not find import job %v.", id)
+}
+
+// expire removes the jobs which finished more than importJobExpiry before
+// the time. The service must be locked.
+func (s *ImportService) expire(now time.Time) {
+	for id, job := range s.jobs {
+		job.lock.Lock()
+		finished := job.finished
+		job.lock.Unlock()
+		if !finished.IsZero() && now.Sub(finished) > importJobExpiry {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+func (s *ImportService) run(job *ImportJob, records []ImportRecord) {
+	seen := make(map[string]int, len(records))
+	for _, record := range records {
+		result := ImportRowResult{Row: record.Row, Title: record.Title}
+		key := duplicateKey(record)
+		if err := validateRecord(record); err != nil {
+			result.Status, result.Reason = ImportFailed, err.Error()
+		} else if row, found := seen[key]; found {
+			result.Status, result.Reason = ImportSkipped, fmt.Sprintf("Duplicate of row %v.", row)
+		} else if exists, err := s.target.Exists(record); err != nil {
+			result.Status, result.Reason = ImportFailed, err.Error()
+		} else if exists {
+			result.Status, result.Reason = ImportSkipped, "Display already exists."
+		} else if job.DryRun {
+			result.Status = ImportValid
+		} else if err := s.target.Create(job.UserID, record); err != nil {
+			result.Status, result.Reason = ImportFailed, err.Error()
+		} else {
+			result.Status = ImportCreated
+		}
+		if result.Status != ImportFailed && result.Status != ImportSkipped {
+			seen[key] = record.Row
+		}
+		job.add(result)
+	}
+	job.lock.Lock()
+	job.finished = time.Now()
+	job.lock.Unlock()
+	app.Log(app.Info, "Import %v finished %v rows in %v.", job.ID, job.total, time.Since(job.Started))
+}
+
+// Progress gets a snapshot of the job's progress and results so far.
+func (j *ImportJob) Progress() ImportProgress {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	p := ImportProgress{
+		ID:        j.ID,
+		DryRun:    j.DryRun,
+		Total:     j.total,
+		Processed: len(j.results),
+		Done:      !j.finished.IsZero(),
+		Results:   make([]ImportRowResult, len(j.results)),
+	}
+	copy(p.Results, j.results)
+	for _, r := range j.results {
+		switch r.Status {
+		case ImportCreated:
+			p.Created++
+		case ImportSkipped:
+			p.Skipped++
+		case ImportFailed:
+			p.Failed++
+		}
+	}
+	return p
+}
+
+func (j *ImportJob) add(result ImportRowResult) {
+	j.lock.Lock()
+	j.results = append(j.results, result)
+	j.lock.Unlock()
+}
+
+func validateRecord(record ImportRecord) error {
+	if len(record.Title) == 0 {
+		return errors.New("Title is required.")
+	}
+	return record.Validate()
+}
+
+// duplicateKey identifies displays with the same title within about ten meters.
+func duplicateKey(record ImportRecord) string {
+	return fmt.Sprintf("%v|%.4f|%.4f", strings.ToLower(record.Title), record.Latitude, record.Longitude)
+}
+
+func createJobID() string {
+	rb := make([]byte, 8)
+	rand.Read(rb)
+	return hex.EncodeToString(rb)
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryTarget an import target which keeps the created records.
+type memoryTarget struct {
+	lock    sync.Mutex
+	created []ImportRecord
+}
+
+func (m *memoryTarget) Exists(record ImportRecord) (bool, error) { return false, nil }
+
+func (m *memoryTarget) Create(userID int64, record ImportRecord) error {
+	m.lock.Lock()
+	m.created = append(m.created, record)
+	m.lock.Unlock()
+	return nil
+}
+
+const nonFiniteCSV = `title,lat,lon
+Candy Cane Lane,41.88,-87.63
+Not a number,NaN,-87.63
+Nowhere east,41.88,Inf
+Nowhere south,-Inf,-87.63
+Infinity,41.88,-infinity
+`
+
+func TestImportRejectsNonFiniteCoordinates(t *testing.T) {
+	for _, dryRun := range []bool{true, false} {
+		target := &memoryTarget{}
+		s := NewImportService(target)
+		records, failed, err := s.ParseCSV(strings.NewReader(nonFiniteCSV),
+			ColumnMapping{ImportTitle: "title", ImportLatitude: "lat", ImportLongitude: "lon"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		progress := waitForImport(t, s.Start(1, records, failed, dryRun))
+
+		if progress.Failed != 4 || progress.Total != 5 {
+			t.Errorf("Dry run %v: %v of %v rows failed, expected 4 of 5", dryRun, progress.Failed, progress.Total)
+		}
+		for _, r := range progress.Results {
+			if r.Title != "Candy Cane Lane" && r.Status != ImportFailed {
+				t.Errorf("Dry run %v: row %v %q is %v, expected it to fail", dryRun, r.Row, r.Title, r.Status)
+			}
+		}
+		if n := len(target.created); dryRun && n != 0 || !dryRun && n != 1 {
+			t.Errorf("Dry run %v: created %v displays", dryRun, n)
+		}
+	}
+}
+
+func waitForImport(t *testing.T, job *ImportJob) ImportProgress {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if p := job.Progress(); p.Done {
+			return p
+		}
+	}
+	t.Fatalf("Import %v did not finish", job.ID)
+	return ImportProgress{}
+}
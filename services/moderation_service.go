@@ -0,0 +1,155 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rchargel/localiday/app"
+	"github.com/rchargel/localiday/db"
+)
+
+// ModerationService defines a set of functions for reporting items and
+// moderating the reports.
+type ModerationService struct {
+	repos           *db.Repositories
+	displays        *DisplayService
+	autoHideReports uint32
+}
+
+// NewModerationService creates a pointer to the moderation service using the
+// repositories, which hides and deletes displays through the display service.
+func NewModerationService(repos *db.Repositories, displays *DisplayService) *ModerationService {
+	return &ModerationService{repos, displays, uint32(app.LoadConfiguration().AutoHideReports)}
+}
+
+// Report reports an item on behalf of a user. Once enough different users
+// have reported an item it is hidden until a moderator decides what to do.
+func (m *ModerationService) Report(itemType string, itemID, reporterID int64, reason, comment string) (*db.Report, error) {
+	report, err := db.CreateReport(itemType, itemID, reporterID, reason, comment)
+	if err != nil {
+		return nil, err
+	}
+	if m.autoHideReports > 0 && !db.IsHidden(itemType, itemID) && db.CountActiveReports(itemType, itemID) >= m.autoHideReports {
+		if err = db.SetItemVisibility(itemType, itemID, true, false); err != nil {
+			return report, err
+		}
+		m.reindex(itemType, itemID)
+		err = db.RecordDecision(&db.ModerationDecision{
+			ReportID: report.ID,
+			ItemType: itemType,
+			ItemID:   itemID,
+			Action:   db.ActionAutoHide,
+			Note:     fmt.Sprintf("Hidden after %v reports.", m.autoHideReports),
+		})
+		if owner, found := db.FindItemOwner(itemType, itemID); found && err == nil {
+			m.notify([]int64{owner}, db.NotifyModerated, "Your "+itemName(itemType)+" has been hidden.",
+				"It was reported by several users and is hidden until a moderator reviews it.", itemType, itemID)
+		}
+	}
+	return report, err
+}
+
+// Decide applies a moderator's decision to an open or claimed report and
+// records it. Hiding, deleting and banning resolve every open report against
+// the item, while dismissing restores the item if it had been hidden. Banning
+// deactivates the given user, which for a reported user is the user
+// themselves. Once the decision is saved a deleted display is deleted, and a
+// hidden or restored one taken out of or put back in the index. The reporters
+// are told the outcome of their reports, and the item's owner that it was
+// hidden, deleted or, if it had been hidden, approved.
+func (m *ModerationService) Decide(reportID, moderatorID int64, action, note string, banUserID int64) (*db.ModerationDecision, error) {
+	report, err := db.Report{}.Get(reportID)
+	if err != nil {
+		return nil, fmt.Errorf("Could not find report %v.", reportID)
+	}
+	reporters := []int64{report.ReporterID}
+	if action != db.ActionDismiss {
+		if reporters, err = db.FindOpenReporterIDs(report.ItemType, report.ItemID); err != nil {
+			return nil, err
+		}
+	}
+	wasHidden := db.IsHidden(report.ItemType, report.ItemID)
+
+	decision := &db.ModerationDecision{
+		ReportID:    report.ID,
+		ItemType:    report.ItemType,
+		ItemID:      report.ItemID,
+		ModeratorID: moderatorID,
+		Action:      action,
+		Note:        note,
+	}
+	if action == db.ActionBan {
+		if report.ItemType == db.ItemUser {
+			banUserID = report.ItemID
+		}
+		decision.Note = fmt.Sprintf("Banned user %v. %v", banUserID, note)
+	}
+	if err = report.Decide(decision, banUserID); err != nil {
+		return nil, err
+	}
+
+	if action == db.ActionDelete && report.ItemType == db.ItemDisplay {
+		// the display is already hidden by the decision if it cannot be deleted.
+		if err = m.displays.Delete(report.ItemID); err != nil {
+			app.Log(app.Error, "Could not delete display %v.", report.ItemID, err)
+		}
+	}
+	m.reindex(report.ItemType, report.ItemID)
+	m.notifyDecision(report, action, note, reporters, wasHidden)
+	return decision, nil
+}
+
+// reindex updates the index after the item's visibility has changed, if it
+// is a display.
+func (m *ModerationService) reindex(itemType string, itemID int64) {
+	if itemType == db.ItemDisplay {
+		m.displays.Reindex(itemID)
+	}
+}
+
+// notifyDecision tells the reporters and the owner of the item what a
+// moderator decided.
+func (m *ModerationService) notifyDecision(report *db.Report, action, note string, reporters []int64, wasHidden bool) {
+	name := itemName(report.ItemType)
+	if action == db.ActionDismiss {
+		m.notify(reporters, db.NotifyReported, "Your report was reviewed.",
+			"A moderator reviewed the "+name+" you reported and decided to keep it.", report.ItemType, report.ItemID)
+	} else {
+		m.notify(reporters, db.NotifyReported, "Your report was acted on.",
+			"A moderator has taken action against the "+name+" you reported. Thank you for letting us know.", report.ItemType, report.ItemID)
+	}
+
+	owner, found := db.FindItemOwner(report.ItemType, report.ItemID)
+	if !found || action == db.ActionBan {
+		return
+	}
+	switch action {
+	case db.ActionHide:
+		m.notify([]int64{owner}, db.NotifyModerated, "Your "+name+" has been hidden.", note, report.ItemType, report.ItemID)
+	case db.ActionDelete:
+		m.notify([]int64{owner}, db.NotifyModerated, "Your "+name+" has been deleted.", note, report.ItemType, report.ItemID)
+	case db.ActionDismiss:
+		if wasHidden && !db.IsHidden(report.ItemType, report.ItemID) {
+			m.notify([]int64{owner}, db.NotifyApproved, "Your "+name+" has been approved.",
+				"A moderator reviewed your "+name+" and it is visible again.", report.ItemType, report.ItemID)
+		}
+	}
+}
+
+// notify notifies the users about a moderated item. A notification which
+// cannot be sent is logged, as the decision has already been made.
+func (m *ModerationService) notify(userIDs []int64, notificationType, subject, body, itemType string, itemID int64) {
+	for _, userID := range userIDs {
+		if err := NewNotificationService(m.repos).Notify(userID, notificationType, subject, body, itemType, itemID); err != nil {
+			app.Log(app.Error, "Could not notify user %v about %v %v.", userID, itemType, itemID, err)
+		}
+	}
+}
+
+// itemName the name of an item type in a message.
+func itemName(itemType string) string {
+	if itemType == db.ItemUser {
+		return "profile"
+	}
+	return strings.ToLower(itemType)
+}
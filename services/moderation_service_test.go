@@ -0,0 +1,115 @@
+//go:build sqlite
+// +build sqlite
+
+package services
+
+import (
+	"testing"
+
+	"github.com/rchargel/localiday/db"
+	"github.com/rchargel/localiday/geo"
+)
+
+func TestModerationNotifiesReportersAndOwner(t *testing.T) {
+	useTestDatabase(t)
+	owner, reporter, moderator := createTestUser(t), createTestUser(t), createTestUser(t)
+	display := createTestDisplay(t, owner.ID, 33, -90, "")
+	m := NewModerationService(db.NewDBRepositories(), NewDisplayService(nil))
+
+	report, err := m.Report(db.ItemDisplay, display.ID, reporter.ID, db.ReasonSpam, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.Decide(report.ID, moderator.ID, db.ActionHide, "Advertising.", 0); err != nil {
+		t.Fatal(err)
+	}
+	expectNotification(t, reporter.ID, db.NotifyReported, "Your report was acted on.")
+	expectNotification(t, owner.ID, db.NotifyModerated, "Your display has been hidden.")
+}
+
+func TestModerationApprovesHiddenItem(t *testing.T) {
+	useTestDatabase(t)
+	owner := createTestUser(t)
+	display := createTestDisplay(t, owner.ID, 33.01, -90, "")
+	index := geo.NewIndex()
+	index.Put(display.Marker())
+	m := &ModerationService{repos: db.NewDBRepositories(), displays: NewDisplayService(index), autoHideReports: 1}
+
+	reporter := createTestUser(t)
+	report, err := m.Report(db.ItemDisplay, display.ID, reporter.ID, db.ReasonInaccurate, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectNotification(t, owner.ID, db.NotifyModerated, "Your display has been hidden.")
+	expectShown(t, index, display.ID, false)
+	if _, err = m.Decide(report.ID, owner.ID, db.ActionDismiss, "", 0); err != nil {
+		t.Fatal(err)
+	}
+	expectNotification(t, reporter.ID, db.NotifyReported, "Your report was reviewed.")
+	expectNotification(t, owner.ID, db.NotifyApproved, "Your display has been approved.")
+	expectShown(t, index, display.ID, true)
+	if _, err = m.Decide(report.ID, owner.ID, db.ActionHide, "", 0); err == nil {
+		t.Error("Decided a report which was already dismissed")
+	}
+}
+
+func TestModerationHidesAndDeletesDisplays(t *testing.T) {
+	useTestDatabase(t)
+	owner, reporter, moderator := createTestUser(t), createTestUser(t), createTestUser(t)
+	hidden, deleted := createTestDisplay(t, owner.ID, 33.02, -90, ""), createTestDisplay(t, owner.ID, 33.03, -90, "")
+	index := geo.NewIndex()
+	index.Load([]geo.Marker{hidden.Marker(), deleted.Marker()})
+	m := NewModerationService(db.NewDBRepositories(), NewDisplayService(index))
+
+	for display, action := range map[*db.Display]string{hidden: db.ActionHide, deleted: db.ActionDelete} {
+		report, err := m.Report(db.ItemDisplay, display.ID, reporter.ID, db.ReasonSpam, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = m.Decide(report.ID, moderator.ID, action, "", 0); err != nil {
+			t.Fatal(err)
+		}
+		expectShown(t, index, display.ID, false)
+		if found, _ := db.FindFavoriteDisplays(owner.ID); len(found) != 0 {
+			t.Errorf("Found %v favorites after %v", len(found), action)
+		}
+		results, err := NewDisplayService(nil).Search(DisplaySearch{Center: &geo.Point{Latitude: 33.025, Longitude: -90}, RadiusKm: 5})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range results {
+			if r.ID == display.ID {
+				t.Errorf("Search found display %v after %v", display.ID, action)
+			}
+		}
+	}
+	if n, _ := db.DB.SelectInt("select count(*) from displays where id = $1 and deleted_at is not null", deleted.ID); n != 1 {
+		t.Error("Deleting a display should soft delete it")
+	}
+	if owner, found := db.FindItemOwner(db.ItemDisplay, deleted.ID); !found || owner == 0 {
+		t.Error("The owner of a deleted display should still be found")
+	}
+}
+
+// expectShown checks that the display is in the index, and can be found, if
+// it is shown.
+func expectShown(t *testing.T, index *geo.Index, displayID int64, shown bool) {
+	_, indexed := index.Get(displayID)
+	_, err := db.Display{}.Get(displayID)
+	if indexed != shown || (err == nil) != shown {
+		t.Errorf("Display %v is indexed %v and found %v, expected %v", displayID, indexed, err == nil, shown)
+	}
+}
+
+// expectNotification checks the user's newest notification.
+func expectNotification(t *testing.T, userID int64, notificationType, subject string) {
+	inbox, err := NewNotificationService(db.NewDBRepositories()).Inbox(userID, false, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inbox) == 0 {
+		t.Errorf("User %v was not notified, expected %v", userID, subject)
+	} else if inbox[0].NotificationType != notificationType || inbox[0].Subject != subject {
+		t.Errorf("User %v was notified %v %q, expected %v %q", userID, inbox[0].NotificationType, inbox[0].Subject, notificationType, subject)
+	}
+}
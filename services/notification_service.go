@@ -0,0 +1,181 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"sync"
+
+	"github.com/rchargel/localiday/app"
+	"github.com/rchargel/localiday/db"
+)
+
+// NotificationChannel delivers notifications to users by some means other
+// than the inbox.
+type NotificationChannel interface {
+	Deliver(user *db.User, n *db.Notification) error
+}
+
+// channels the channels each delivery preference sends through, besides the
+// inbox.
+var channels = struct {
+	sync.RWMutex
+	byDelivery map[string][]NotificationChannel
+}{byDelivery: map[string][]NotificationChannel{db.DeliverEmail: {EmailChannel{}}}}
+
+// RegisterChannel adds a channel to the ones notifications are sent through
+// for a delivery preference.
+func RegisterChannel(delivery string, channel NotificationChannel) {
+	channels.Lock()
+	channels.byDelivery[delivery] = append(channels.byDelivery[delivery], channel)
+	channels.Unlock()
+}
+
+// EmailChannel delivers notifications by email through the configured SMTP
+// server. Nothing is sent if no server is configured.
+type EmailChannel struct{}
+
+// Deliver emails the notification to the user.
+func (e EmailChannel) Deliver(user *db.User, n *db.Notification) error {
+	config := app.LoadConfiguration()
+	if len(config.SMTPServer) == 0 {
+		app.Log(app.Debug, "No SMTP server configured, not emailing %v.", user.Username)
+		return nil
+	}
+	if len(user.Email) == 0 {
+		return fmt.Errorf("User %v has no email address.", user.Username)
+	}
+	var auth smtp.Auth
+	if len(config.SMTPUser) > 0 {
+		host, _, err := net.SplitHostPort(config.SMTPServer)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", config.SMTPUser, config.SMTPPass, host)
+	}
+	message, err := emailMessage(config.MailFrom, user.Email, n)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(config.SMTPServer, auth, config.MailFrom, []string{user.Email}, message)
+}
+
+// emailMessage writes the notification as an email. The addresses must be
+// valid, and the subject is encoded so that it cannot break out of its header.
+func emailMessage(from, to string, n *db.Notification) ([]byte, error) {
+	fromAddress, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid sender address %q: %v.", from, err)
+	}
+	toAddress, err := mail.ParseAddress(to)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid email address %q: %v.", to, err)
+	}
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %v\r\nTo: %v\r\nSubject: %v\r\n", fromAddress, toAddress, headerValue(n.Subject))
+	message.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n")
+	body := quotedprintable.NewWriter(&message)
+	body.Write([]byte(n.Body))
+	body.Close()
+	message.WriteString("\r\n")
+	return message.Bytes(), nil
+}
+
+// headerValue encodes a header value as a MIME encoded word when it is not
+// plain text, after replacing any line breaks, which would start a new header.
+func headerValue(value string) string {
+	value = strings.Map(func(r rune) rune {
+		if r == '\r' || r == '\n' {
+			return ' '
+		}
+		return r
+	}, value)
+	return mime.QEncoding.Encode("utf-8", value)
+}
+
+// NotificationService defines a set of functions for notifying users and
+// reading their inbox.
+type NotificationService struct {
+	repos *db.Repositories
+}
+
+// NewNotificationService creates a pointer to the notification service using
+// the repositories.
+func NewNotificationService(repos *db.Repositories) *NotificationService {
+	return &NotificationService{repos}
+}
+
+// Notify notifies a user as they prefer for the type of notification. The
+// notification is kept in the inbox unless the type is turned off, and is
+// sent through any other channels in the background.
+func (s *NotificationService) Notify(userID int64, notificationType, subject, body, itemType string, itemID int64) error {
+	delivery := db.GetDelivery(userID, notificationType)
+	if delivery == db.DeliverOff {
+		return nil
+	}
+	n := &db.Notification{
+		UserID:           userID,
+		NotificationType: notificationType,
+		Subject:          subject,
+		Body:             body,
+		ItemType:         itemType,
+		ItemID:           itemID,
+	}
+	if err := db.CreateNotification(n); err != nil {
+		return err
+	}
+
+	channels.RLock()
+	deliverTo := channels.byDelivery[delivery]
+	channels.RUnlock()
+	if len(deliverTo) > 0 {
+		go func() {
+			user, err := s.repos.Users.Get(userID)
+			if err != nil {
+				app.Log(app.Error, "Could not find user %v to notify.", userID, err)
+				return
+			}
+			for _, channel := range deliverTo {
+				if err := channel.Deliver(user, n); err != nil {
+					app.Log(app.Error, "Could not deliver notification %v.", n.ID, err)
+				}
+			}
+		}()
+	}
+	return nil
+}
+
+// Inbox gets a page of the user's notifications.
+func (s *NotificationService) Inbox(userID int64, unreadOnly bool, offset, max int) ([]db.Notification, error) {
+	if max <= 0 {
+		return nil, errors.New("The page size must be positive.")
+	}
+	return db.FindNotifications(userID, unreadOnly, offset, max)
+}
+
+// UnreadCount counts the user's unread notifications.
+func (s *NotificationService) UnreadCount(userID int64) uint32 {
+	return db.CountUnreadNotifications(userID)
+}
+
+// MarkRead marks the user's notifications as read, or all of them if no IDs
+// are given.
+func (s *NotificationService) MarkRead(userID int64, ids []int64) error {
+	return db.MarkNotificationsRead(userID, ids)
+}
+
+// Preferences gets how the user wants each type of notification delivered.
+func (s *NotificationService) Preferences(userID int64) (map[string]string, error) {
+	return db.FindDeliveries(userID)
+}
+
+// SetPreference sets how the user wants a type of notification delivered.
+func (s *NotificationService) SetPreference(userID int64, notificationType, delivery string) error {
+	return db.SetDelivery(userID, notificationType, delivery)
+}
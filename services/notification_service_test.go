@@ -0,0 +1,60 @@
+package services
+
+import (
+	"mime"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/rchargel/localiday/db"
+)
+
+func TestEmailMessageHeaders(t *testing.T) {
+	n := &db.Notification{
+		Subject: "Hello\r\nBcc: victim@example.com",
+		Body:    "Your display is lit.\r\nBcc: nobody@example.com",
+	}
+	message, err := emailMessage("Localiday <noreply@localiday.com>", "user@example.com", n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := mail.ReadMessage(strings.NewReader(string(message)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bcc := parsed.Header.Get("Bcc"); len(bcc) > 0 {
+		t.Errorf("The subject added a Bcc header: %v", bcc)
+	}
+	if subject := parsed.Header.Get("Subject"); subject != "Hello  Bcc: victim@example.com" {
+		t.Errorf("Unexpected subject %q", subject)
+	}
+	if to := parsed.Header.Get("To"); to != "<user@example.com>" {
+		t.Errorf("Unexpected recipient %q", to)
+	}
+}
+
+func TestEmailMessageEncodesSubject(t *testing.T) {
+	message, err := emailMessage("noreply@localiday.com", "user@example.com", &db.Notification{Subject: "Frohe Weihnachten ☃", Body: "Grüße"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := mail.ReadMessage(strings.NewReader(string(message)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := parsed.Header.Get("Subject")
+	if !strings.HasPrefix(raw, "=?utf-8?q?") {
+		t.Errorf("The subject should be encoded, got %q", raw)
+	}
+	if subject, err := new(mime.WordDecoder).DecodeHeader(raw); err != nil || subject != "Frohe Weihnachten ☃" {
+		t.Errorf("The subject decoded as %q: %v", subject, err)
+	}
+}
+
+func TestEmailMessageRejectsInvalidAddresses(t *testing.T) {
+	for _, to := range []string{"", "user@example.com\r\nBcc: victim@example.com", "not an address"} {
+		if _, err := emailMessage("noreply@localiday.com", to, &db.Notification{Subject: "Hi"}); err == nil {
+			t.Errorf("Emailing %q should fail", to)
+		}
+	}
+}
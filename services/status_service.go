@@ -0,0 +1,166 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/rchargel/localiday/db"
+)
+
+const (
+	// StatusUnknown the status of a display without enough recent reports.
+	StatusUnknown = "UNKNOWN"
+
+	// statusThreshold the decayed, trust weighted score a status needs before
+	// it is believed.
+	statusThreshold = 0.5
+	// statusWindow how far back reports are read. Older reports have decayed
+	// to nothing.
+	statusWindow = 14 * 24 * time.Hour
+
+	maxReportsPerHour     = 10
+	displayReportInterval = 15 * time.Minute
+	trustedReportAge      = 24 * time.Hour
+	baseTrust             = 0.5
+	trustPerReport        = 0.05
+	maxTrust              = 1.0
+	minTrust              = 0.1
+)
+
+// statusHalfLives how quickly belief in each status fades. Whether the lights
+// are on changes nightly, while a display being taken down lasts.
+var statusHalfLives = map[string]time.Duration{
+	db.StatusLit:        2 * time.Hour,
+	db.StatusDark:       2 * time.Hour,
+	db.StatusRoadClosed: 12 * time.Hour,
+	db.StatusGone:       7 * 24 * time.Hour,
+}
+
+// DisplayStatus the current status of a display derived from recent reports.
+// Confidence is between 0 and 1.
+type DisplayStatus struct {
+	DisplayID  int64
+	Status     string
+	Confidence float64
+	Reports    int
+	LastReport time.Time
+}
+
+// StatusService defines a set of functions for reporting and deriving the
+// live status of displays.
+type StatusService struct {
+	trust map[int64]float64
+}
+
+// NewStatusService creates a pointer to the status service.
+func NewStatusService() *StatusService {
+	return &StatusService{make(map[int64]float64, 10)}
+}
+
+// Report records a user's report of a display's status. A user may report a
+// single display only once every 15 minutes, and only 10 displays an hour.
+func (s *StatusService) Report(displayID, userID int64, status string) (*db.StatusReport, error) {
+	now := time.Now()
+	if db.CountStatusReportsByUser(userID, displayID, now.Add(-displayReportInterval)) > 0 {
+		return nil, errors.New("You have already reported the status of this display recently.")
+	}
+	if db.CountStatusReportsByUser(userID, 0, now.Add(-time.Hour)) >= maxReportsPerHour {
+		return nil, errors.New("You have made too many status reports, please try again later.")
+	}
+	report, err := db.CreateStatusReport(displayID, userID, status)
+	if err != nil {
+		return nil, err
+	}
+	err = db.RecordActivity(&db.Activity{
+		UserID:       userID,
+		ActivityType: db.ActivityStatus,
+		ItemID:       report.ID,
+		Summary:      fmt.Sprintf("Reported display %v as %v.", displayID, status),
+	})
+	return report, err
+}
+
+// CurrentStatus gets the current status of a display.
+func (s *StatusService) CurrentStatus(displayID int64) (DisplayStatus, error) {
+	statuses, err := s.CurrentStatuses([]int64{displayID})
+	return statuses[displayID], err
+}
+
+// CurrentStatuses gets the current status of each display, for use in search
+// results.
+func (s *StatusService) CurrentStatuses(displayIDs []int64) (map[int64]DisplayStatus, error) {
+	now := time.Now()
+	statuses := make(map[int64]DisplayStatus, len(displayIDs))
+	for _, id := range displayIDs {
+		statuses[id] = DisplayStatus{DisplayID: id, Status: StatusUnknown}
+	}
+	reports, err := db.FindRecentStatusReports(displayIDs, now.Add(-statusWindow))
+	if err != nil {
+		return statuses, err
+	}
+	byDisplay := make(map[int64][]db.StatusReport, len(displayIDs))
+	for _, r := range reports {
+		byDisplay[r.DisplayID] = append(byDisplay[r.DisplayID], r)
+	}
+	for id, displayReports := range byDisplay {
+		statuses[id] = DeriveStatus(id, displayReports, s.reporterTrust, now)
+	}
+	return statuses, nil
+}
+
+// DeriveStatus derives the status of a display from its reports, newest first.
+// Each report counts for its reporter's trust, halving with every half life of
+// the reported status. The strongest status wins if it passes the threshold,
+// and its confidence is its share of all of the remaining belief.
+func DeriveStatus(displayID int64, reports []db.StatusReport, trust func(userID int64) float64, now time.Time) DisplayStatus {
+	result := DisplayStatus{DisplayID: displayID, Status: StatusUnknown, Reports: len(reports)}
+	if len(reports) == 0 {
+		return result
+	}
+	result.LastReport = reports[0].Created
+
+	scores := make(map[string]float64, len(statusHalfLives))
+	total := 0.0
+	for _, r := range reports {
+		halfLife, found := statusHalfLives[r.Status]
+		if !found {
+			continue
+		}
+		age := now.Sub(r.Created)
+		if age < 0 {
+			age = 0
+		}
+		score := trust(r.UserID) * math.Pow(0.5, float64(age)/float64(halfLife))
+		scores[r.Status] += score
+		total += score
+	}
+
+	best, bestScore := StatusUnknown, 0.0
+	for status, score := range scores {
+		if score > bestScore {
+			best, bestScore = status, score
+		}
+	}
+	if bestScore >= statusThreshold {
+		result.Status = best
+		result.Confidence = bestScore / total * math.Min(1, bestScore)
+	}
+	return result
+}
+
+// reporterTrust gets how far a reporter is trusted. Trust grows with the
+// number of reports the user has made, and is halved for every upheld
+// moderation report against them.
+func (s *StatusService) reporterTrust(userID int64) float64 {
+	if trust, found := s.trust[userID]; found {
+		return trust
+	}
+	past := db.CountPastStatusReports(userID, time.Now().Add(-trustedReportAge))
+	trust := math.Min(maxTrust, baseTrust+trustPerReport*float64(past))
+	trust *= math.Pow(0.5, float64(db.CountUpheldReports(db.ItemUser, userID)))
+	trust = math.Max(minTrust, trust)
+	s.trust[userID] = trust
+	return trust
+}
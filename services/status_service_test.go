@@ -0,0 +1,57 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/rchargel/localiday/db"
+)
+
+// statusReport a report of the status by the user, the age before now.
+func statusReport(userID int64, status string, age time.Duration, now time.Time) db.StatusReport {
+	return db.StatusReport{UserID: userID, Status: status, Audited: db.Audited{Created: now.Add(-age)}}
+}
+
+func TestDeriveStatus(t *testing.T) {
+	now := time.Date(2026, 12, 20, 20, 0, 0, 0, time.UTC)
+	// users 1 and 2 are fully trusted, 3 and 4 are new.
+	trust := func(userID int64) float64 {
+		if userID > 2 {
+			return 0.4
+		}
+		return 1
+	}
+	tests := []struct {
+		name       string
+		reports    []db.StatusReport
+		status     string
+		confidence float64
+	}{
+		{"no reports", nil, StatusUnknown, 0},
+		{"fresh report", []db.StatusReport{statusReport(1, db.StatusLit, 0, now)}, db.StatusLit, 1},
+		{"one half life", []db.StatusReport{statusReport(1, db.StatusLit, 2*time.Hour, now)}, db.StatusLit, 0.5},
+		{"past the threshold", []db.StatusReport{statusReport(1, db.StatusLit, 2*time.Hour+time.Minute, now)}, StatusUnknown, 0},
+		{"a night later", []db.StatusReport{statusReport(1, db.StatusDark, 24*time.Hour, now)}, StatusUnknown, 0},
+		{"gone lasts", []db.StatusReport{statusReport(1, db.StatusGone, 3*24*time.Hour, now)}, db.StatusGone, math.Pow(0.5, 3.0/7)},
+		{"road closed half life", []db.StatusReport{statusReport(1, db.StatusRoadClosed, 12*time.Hour, now)}, db.StatusRoadClosed, 0.5},
+		{"untrusted reporter", []db.StatusReport{statusReport(3, db.StatusLit, 0, now)}, StatusUnknown, 0},
+		{"two untrusted reporters", []db.StatusReport{statusReport(3, db.StatusLit, 0, now), statusReport(4, db.StatusLit, 0, now)}, db.StatusLit, 0.8},
+		{"newer report wins", []db.StatusReport{statusReport(1, db.StatusLit, 0, now), statusReport(2, db.StatusDark, 2*time.Hour, now)}, db.StatusLit, 1 / 1.5},
+		{"reported in the future", []db.StatusReport{statusReport(1, db.StatusDark, -time.Hour, now)}, db.StatusDark, 1},
+		{"unknown status", []db.StatusReport{statusReport(1, "FLICKERING", 0, now)}, StatusUnknown, 0},
+	}
+	for _, test := range tests {
+		actual := DeriveStatus(42, test.reports, trust, now)
+		if actual.Status != test.status || math.Abs(actual.Confidence-test.confidence) > 1e-9 {
+			t.Errorf("%v: status %v with confidence %v, expected %v with confidence %v",
+				test.name, actual.Status, actual.Confidence, test.status, test.confidence)
+		}
+		if actual.DisplayID != 42 || actual.Reports != len(test.reports) {
+			t.Errorf("%v: display %v with %v reports", test.name, actual.DisplayID, actual.Reports)
+		}
+		if len(test.reports) > 0 && !actual.LastReport.Equal(test.reports[0].Created) {
+			t.Errorf("%v: last report at %v, expected the newest", test.name, actual.LastReport)
+		}
+	}
+}
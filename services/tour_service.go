@@ -0,0 +1,206 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rchargel/localiday/db"
+	"github.com/rchargel/localiday/geo"
+)
+
+const (
+	// MaxTourStops the most displays a tour may visit. Planning is quadratic in
+	// the number of stops for every 2-opt pass.
+	MaxTourStops = 50
+
+	maxTwoOptPasses = 50
+)
+
+// TourStop a display to be visited on a tour.
+type TourStop struct {
+	DisplayID int64
+	geo.Point
+}
+
+// TourLeg a single leg of the tour, distances are in kilometers.
+type TourLeg struct {
+	From     geo.Point
+	To       geo.Point
+	ToStop   int64
+	Distance float64
+}
+
+// Tour the planned order in which to visit a set of displays.
+type Tour struct {
+	Start         geo.Point
+	ReturnHome    bool
+	Stops         []TourStop
+	Legs          []TourLeg
+	TotalDistance float64
+}
+
+// TourService defines a set of functions for planning tours of displays.
+type TourService struct{}
+
+// NewTourService creates a pointer to the tour service.
+func NewTourService() *TourService {
+	return &TourService{}
+}
+
+// PlanDisplays plans a tour of the displays with the IDs, see PlanTour.
+func (t *TourService) PlanDisplays(start geo.Point, displayIDs []int64, returnHome bool) (*Tour, error) {
+	stops, err := t.StopsFor(displayIDs)
+	if err != nil {
+		return nil, err
+	}
+	return t.PlanTour(start, stops, returnHome)
+}
+
+// StopsFor gets the displays with the IDs as tour stops, in the order given.
+// Every display must exist and appear only once.
+func (t *TourService) StopsFor(displayIDs []int64) ([]TourStop, error) {
+	if err := checkStopCount(len(displayIDs)); err != nil {
+		return nil, err
+	}
+	displays, err := db.Display{}.FindByIDs(displayIDs)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]*db.Display, len(displays))
+	for i := range displays {
+		byID[displays[i].ID] = &displays[i]
+	}
+	seen := make(map[int64]bool, len(displayIDs))
+	stops := make([]TourStop, len(displayIDs))
+	for i, id := range displayIDs {
+		display, found := byID[id]
+		if !found {
+			return nil, fmt.Errorf("Could not find display %v.", id)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("Display %v is on the tour more than once.", id)
+		}
+		seen[id] = true
+		stops[i] = TourStop{DisplayID: id, Point: display.Point()}
+	}
+	return stops, nil
+}
+
+// PlanTour orders the stops to minimize the distance travelled from the start
+// point. A nearest-neighbour route is built first, which is then improved with
+// 2-opt until no reversal of the route makes it shorter. If returnHome is set
+// the tour ends back at the start point. A tour has at most MaxTourStops.
+func (t *TourService) PlanTour(start geo.Point, stops []TourStop, returnHome bool) (*Tour, error) {
+	if err := start.Validate(); err != nil {
+		return nil, err
+	}
+	if err := checkStopCount(len(stops)); err != nil {
+		return nil, err
+	}
+	for _, stop := range stops {
+		if err := stop.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	route := nearestNeighbour(start, stops)
+	path := make([]geo.Point, 0, len(route)+2)
+	path = append(path, start)
+	for _, stop := range route {
+		path = append(path, stop.Point)
+	}
+	if returnHome {
+		path = append(path, start)
+	}
+	twoOpt(path, route, returnHome)
+
+	return NewTour(start, route, returnHome), nil
+}
+
+func checkStopCount(n int) error {
+	if n == 0 {
+		return errors.New("A tour requires at least one stop.")
+	}
+	if n > MaxTourStops {
+		return fmt.Errorf("A tour may have at most %v stops.", MaxTourStops)
+	}
+	return nil
+}
+
+// NewTour creates a tour which visits the stops in the order given,
+// calculating the distance of each leg.
+func NewTour(start geo.Point, stops []TourStop, returnHome bool) *Tour {
+	tour := &Tour{Start: start, ReturnHome: returnHome, Stops: stops, Legs: make([]TourLeg, 0, len(stops)+1)}
+	from := start
+	for _, stop := range stops {
+		tour.addLeg(from, stop.Point, stop.DisplayID)
+		from = stop.Point
+	}
+	if returnHome {
+		tour.addLeg(from, start, 0)
+	}
+	return tour
+}
+
+func (t *Tour) addLeg(from, to geo.Point, stopID int64) {
+	leg := TourLeg{From: from, To: to, ToStop: stopID, Distance: geo.Distance(from, to)}
+	t.Legs = append(t.Legs, leg)
+	t.TotalDistance += leg.Distance
+}
+
+func nearestNeighbour(start geo.Point, stops []TourStop) []TourStop {
+	remaining := make([]TourStop, len(stops))
+	copy(remaining, stops)
+	route := make([]TourStop, 0, len(stops))
+
+	current := start
+	for len(remaining) > 0 {
+		closest := 0
+		for i := 1; i < len(remaining); i++ {
+			if geo.Distance(current, remaining[i].Point) < geo.Distance(current, remaining[closest].Point) {
+				closest = i
+			}
+		}
+		route = append(route, remaining[closest])
+		current = remaining[closest].Point
+		remaining = append(remaining[:closest], remaining[closest+1:]...)
+	}
+	return route
+}
+
+// twoOpt improves the route in place. The path holds the start point, the
+// stops in route order and, for a round trip, the start point again, so that
+// path[i] is the location of route[i-1].
+func twoOpt(path []geo.Point, route []TourStop, closed bool) {
+	last := len(path) - 1
+	if closed {
+		last--
+	}
+	for pass := 0; pass < maxTwoOptPasses; pass++ {
+		improved := false
+		for i := 1; i < last; i++ {
+			for j := i + 1; j <= last; j++ {
+				before := geo.Distance(path[i-1], path[i])
+				after := geo.Distance(path[i-1], path[j])
+				if j < len(path)-1 {
+					before += geo.Distance(path[j], path[j+1])
+					after += geo.Distance(path[i], path[j+1])
+				}
+				if after < before-1e-9 {
+					reverse(path, route, i, j)
+					improved = true
+				}
+			}
+		}
+		if !improved {
+			return
+		}
+	}
+}
+
+func reverse(path []geo.Point, route []TourStop, i, j int) {
+	for ; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+		route[i-1], route[j-1] = route[j-1], route[i-1]
+	}
+}
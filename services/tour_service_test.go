@@ -0,0 +1,60 @@
+//go:build sqlite
+// +build sqlite
+
+package services
+
+import (
+	"testing"
+
+	"github.com/rchargel/localiday/db"
+	"github.com/rchargel/localiday/geo"
+)
+
+func TestPlanTourOfDisplays(t *testing.T) {
+	useTestDatabase(t)
+	user := createTestUser(t)
+	far := createTestDisplay(t, user.ID, 37.03, -88, "")
+	near := createTestDisplay(t, user.ID, 37.01, -88, "")
+	middle := createTestDisplay(t, user.ID, 37.02, -88, "")
+	start := geo.Point{Latitude: 37, Longitude: -88}
+	s := NewTourService()
+
+	tour, err := s.PlanDisplays(start, []int64{far.ID, near.ID, middle.ID}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, expected := range []*db.Display{near, middle, far} {
+		if tour.Stops[i].DisplayID != expected.ID {
+			t.Errorf("Stop %v is display %v, expected %v", i+1, tour.Stops[i].DisplayID, expected.ID)
+		}
+		if tour.Stops[i].Point != expected.Point() {
+			t.Errorf("Stop %v is at %v, not at its display", i+1, tour.Stops[i].Point)
+		}
+	}
+
+	for _, ids := range [][]int64{nil, {near.ID, 999999}, {near.ID, near.ID}} {
+		if _, err = s.PlanDisplays(start, ids, false); err == nil {
+			t.Errorf("Planning a tour of %v should fail", ids)
+		}
+	}
+	if _, err = s.PlanDisplays(geo.Point{Latitude: 91}, []int64{near.ID}, false); err == nil {
+		t.Error("Planning a tour from an invalid start should fail")
+	}
+}
+
+func TestTourStopsAreLimited(t *testing.T) {
+	useTestDatabase(t)
+	user := createTestUser(t)
+	ids := make([]int64, MaxTourStops+1)
+	for i := range ids {
+		ids[i] = createTestDisplay(t, user.ID, 37.5+float64(i)/1000, -88.5, "").ID
+	}
+	start := geo.Point{Latitude: 37.5, Longitude: -88.5}
+	s := NewTourService()
+	if _, err := s.PlanDisplays(start, ids[:MaxTourStops], false); err != nil {
+		t.Errorf("Could not plan a tour of %v stops: %v", MaxTourStops, err)
+	}
+	if _, err := s.PlanDisplays(start, ids, false); err == nil {
+		t.Errorf("Planned a tour of %v stops", len(ids))
+	}
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"strings"
 
 	"github.com/rchargel/goauth"
@@ -10,37 +11,35 @@ import (
 )
 
 // UserService defines a set of functions to simplify working with user data.
-type UserService struct{}
+type UserService struct {
+	repos *db.Repositories
+}
 
-// NewUserService creates a pointer to the user service.
-func NewUserService() *UserService {
-	return &UserService{}
+// NewUserService creates a pointer to the user service using the repositories.
+func NewUserService(repos *db.Repositories) *UserService {
+	return &UserService{repos}
 }
 
 // CreateSessionForOAuthUser creates a session for an oauth user.
 func (u *UserService) CreateSessionForOAuthUser(guser goauth.UserData) (*db.Session, error) {
-	user, err := db.User{}.FindByUsername(guser.UserID)
+	user, err := u.repos.Users.FindByUsername(guser.UserID)
 	if err != nil {
 		rb := make([]byte, 20)
 		rand.Read(rb)
-		user, err = db.CreateNewUser(guser.UserID, base64.StdEncoding.EncodeToString(rb), guser.FullName, guser.ScreenName, guser.Email)
+		user, err = u.repos.Users.Create(guser.UserID, base64.StdEncoding.EncodeToString(rb), guser.FullName, guser.ScreenName, guser.Email)
 		if err == nil {
-			userRole, err := db.Role{}.FindByAuthority(db.RoleUser)
-			if err == nil {
-				db.AddAuthorityToUser(user, userRole)
-			}
-			oauthRole, err := db.Role{}.FindByAuthority(db.RoleOAuthUser)
-			if err == nil {
-				db.AddAuthorityToUser(user, oauthRole)
-			}
-			providerRole, err := db.Role{}.FindByAuthority(strings.ToUpper(guser.OAuthProvider) + "_USER")
-			if err == nil {
-				db.AddAuthorityToUser(user, providerRole)
+			for _, authority := range []string{db.RoleUser, db.RoleOAuthUser, strings.ToUpper(guser.OAuthProvider) + "_USER"} {
+				if role, err := u.repos.Roles.FindByAuthority(authority); err == nil {
+					u.repos.Roles.AddToUser(user, role)
+				}
 			}
 		}
 	}
+	if err == nil && !user.Active {
+		err = errors.New("This account has been disabled.")
+	}
 	if err == nil {
-		return db.CreateNewOAuthSession(user.ID, guser.OAuthToken, guser.OAuthProvider), nil
+		return u.repos.Sessions.CreateOAuth(user.ID, guser.OAuthToken, guser.OAuthProvider), nil
 	}
 	return nil, err
 }
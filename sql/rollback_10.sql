@@ -0,0 +1,12 @@
+alter table tours drop column deleted_at;
+alter table tours drop column version;
+alter table tours drop column updated;
+
+alter table roles drop column deleted_at;
+alter table roles drop column version;
+alter table roles drop column updated;
+alter table roles drop column created;
+
+alter table users drop column deleted_at;
+alter table users drop column version;
+alter table users drop column updated;
@@ -0,0 +1,5 @@
+drop index displays_title_prefix_idx;
+drop trigger displays_search_trg on displays;
+drop function display_search_update();
+drop table display_search;
+drop function display_document(varchar, varchar, varchar, varchar);
@@ -0,0 +1,15 @@
+alter table check_ins drop column deleted_at;
+alter table check_ins drop column version;
+alter table check_ins drop column updated;
+
+alter table contests drop column deleted_at;
+alter table contests drop column version;
+alter table contests drop column updated;
+
+alter table status_reports drop column deleted_at;
+alter table status_reports drop column version;
+alter table status_reports drop column updated;
+
+alter table reports drop column deleted_at;
+alter table reports drop column version;
+alter table reports drop column updated;
@@ -0,0 +1,2 @@
+drop table if exists tour_stops;
+drop table if exists tours;
@@ -0,0 +1,3 @@
+drop table if exists moderation_decisions;
+drop table if exists moderated_items;
+drop table if exists reports;
@@ -0,0 +1,5 @@
+drop table if exists contest_votes;
+drop table if exists contest_entries;
+drop table if exists contests;
+
+alter table users drop column if exists created;
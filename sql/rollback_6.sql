@@ -0,0 +1,3 @@
+drop table if exists activities;
+drop table if exists followed_areas;
+drop table if exists follows;
@@ -0,0 +1,2 @@
+drop table if exists user_badges;
+drop table if exists check_ins;
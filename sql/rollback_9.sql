@@ -0,0 +1,7 @@
+create table application (
+  id serial primary key,
+  version integer not null,
+  application_name varchar(100) not null
+);
+
+insert into application (version, application_name) values (8, 'localiday');
@@ -0,0 +1 @@
+drop index displays_title_prefix_idx;
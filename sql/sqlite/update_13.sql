@@ -0,0 +1,3 @@
+-- sqlite has no full-text search built in, displays are matched with like,
+-- which ignores case and can use an index on the title for autocomplete.
+create index displays_title_prefix_idx on displays(title collate nocase);
@@ -49,5 +49,3 @@ create table sessions (
 create unique index sessions_session_id_idx on sessions(session_id);
 create unique index sessions_user_id_idx on sessions(user_id);
 create index sessions_last_accessed_idx on sessions(session_id, last_accessed);
-
-update application set version = 1 where application_name = 'localiday';
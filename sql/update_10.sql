@@ -0,0 +1,16 @@
+-- audit timestamps, optimistic locking versions and soft deletion.
+alter table users add column updated timestamp;
+alter table users add column version integer not null default 1;
+alter table users add column deleted_at timestamp null;
+update users set updated = created;
+
+alter table roles add column created timestamp;
+alter table roles add column updated timestamp;
+alter table roles add column version integer not null default 1;
+alter table roles add column deleted_at timestamp null;
+update roles set created = current_timestamp, updated = current_timestamp;
+
+alter table tours add column updated timestamp;
+alter table tours add column version integer not null default 1;
+alter table tours add column deleted_at timestamp null;
+update tours set updated = created;
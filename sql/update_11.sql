@@ -0,0 +1,19 @@
+create table displays (
+  id serial primary key,
+  user_id integer references users(id) not null,
+  title varchar(250) not null,
+  description varchar(2000) not null default '',
+  address varchar(500) not null default '',
+  tags varchar(500) not null default '',
+  holidays varchar(250) not null default '',
+  latitude double precision not null,
+  longitude double precision not null,
+  schedule varchar(2000) not null default '',
+  created timestamp default now(),
+  updated timestamp default now(),
+  version integer not null default 1,
+  deleted_at timestamp null
+);
+
+create index displays_location_idx on displays(latitude, longitude);
+create index displays_title_idx on displays(lower(title));
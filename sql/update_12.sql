@@ -0,0 +1,7 @@
+create table favorites (
+  id serial primary key,
+  user_id integer references users(id) not null,
+  display_id integer references displays(id) not null,
+  created timestamp default now(),
+  constraint favorites_unq unique(user_id, display_id)
+);
@@ -0,0 +1,33 @@
+-- the full-text search document of each display, weighted from the title
+-- down to the description, kept current by a trigger.
+create function display_document(title varchar, tags varchar, address varchar, description varchar) returns tsvector as $$
+  select setweight(to_tsvector('english', title), 'A') ||
+    setweight(to_tsvector('english', replace(tags, ',', ' ')), 'B') ||
+    setweight(to_tsvector('english', address), 'C') ||
+    setweight(to_tsvector('english', description), 'D')
+$$ language sql immutable;
+
+create table display_search (
+  display_id integer primary key references displays(id) on delete cascade,
+  document tsvector not null
+);
+
+create index display_search_document_idx on display_search using gin(document);
+
+create function display_search_update() returns trigger as $$
+begin
+  insert into display_search (display_id, document)
+    values (new.id, display_document(new.title, new.tags, new.address, new.description))
+    on conflict (display_id) do update set document = excluded.document;
+  return new;
+end
+$$ language plpgsql;
+
+create trigger displays_search_trg after insert or update of title, tags, address, description on displays
+  for each row execute procedure display_search_update();
+
+insert into display_search (display_id, document)
+  select id, display_document(title, tags, address, description) from displays;
+
+-- title autocomplete matches a prefix of the lower case title.
+create index displays_title_prefix_idx on displays(lower(title) text_pattern_ops);
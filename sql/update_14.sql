@@ -0,0 +1,20 @@
+-- audit timestamps, optimistic locking versions and soft deletion for content.
+alter table reports add column updated timestamp;
+alter table reports add column version integer not null default 1;
+alter table reports add column deleted_at timestamp null;
+update reports set updated = created;
+
+alter table status_reports add column updated timestamp;
+alter table status_reports add column version integer not null default 1;
+alter table status_reports add column deleted_at timestamp null;
+update status_reports set updated = created;
+
+alter table contests add column updated timestamp;
+alter table contests add column version integer not null default 1;
+alter table contests add column deleted_at timestamp null;
+update contests set updated = created;
+
+alter table check_ins add column updated timestamp;
+alter table check_ins add column version integer not null default 1;
+alter table check_ins add column deleted_at timestamp null;
+update check_ins set updated = created;
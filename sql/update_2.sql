@@ -0,0 +1,24 @@
+create table tours (
+  id serial primary key,
+  user_id integer references users(id) not null,
+  share_code varchar(40) not null,
+  start_latitude double precision not null,
+  start_longitude double precision not null,
+  return_home boolean not null,
+  total_distance double precision not null,
+  created timestamp default now()
+);
+
+create unique index tours_share_code_idx on tours(share_code);
+create index tours_user_id_idx on tours(user_id);
+
+create table tour_stops (
+  id serial primary key,
+  tour_id integer references tours(id) not null,
+  position integer not null,
+  display_id integer not null,
+  latitude double precision not null,
+  longitude double precision not null
+);
+
+create unique index tour_stops_position_idx on tour_stops(tour_id, position);
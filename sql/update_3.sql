@@ -0,0 +1,38 @@
+create table reports (
+  id serial primary key,
+  item_type varchar(50) not null,
+  item_id integer not null,
+  reporter_id integer references users(id) not null,
+  reason varchar(50) not null,
+  comment varchar(1000) not null default '',
+  status varchar(20) not null,
+  claimed_by integer not null default 0,
+  created timestamp default now()
+);
+
+create unique index reports_reporter_item_idx on reports(item_type, item_id, reporter_id);
+create index reports_status_idx on reports(status, created);
+
+create table moderated_items (
+  id serial primary key,
+  item_type varchar(50) not null,
+  item_id integer not null,
+  hidden boolean not null,
+  deleted boolean not null,
+  updated timestamp default now()
+);
+
+create unique index moderated_items_item_idx on moderated_items(item_type, item_id);
+
+create table moderation_decisions (
+  id serial primary key,
+  report_id integer not null,
+  item_type varchar(50) not null,
+  item_id integer not null,
+  moderator_id integer not null,
+  action varchar(20) not null,
+  note varchar(1000) not null default '',
+  created timestamp default now()
+);
+
+create index moderation_decisions_item_idx on moderation_decisions(item_type, item_id);
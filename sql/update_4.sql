@@ -0,0 +1,10 @@
+create table status_reports (
+  id serial primary key,
+  display_id integer not null,
+  user_id integer references users(id) not null,
+  status varchar(20) not null,
+  created timestamp default now()
+);
+
+create index status_reports_display_idx on status_reports(display_id, created);
+create index status_reports_user_idx on status_reports(user_id, created);
@@ -0,0 +1,41 @@
+alter table users add column created timestamp default now();
+
+create table contests (
+  id serial primary key,
+  name varchar(200) not null,
+  holiday varchar(50) not null,
+  latitude double precision not null,
+  longitude double precision not null,
+  radius_km double precision not null,
+  voting_start timestamp not null,
+  voting_end timestamp not null,
+  status varchar(20) not null,
+  created_by integer references users(id) not null,
+  created timestamp default now()
+);
+
+create index contests_status_idx on contests(status, voting_end);
+
+create table contest_entries (
+  id serial primary key,
+  contest_id integer references contests(id) not null,
+  display_id integer not null,
+  votes integer not null default 0,
+  rank integer not null default 0,
+  winner boolean not null default false,
+  constraint contest_entries_display_unq unique(contest_id, display_id)
+);
+
+create table contest_votes (
+  id serial primary key,
+  contest_id integer references contests(id) not null,
+  display_id integer not null,
+  user_id integer references users(id) not null,
+  ip_address varchar(64) not null,
+  flagged boolean not null default false,
+  created timestamp default now(),
+  constraint contest_votes_user_unq unique(contest_id, user_id)
+);
+
+create index contest_votes_ip_idx on contest_votes(contest_id, ip_address);
+create index contest_votes_display_idx on contest_votes(contest_id, display_id, created);
@@ -0,0 +1,34 @@
+create table follows (
+  id serial primary key,
+  follower_id integer references users(id) not null,
+  followed_id integer references users(id) not null,
+  created timestamp default now(),
+  constraint follows_unq unique(follower_id, followed_id)
+);
+
+create table followed_areas (
+  id serial primary key,
+  user_id integer references users(id) not null,
+  name varchar(200) not null,
+  latitude double precision not null,
+  longitude double precision not null,
+  radius_km double precision not null,
+  created timestamp default now()
+);
+
+create index followed_areas_user_idx on followed_areas(user_id);
+
+create table activities (
+  id serial primary key,
+  user_id integer references users(id) not null,
+  activity_type varchar(20) not null,
+  item_id integer not null,
+  located boolean not null default false,
+  latitude double precision not null default 0,
+  longitude double precision not null default 0,
+  summary varchar(250) not null,
+  created timestamp default now()
+);
+
+create index activities_user_idx on activities(user_id, id);
+create index activities_location_idx on activities(latitude, longitude) where located;
@@ -0,0 +1,21 @@
+create table notifications (
+  id serial primary key,
+  user_id integer references users(id) not null,
+  notification_type varchar(30) not null,
+  subject varchar(250) not null,
+  body varchar(2000) not null,
+  item_type varchar(20) not null,
+  item_id integer not null,
+  read boolean not null default false,
+  created timestamp default now()
+);
+
+create index notifications_user_idx on notifications(user_id, read, id);
+
+create table notification_preferences (
+  id serial primary key,
+  user_id integer references users(id) not null,
+  notification_type varchar(30) not null,
+  delivery varchar(20) not null,
+  constraint notification_preferences_unq unique(user_id, notification_type)
+);
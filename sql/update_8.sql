@@ -0,0 +1,21 @@
+create table check_ins (
+  id serial primary key,
+  user_id integer references users(id) not null,
+  display_id integer not null,
+  verified boolean not null default false,
+  located boolean not null default false,
+  latitude double precision not null default 0,
+  longitude double precision not null default 0,
+  holidays varchar(250) not null default '',
+  created timestamp default now()
+);
+
+create index check_ins_user_idx on check_ins(user_id, created);
+
+create table user_badges (
+  id serial primary key,
+  user_id integer references users(id) not null,
+  badge varchar(50) not null,
+  created timestamp default now(),
+  constraint user_badges_unq unique(user_id, badge)
+);
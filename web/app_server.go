@@ -2,31 +2,101 @@ package web
 
 import (
 	"fmt"
+	"net"
 	"time"
 
 	"github.com/hoisie/web"
 	"github.com/rchargel/localiday/app"
+	"github.com/rchargel/localiday/db"
+	"github.com/rchargel/localiday/geo"
 )
 
 // AppServer the application server.
 type AppServer struct {
-	Port uint16
+	Port      uint16
+	Geocoder  geo.Geocoder
+	Clusterer *geo.Clusterer
+	Heatmap   *geo.Heatmap
+	Displays  *geo.Index
+
+	// TrustedProxies the proxies whose X-Forwarded-For header is believed,
+	// otherwise the client is the address the request came from.
+	TrustedProxies []*net.IPNet
+
+	// Repositories stores users, roles and sessions, in the database unless
+	// it is set.
+	Repositories *db.Repositories
 }
 
 // Start initializes and starts the server.
 func (a AppServer) Start() {
 	startTime := time.Now()
+	repos := a.Repositories
+	if repos == nil {
+		repos = db.NewDBRepositories()
+	}
+	auth := authenticator{repos}
+	trustedProxies = a.TrustedProxies
 
 	cssController := CreateCSSController()
 	jsController := CreateJSController()
 	htmlController := CreateHTMLController()
 	imagesController := CreateImagesController()
 
-	userController := UserController{}
-	oauthController := CreateOAuthController()
+	userController := UserController{auth}
+	tourController := TourController{auth}
+	holidayController := HolidayController{}
+	statusController := StatusController{auth}
+	contestController := ContestController{auth}
+	feedController := FeedController{auth}
+	notificationController := NotificationController{auth}
+	favoriteController := FavoriteController{auth}
+	checkInController := CreateCheckInController(repos, a.Displays)
+	importController := CreateImportController(repos, a.Displays)
+	displayController := CreateDisplayController(repos, a.Displays)
+	reportController := CreateReportController(repos, a.Displays)
+	moderationController := CreateModerationController(repos, a.Displays)
+	oauthController := CreateOAuthController(repos)
 	//var oauthController OAuthController
 
 	web.Post("/r/user/(.*)", userController.ProcessRequest)
+	web.Post("/r/tour/(.*)", tourController.ProcessRequest)
+	web.Post("/r/report/(.*)", reportController.ProcessRequest)
+	web.Post("/r/moderation/(.*)", moderationController.ProcessRequest)
+	web.Post("/r/status/(.*)", statusController.ProcessRequest)
+	web.Post("/r/contest/(.*)", contestController.ProcessRequest)
+	web.Post("/r/feed/(.*)", feedController.ProcessRequest)
+	web.Post("/r/notification/(.*)", notificationController.ProcessRequest)
+	web.Post("/r/checkin/(.*)", checkInController.ProcessRequest)
+	web.Post("/r/import/(.*)", importController.ProcessRequest)
+	web.Post("/r/display/(.*)", displayController.ProcessRequest)
+	web.Post("/r/favorite/(.*)", favoriteController.ProcessRequest)
+	web.Get("/r/display/search", displayController.Search)
+	web.Get("/r/display/complete", displayController.Complete)
+	web.Get("/r/display/([0-9]+)", displayController.RenderDisplay)
+	web.Get("/r/display/export/(geojson|kml|gpx)", displayController.ExportSearch)
+	web.Get("/r/favorite/export/(geojson|kml|gpx)", favoriteController.ExportFavorites)
+	web.Get("/r/tour/shared/(.*)", tourController.RenderSharedTour)
+	web.Get("/r/status/display/([0-9]+)", statusController.RenderStatus)
+	web.Get("/r/contest/list", contestController.RenderContests)
+	web.Get("/r/contest/([0-9]+)/leaderboard", contestController.RenderLeaderboard)
+	web.Get("/r/holiday/list", holidayController.RenderHolidays)
+	web.Get("/r/holiday/active", holidayController.RenderActive)
+	web.Get("/r/holiday/calendar/([0-9]+)", holidayController.RenderCalendar)
+	if a.Geocoder != nil {
+		geocodeController := CreateGeocodeController(a.Geocoder)
+		web.Get("/r/geocode/forward", geocodeController.Geocode)
+		web.Get("/r/geocode/reverse", geocodeController.ReverseGeocode)
+	}
+	if a.Clusterer != nil {
+		clusterController := CreateClusterController(a.Clusterer)
+		web.Get("/r/map/clusters", clusterController.RenderClusters)
+	}
+	if a.Heatmap != nil {
+		heatmapController := CreateHeatmapController(a.Heatmap)
+		web.Get("/r/map/heat/([0-9]+)/([0-9]+)/([0-9]+)\\.(json|png)", heatmapController.RenderTile)
+	}
+	web.Get("/r/tour/export/([^/]+)/(geojson|kml|gpx)", tourController.ExportTour)
 
 	web.Get("/css/localiday_(.*).css", cssController.RenderCSS)
 	web.Get("/js/localiday_(.*).js", jsController.RenderJS)
@@ -41,3 +111,8 @@ func (a AppServer) Start() {
 
 	web.Run(fmt.Sprintf("0.0.0.0:%v", a.Port))
 }
+
+// Stop stops the server, returning from Start.
+func (a AppServer) Stop() {
+	web.Close()
+}
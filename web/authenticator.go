@@ -0,0 +1,39 @@
+package web
+
+import (
+	"errors"
+
+	"github.com/rchargel/localiday/db"
+)
+
+// authenticator finds the logged in user's session for a controller.
+type authenticator struct {
+	repos *db.Repositories
+}
+
+// requireSession gets the session of the logged in user, sending an
+// unauthorized response if there is none.
+func (a authenticator) requireSession(w *ResponseWriter) (*db.Session, bool) {
+	sessionID, err := w.GetSessionIDAuthorization()
+	if err != nil {
+		w.SendError(HTTPUnauthorizedCode, err)
+		return nil, false
+	}
+	sess, err := a.repos.Sessions.Get(sessionID)
+	if err != nil {
+		w.SendError(HTTPUnauthorizedCode, err)
+		return nil, false
+	}
+	return sess, true
+}
+
+// requireAdmin gets the session of the logged in admin, sending an error
+// response if there is none.
+func (a authenticator) requireAdmin(w *ResponseWriter) (*db.Session, bool) {
+	sess, ok := a.requireSession(w)
+	if ok && !a.repos.IsAuthorized(sess.SessionID, db.RoleAdmin) {
+		w.SendError(HTTPForbiddenCode, errors.New("Only administrators may do this."))
+		return nil, false
+	}
+	return sess, ok
+}
@@ -0,0 +1,143 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/hoisie/web"
+	"github.com/rchargel/localiday/db"
+	"github.com/rchargel/localiday/geo"
+	"github.com/rchargel/localiday/services"
+)
+
+const checkInHistorySize = 50
+
+// CheckInController controller for the logged in user's check-ins and badges.
+type CheckInController struct {
+	authenticator
+	locate services.DisplayLocator
+}
+
+// CreateCheckInController creates a check-in controller which finds displays
+// in the index. The index may be nil, in which case displays are located by
+// reading them from the database.
+func CreateCheckInController(repos *db.Repositories, displays *geo.Index) *CheckInController {
+	c := &CheckInController{authenticator: authenticator{repos}}
+	if displays != nil {
+		c.locate = func(displayID int64) (geo.Point, bool) {
+			m, found := displays.Get(displayID)
+			return m.Point, found
+		}
+	}
+	return c
+}
+
+// ProcessRequest processes a check-in request.
+func (c *CheckInController) ProcessRequest(ctx *web.Context, request string) {
+	callMethod(c, NewResponseWriter(ctx), request)
+}
+
+// CheckIn checks in at a display, verified by the device's location if given.
+func (c *CheckInController) CheckIn(w *ResponseWriter) {
+	sess, ok := c.requireSession(w)
+	if !ok {
+		return
+	}
+	var req struct {
+		DisplayID int64
+		Device    *geo.Point
+	}
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	if result, err := services.NewCheckInService(c.repos, c.locate).CheckIn(sess.UserID, req.DisplayID, req.Device); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else {
+		w.SendJSON(result)
+	}
+}
+
+// History gets a page of the user's check-ins, newest first.
+func (c *CheckInController) History(w *ResponseWriter) {
+	sess, ok := c.requireSession(w)
+	if !ok {
+		return
+	}
+	var req struct {
+		Offset int
+	}
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	if checkIns, err := services.NewCheckInService(c.repos, c.locate).History(sess.UserID, req.Offset, checkInHistorySize); err != nil {
+		w.SendError(HTTPServerErrorCode, err)
+	} else {
+		w.SendJSON(checkIns)
+	}
+}
+
+// Delete deletes one of the logged in user's check-ins.
+func (c *CheckInController) Delete(w *ResponseWriter) {
+	sess, ok := c.requireSession(w)
+	if !ok {
+		return
+	}
+	var req struct{ ID int64 }
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else if err = db.DeleteCheckIn(sess.UserID, req.ID); err != nil {
+		w.SendError(HTTPFileNotFoundCode, err)
+	} else {
+		w.SendSuccess()
+	}
+}
+
+// Restore restores a deleted check-in.
+func (c *CheckInController) Restore(w *ResponseWriter) {
+	if _, ok := c.requireAdmin(w); !ok {
+		return
+	}
+	var req struct{ ID int64 }
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else if err = db.RestoreCheckIn(req.ID); err != nil {
+		w.SendError(HTTPFileNotFoundCode, err)
+	} else {
+		w.SendSuccess()
+	}
+}
+
+// Map gets the user's visits as GeoJSON.
+func (c *CheckInController) Map(w *ResponseWriter) {
+	sess, ok := c.requireSession(w)
+	if !ok {
+		return
+	}
+	visits, err := services.NewCheckInService(c.repos, c.locate).VisitMap(sess.UserID)
+	if err != nil {
+		w.SendError(HTTPServerErrorCode, err)
+		return
+	}
+	var buffer bytes.Buffer
+	if err = visits.Write(&buffer, geo.GeoJSON); err != nil {
+		w.SendError(HTTPServerErrorCode, err)
+		return
+	}
+	w.Format, _ = geo.ContentType(geo.GeoJSON)
+	w.Respond(&buffer)
+}
+
+// Badges gets the badges the user has earned, and every badge there is.
+func (c *CheckInController) Badges(w *ResponseWriter) {
+	sess, ok := c.requireSession(w)
+	if !ok {
+		return
+	}
+	if earned, err := services.NewCheckInService(c.repos, c.locate).Badges(sess.UserID); err != nil {
+		w.SendError(HTTPServerErrorCode, err)
+	} else {
+		w.SendJSON(map[string]interface{}{"Earned": earned, "Badges": services.BadgeRules()})
+	}
+}
@@ -0,0 +1,57 @@
+package web
+
+import (
+	"strconv"
+
+	"github.com/hoisie/web"
+	"github.com/rchargel/localiday/geo"
+)
+
+// ClusterController controller for map marker cluster rest calls.
+type ClusterController struct {
+	clusterer *geo.Clusterer
+}
+
+// CreateClusterController creates a cluster controller using the given clusterer.
+func CreateClusterController(clusterer *geo.Clusterer) *ClusterController {
+	return &ClusterController{clusterer}
+}
+
+// RenderClusters renders the clusters and markers inside the box given by the
+// "south", "west", "north" and "east" parameters at the "zoom" level.
+func (c *ClusterController) RenderClusters(ctx *web.Context) {
+	w := NewResponseWriter(ctx)
+	box, err := boundingBoxParams(ctx)
+	if err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	zoom, err := strconv.Atoi(ctx.Params["zoom"])
+	if err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	if clusters, err := c.clusterer.Cluster(box, zoom); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else {
+		w.SendJSON(clusters)
+	}
+}
+
+func boundingBoxParams(ctx *web.Context) (geo.BoundingBox, error) {
+	var box geo.BoundingBox
+	var err error
+	if box.South, err = strconv.ParseFloat(ctx.Params["south"], 64); err != nil {
+		return box, err
+	}
+	if box.West, err = strconv.ParseFloat(ctx.Params["west"], 64); err != nil {
+		return box, err
+	}
+	if box.North, err = strconv.ParseFloat(ctx.Params["north"], 64); err != nil {
+		return box, err
+	}
+	if box.East, err = strconv.ParseFloat(ctx.Params["east"], 64); err != nil {
+		return box, err
+	}
+	return box, box.Validate()
+}
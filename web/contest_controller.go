@@ -0,0 +1,165 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hoisie/web"
+	"github.com/rchargel/localiday/db"
+	"github.com/rchargel/localiday/services"
+)
+
+// ContestController controller for display contests.
+type ContestController struct {
+	authenticator
+}
+
+// ProcessRequest processes a contest request.
+func (c ContestController) ProcessRequest(ctx *web.Context, request string) {
+	callMethod(c, NewResponseWriter(ctx), request)
+}
+
+// Create creates a contest. Only admins may create contests.
+func (c ContestController) Create(w *ResponseWriter) {
+	sess, ok := c.requireAdmin(w)
+	if !ok {
+		return
+	}
+	var req struct {
+		Name        string
+		Holiday     string
+		Latitude    float64
+		Longitude   float64
+		RadiusKm    float64
+		VotingStart time.Time
+		VotingEnd   time.Time
+		DisplayIDs  []int64
+	}
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	contest := &db.Contest{
+		Name:        req.Name,
+		Holiday:     req.Holiday,
+		Latitude:    req.Latitude,
+		Longitude:   req.Longitude,
+		RadiusKm:    req.RadiusKm,
+		VotingStart: req.VotingStart,
+		VotingEnd:   req.VotingEnd,
+		CreatedBy:   sess.UserID,
+	}
+	if err := services.NewContestService(c.repos).CreateContest(contest, req.DisplayIDs); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else {
+		w.SendJSON(contest)
+	}
+}
+
+// Vote casts the logged in user's vote.
+func (c ContestController) Vote(w *ResponseWriter) {
+	sess, ok := c.requireSession(w)
+	if !ok {
+		return
+	}
+	var req struct {
+		ContestID int64
+		DisplayID int64
+	}
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	if _, err := services.NewContestService(c.repos).Vote(req.ContestID, req.DisplayID, sess.UserID, w.GetClientIP()); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else {
+		w.SendSuccess()
+	}
+}
+
+// Close closes voting in a contest early. Only admins may close contests.
+func (c ContestController) Close(w *ResponseWriter) {
+	if _, ok := c.requireAdmin(w); !ok {
+		return
+	}
+	var req struct {
+		ContestID int64
+	}
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	if contest, err := services.NewContestService(c.repos).Close(req.ContestID); db.IsConflict(err) {
+		w.SendError(HTTPConflictCode, fmt.Errorf("Contest %v was changed by someone else, reload it and try again.", req.ContestID))
+	} else if err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else {
+		w.SendJSON(contest)
+	}
+}
+
+// Delete deletes a contest. Only admins may delete contests.
+func (c ContestController) Delete(w *ResponseWriter) {
+	if _, ok := c.requireAdmin(w); !ok {
+		return
+	}
+	var req struct{ ContestID int64 }
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else if err = db.DeleteContest(req.ContestID); err != nil {
+		w.SendError(HTTPFileNotFoundCode, err)
+	} else {
+		w.SendSuccess()
+	}
+}
+
+// Restore restores a deleted contest.
+func (c ContestController) Restore(w *ResponseWriter) {
+	if _, ok := c.requireAdmin(w); !ok {
+		return
+	}
+	var req struct{ ContestID int64 }
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else if err = db.RestoreContest(req.ContestID); err != nil {
+		w.SendError(HTTPFileNotFoundCode, err)
+	} else {
+		w.SendSuccess()
+	}
+}
+
+// RenderContests renders the open contests, or the closed ones if the closed
+// parameter is set.
+func (c ContestController) RenderContests(ctx *web.Context) {
+	w := NewResponseWriter(ctx)
+	status := db.ContestOpen
+	if closed, _ := strconv.ParseBool(ctx.Params["closed"]); closed {
+		status = db.ContestClosed
+	}
+	if contests, err := (db.Contest{}).FindContests(status); err != nil {
+		w.SendError(HTTPServerErrorCode, err)
+	} else {
+		w.SendJSON(contests)
+	}
+}
+
+// RenderLeaderboard renders a contest's leaderboard.
+func (c ContestController) RenderLeaderboard(ctx *web.Context, contestID string) {
+	w := NewResponseWriter(ctx)
+	id, err := strconv.ParseInt(contestID, 10, 64)
+	if err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	contest, entries, err := services.NewContestService(c.repos).Leaderboard(id)
+	if err != nil {
+		w.SendError(HTTPFileNotFoundCode, err)
+		return
+	}
+	w.SendJSON(struct {
+		Contest *db.Contest
+		Entries []db.ContestEntry
+	}{contest, entries})
+}
@@ -4,8 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"io"
-	"io/ioutil"
-	"os"
+	"io/fs"
 	"strings"
 	"time"
 
@@ -65,7 +64,7 @@ func readCSS() (io.Reader, error) {
 	return reader, err
 }
 
-func readIntoFile(filename string, fileMap map[string]os.FileInfo, writer io.Writer) error {
+func readIntoFile(filename string, fileMap map[string]fs.DirEntry, writer io.Writer) error {
 	if file, ok := fileMap[filename]; ok {
 		if err := readFile(file, fileMap, writer); err != nil {
 			return err
@@ -74,18 +73,18 @@ func readIntoFile(filename string, fileMap map[string]os.FileInfo, writer io.Wri
 	return nil
 }
 
-func readFile(fileInfo os.FileInfo, fileMap map[string]os.FileInfo, writer io.Writer) error {
-	file, err := os.Open(cssDir + "/" + fileInfo.Name())
+func readFile(fileInfo fs.DirEntry, fileMap map[string]fs.DirEntry, writer io.Writer) error {
+	file, err := app.OpenFile(cssDir + "/" + fileInfo.Name())
 	defer file.Close()
 	if err != nil {
 		return err
 	}
 
 	if strings.Contains(fileInfo.Name(), gssFile) {
-		app.Log(app.Debug, "Compiling CSS file %v.", file.Name())
+		app.Log(app.Debug, "Compiling CSS file %v.", fileInfo.Name())
 		// first grab the mixins
 		mixinsInfo := fileMap["mixins"]
-		mixins, err := os.Open(cssDir + "/" + mixinsInfo.Name())
+		mixins, err := app.OpenFile(cssDir + "/" + mixinsInfo.Name())
 		defer mixins.Close()
 		if err != nil {
 			return err
@@ -105,11 +104,11 @@ func readFile(fileInfo os.FileInfo, fileMap map[string]os.FileInfo, writer io.Wr
 	return err
 }
 
-func readDir() (map[string]os.FileInfo, error) {
-	var m map[string]os.FileInfo
+func readDir() (map[string]fs.DirEntry, error) {
+	var m map[string]fs.DirEntry
 	var err error
-	if files, err := ioutil.ReadDir(cssDir); err == nil {
-		m = make(map[string]os.FileInfo, len(files))
+	if files, err := app.ReadDir(cssDir); err == nil {
+		m = make(map[string]fs.DirEntry, len(files))
 		for _, file := range files {
 			n := file.Name()
 			if strings.Contains(n, gssFile) || strings.Contains(n, cssFile) {
@@ -0,0 +1,287 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/structs"
+	"github.com/hoisie/web"
+	"github.com/rchargel/localiday/db"
+	"github.com/rchargel/localiday/geo"
+	"github.com/rchargel/localiday/schedule"
+	"github.com/rchargel/localiday/services"
+)
+
+// DisplayController controller for display search, detail and schedule rest
+// calls.
+type DisplayController struct {
+	authenticator
+	displays *services.DisplayService
+}
+
+// CreateDisplayController creates a display controller which searches the
+// index of displays.
+func CreateDisplayController(repos *db.Repositories, index *geo.Index) *DisplayController {
+	return &DisplayController{authenticator{repos}, services.NewDisplayService(index)}
+}
+
+// ProcessRequest processes a display request.
+func (c *DisplayController) ProcessRequest(ctx *web.Context, request string) {
+	callMethod(c, NewResponseWriter(ctx), request)
+}
+
+// Schedule sets or, if it is null, removes the lighting schedule of a
+// display. Only the display's owner or an admin may change it.
+func (c *DisplayController) Schedule(w *ResponseWriter) {
+	sess, ok := c.requireSession(w)
+	if !ok {
+		return
+	}
+	var req struct {
+		ID       int64
+		Version  int64
+		Schedule *schedule.Schedule
+	}
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	display, err := db.Display{}.Get(req.ID)
+	if err != nil {
+		w.SendError(HTTPFileNotFoundCode, err)
+		return
+	}
+	if display.UserID != sess.UserID && !c.repos.IsAuthorized(sess.SessionID, db.RoleAdmin) {
+		w.SendError(HTTPForbiddenCode, errors.New("Only the owner of the display may change its schedule."))
+		return
+	}
+	display.Version = req.Version
+	if err = display.SetLightingSchedule(req.Schedule); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else if err = c.displays.Update(display); db.IsConflict(err) {
+		w.SendError(HTTPConflictCode, fmt.Errorf("Display %v was changed by someone else, reload it and try again.", display.ID))
+	} else if err != nil {
+		w.SendError(HTTPServerErrorCode, err)
+	} else {
+		w.SendJSON(toDisplayMap(display))
+	}
+}
+
+// Search finds the displays inside the box given by the "south", "west",
+// "north" and "east" parameters, or within the "radius" in kilometers of the
+// "lat" and "lon" parameters. The "q" parameter finds the displays matching
+// its words, ranked by how well they match, anywhere if no area is given. The
+// "holiday" parameter limits the displays to those put up for the holiday,
+// and "open=now", or "at" with an RFC 3339 time, to those lit at the time.
+// Each display has its current status.
+func (c *DisplayController) Search(ctx *web.Context) {
+	w := NewResponseWriter(ctx)
+	search, err := displaySearchParams(ctx)
+	if err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	results, err := c.displays.Search(search)
+	if err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	ids := make([]int64, len(results))
+	for i := range results {
+		ids[i] = results[i].ID
+	}
+	statuses, err := services.NewStatusService().CurrentStatuses(ids)
+	if err != nil {
+		w.SendError(HTTPServerErrorCode, err)
+		return
+	}
+	found := make([]map[string]interface{}, len(results))
+	for i := range results {
+		found[i] = toDisplayMap(&results[i].Display)
+		found[i]["Status"] = statuses[results[i].ID]
+		if search.Center != nil {
+			found[i]["Distance"] = results[i].Distance
+		}
+		if len(search.Text) > 0 {
+			found[i]["Rank"] = results[i].Rank
+			found[i]["Snippet"] = results[i].Snippet
+		}
+	}
+	w.SendJSON(found)
+}
+
+// Complete finds the titles of displays starting with the "q" parameter.
+func (c *DisplayController) Complete(ctx *web.Context) {
+	w := NewResponseWriter(ctx)
+	if titles, err := c.displays.Complete(ctx.Params["q"]); err != nil {
+		w.SendError(HTTPServerErrorCode, err)
+	} else {
+		w.SendJSON(titles)
+	}
+}
+
+// ExportSearch exports the displays found by a search, with the parameters of
+// Search, as GeoJSON, KML or GPX.
+func (c *DisplayController) ExportSearch(ctx *web.Context, format string) {
+	w := NewResponseWriter(ctx)
+	search, err := displaySearchParams(ctx)
+	if err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	results, err := c.displays.Search(search)
+	if err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	displays := make([]db.Display, len(results))
+	for i := range results {
+		displays[i] = results[i].Display
+	}
+	sendExport(w, c.displays.Export("Localiday displays", displays), format, "localiday-displays")
+}
+
+// RenderDisplay renders a display found by its ID, with its current status,
+// the sunrise, sunset and civil dusk at the display and the hours it is lit
+// on the "date" parameter, in YYYY-MM-DD format, or today in the display's
+// time zone.
+func (c *DisplayController) RenderDisplay(ctx *web.Context, id string) {
+	w := NewResponseWriter(ctx)
+	displayID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	display, err := db.Display{}.Get(displayID)
+	if err != nil {
+		w.SendError(HTTPFileNotFoundCode, err)
+		return
+	}
+	loc := displayLocation(display)
+	y, mon, day := time.Now().In(loc).Date()
+	date := time.Date(y, mon, day, 12, 0, 0, 0, loc)
+	if param, found := ctx.Params["date"]; found {
+		if date, err = time.ParseInLocation("2006-01-02", param, loc); err != nil {
+			w.SendError(HTTPBadRequestCode, err)
+			return
+		}
+		date = date.Add(12 * time.Hour)
+	}
+	status, err := services.NewStatusService().CurrentStatus(displayID)
+	if err != nil {
+		w.SendError(HTTPServerErrorCode, err)
+		return
+	}
+	m := toDisplayMap(display)
+	m["Solar"] = solarTimes(display, date)
+	m["Status"] = status
+	w.SendJSON(m)
+}
+
+// displayLocation gets the time zone of the display's schedule. Without one,
+// the zone is estimated from the longitude, which is close enough to find
+// the local date.
+func displayLocation(d *db.Display) *time.Location {
+	if s := d.LightingSchedule(); s != nil {
+		if loc, err := s.Location(); err == nil {
+			return loc
+		}
+	}
+	return time.FixedZone("", int(math.Floor(d.Longitude/15+0.5))*60*60)
+}
+
+// solarTimes gets the solar events at the display on the date, and the
+// hours it is lit that night if it has a schedule. An event which does not
+// happen, during the polar day or night, is left out.
+func solarTimes(d *db.Display, date time.Time) map[string]interface{} {
+	p := d.Point()
+	times := map[string]interface{}{"Date": date.Format("2006-01-02")}
+	events := map[string]func(geo.Point, time.Time) (time.Time, error){
+		"Sunrise":   geo.Sunrise,
+		"Sunset":    geo.Sunset,
+		"CivilDusk": geo.CivilDusk,
+	}
+	for name, event := range events {
+		if t, err := event(p, date); err == nil {
+			times[name] = t
+		}
+	}
+	if s := d.LightingSchedule(); s != nil {
+		if on, off, lit := s.Window(date); lit {
+			times["LitFrom"] = on
+			times["LitUntil"] = off
+		}
+	}
+	return times
+}
+
+// sendExport writes the export in the format as a download named by the
+// filename and the format.
+func sendExport(w *ResponseWriter, e *geo.Export, format, filename string) {
+	contentType, err := geo.ContentType(format)
+	if err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	var buffer bytes.Buffer
+	if err = e.Write(&buffer, format); err != nil {
+		w.SendError(HTTPServerErrorCode, err)
+		return
+	}
+	w.Format = contentType
+	w.Headers[HTTPContentDisposition] = fmt.Sprintf("attachment; filename=\"%v.%v\"", filename, format)
+	w.Respond(&buffer)
+}
+
+func displaySearchParams(ctx *web.Context) (services.DisplaySearch, error) {
+	search := services.DisplaySearch{Text: strings.TrimSpace(ctx.Params["q"]), Holiday: ctx.Params["holiday"]}
+	if at, found := ctx.Params["at"]; found {
+		t, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			return search, err
+		}
+		search.LitAt = &t
+	} else if ctx.Params["open"] == "now" {
+		now := time.Now()
+		search.LitAt = &now
+	}
+	if _, found := ctx.Params["south"]; found {
+		box, err := boundingBoxParams(ctx)
+		search.Box = &box
+		return search, err
+	}
+	if _, found := ctx.Params["lat"]; !found && len(search.Text) > 0 {
+		return search, nil
+	}
+	var center geo.Point
+	var err error
+	if center.Latitude, err = strconv.ParseFloat(ctx.Params["lat"], 64); err != nil {
+		return search, err
+	}
+	if center.Longitude, err = strconv.ParseFloat(ctx.Params["lon"], 64); err != nil {
+		return search, err
+	}
+	if search.RadiusKm, err = strconv.ParseFloat(ctx.Params["radius"], 64); err != nil {
+		return search, err
+	}
+	search.Center = &center
+	return search, nil
+}
+
+func toDisplayMap(d *db.Display) map[string]interface{} {
+	m := structs.Map(d)
+	for k, v := range structs.Map(d.Audited) {
+		m[k] = v
+	}
+	delete(m, "Audited")
+	m["Tags"] = d.TagList()
+	m["Holidays"] = d.HolidayKeys()
+	m["Schedule"] = d.LightingSchedule()
+	return m
+}
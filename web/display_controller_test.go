@@ -0,0 +1,29 @@
+package web
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rchargel/localiday/db"
+)
+
+func TestDisplayLocation(t *testing.T) {
+	// 03:30 UTC on December 11th is still the evening of the 10th in the Americas.
+	now := time.Date(2026, 12, 11, 3, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		display  *db.Display
+		expected string
+	}{
+		{"scheduled in New York", &db.Display{Longitude: -74.006, Schedule: `{"TimeZone":"America/New_York"}`}, "2026-12-10"},
+		{"scheduled in Tokyo", &db.Display{Longitude: 139.69, Schedule: `{"TimeZone":"Asia/Tokyo"}`}, "2026-12-11"},
+		{"unscheduled in Chicago", &db.Display{Longitude: -87.63}, "2026-12-10"},
+		{"unscheduled in London", &db.Display{Longitude: -0.13}, "2026-12-11"},
+		{"unknown time zone", &db.Display{Longitude: -122.42, Schedule: `{"TimeZone":"Nowhere/Town"}`}, "2026-12-10"},
+	}
+	for _, test := range tests {
+		if date := now.In(displayLocation(test.display)).Format("2006-01-02"); date != test.expected {
+			t.Errorf("%v: the local date is %v, expected %v", test.name, date, test.expected)
+		}
+	}
+}
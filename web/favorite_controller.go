@@ -0,0 +1,89 @@
+package web
+
+import (
+	"encoding/json"
+
+	"github.com/hoisie/web"
+	"github.com/rchargel/localiday/db"
+	"github.com/rchargel/localiday/services"
+)
+
+// FavoriteController controller for the logged in user's favorite displays.
+type FavoriteController struct {
+	authenticator
+}
+
+type favoriteRequest struct {
+	DisplayID int64
+}
+
+// ProcessRequest processes a favorites request.
+func (c FavoriteController) ProcessRequest(ctx *web.Context, request string) {
+	callMethod(c, NewResponseWriter(ctx), request)
+}
+
+// Add adds a display to the user's favorites.
+func (c FavoriteController) Add(w *ResponseWriter) {
+	sess, ok := c.requireSession(w)
+	if !ok {
+		return
+	}
+	var req favoriteRequest
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else if err = db.AddFavorite(sess.UserID, req.DisplayID); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else {
+		w.SendSuccess()
+	}
+}
+
+// Remove removes a display from the user's favorites.
+func (c FavoriteController) Remove(w *ResponseWriter) {
+	sess, ok := c.requireSession(w)
+	if !ok {
+		return
+	}
+	var req favoriteRequest
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else if err = db.RemoveFavorite(sess.UserID, req.DisplayID); err != nil {
+		w.SendError(HTTPServerErrorCode, err)
+	} else {
+		w.SendSuccess()
+	}
+}
+
+// List lists the user's favorite displays.
+func (c FavoriteController) List(w *ResponseWriter) {
+	sess, ok := c.requireSession(w)
+	if !ok {
+		return
+	}
+	displays, err := db.FindFavoriteDisplays(sess.UserID)
+	if err != nil {
+		w.SendError(HTTPServerErrorCode, err)
+		return
+	}
+	found := make([]map[string]interface{}, len(displays))
+	for i := range displays {
+		found[i] = toDisplayMap(&displays[i])
+	}
+	w.SendJSON(found)
+}
+
+// ExportFavorites exports the user's favorite displays as GeoJSON, KML or GPX.
+func (c FavoriteController) ExportFavorites(ctx *web.Context, format string) {
+	w := NewResponseWriter(ctx)
+	sess, ok := c.requireSession(w)
+	if !ok {
+		return
+	}
+	displays, err := db.FindFavoriteDisplays(sess.UserID)
+	if err != nil {
+		w.SendError(HTTPServerErrorCode, err)
+		return
+	}
+	e := services.NewDisplayService(nil).Export("Localiday favorites", displays)
+	sendExport(w, e, format, "localiday-favorites")
+}
@@ -0,0 +1,132 @@
+package web
+
+import (
+	"encoding/json"
+
+	"github.com/hoisie/web"
+	"github.com/rchargel/localiday/db"
+	"github.com/rchargel/localiday/services"
+)
+
+// FeedController controller for following users and areas and reading the
+// activity feed. Every request is made by the logged in user.
+type FeedController struct {
+	authenticator
+}
+
+// ProcessRequest processes a feed request.
+func (f FeedController) ProcessRequest(ctx *web.Context, request string) {
+	callMethod(f, NewResponseWriter(ctx), request)
+}
+
+// Feed gets a page of the user's feed. Before is the Next cursor of the
+// previous page, or zero for the first page.
+func (f FeedController) Feed(w *ResponseWriter) {
+	sess, ok := f.requireSession(w)
+	if !ok {
+		return
+	}
+	var req struct {
+		Before int64
+	}
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	if page, err := services.NewFeedService(f.repos).Feed(sess.UserID, req.Before); err != nil {
+		w.SendError(HTTPServerErrorCode, err)
+	} else {
+		w.SendJSON(page)
+	}
+}
+
+// Following lists the users and areas the user follows.
+func (f FeedController) Following(w *ResponseWriter) {
+	sess, ok := f.requireSession(w)
+	if !ok {
+		return
+	}
+	userIDs, err := db.FindFollowedUserIDs(sess.UserID)
+	if err != nil {
+		w.SendError(HTTPServerErrorCode, err)
+		return
+	}
+	areas, err := db.FindFollowedAreas(sess.UserID)
+	if err != nil {
+		w.SendError(HTTPServerErrorCode, err)
+		return
+	}
+	w.SendJSON(struct {
+		UserIDs []int64
+		Areas   []db.FollowedArea
+	}{userIDs, areas})
+}
+
+// FollowUser follows another user.
+func (f FeedController) FollowUser(w *ResponseWriter) {
+	f.changeUserFollow(w, services.NewFeedService(f.repos).FollowUser)
+}
+
+// UnfollowUser stops following another user.
+func (f FeedController) UnfollowUser(w *ResponseWriter) {
+	f.changeUserFollow(w, services.NewFeedService(f.repos).UnfollowUser)
+}
+
+// FollowArea follows an area.
+func (f FeedController) FollowArea(w *ResponseWriter) {
+	sess, ok := f.requireSession(w)
+	if !ok {
+		return
+	}
+	var area db.FollowedArea
+	if err := json.NewDecoder(w.Request.Body).Decode(&area); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	area.ID = 0
+	area.UserID = sess.UserID
+	if err := services.NewFeedService(f.repos).FollowArea(&area); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else {
+		w.SendJSON(area)
+	}
+}
+
+// UnfollowArea stops following an area.
+func (f FeedController) UnfollowArea(w *ResponseWriter) {
+	sess, ok := f.requireSession(w)
+	if !ok {
+		return
+	}
+	var req struct {
+		AreaID int64
+	}
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	if err := services.NewFeedService(f.repos).UnfollowArea(sess.UserID, req.AreaID); err != nil {
+		w.SendError(HTTPServerErrorCode, err)
+	} else {
+		w.SendSuccess()
+	}
+}
+
+func (f FeedController) changeUserFollow(w *ResponseWriter, change func(userID, followedID int64) error) {
+	sess, ok := f.requireSession(w)
+	if !ok {
+		return
+	}
+	var req struct {
+		UserID int64
+	}
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	if err := change(sess.UserID, req.UserID); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else {
+		w.SendSuccess()
+	}
+}
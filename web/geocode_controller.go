@@ -0,0 +1,54 @@
+package web
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/hoisie/web"
+	"github.com/rchargel/localiday/geo"
+)
+
+// GeocodeController controller for geocoding rest calls.
+type GeocodeController struct {
+	geocoder geo.Geocoder
+}
+
+// CreateGeocodeController creates a geocode controller using the given geocoder.
+func CreateGeocodeController(geocoder geo.Geocoder) *GeocodeController {
+	return &GeocodeController{geocoder}
+}
+
+// Geocode finds the location of the address given in the "q" parameter,
+// which may be a postal code, a city or a street address.
+func (c *GeocodeController) Geocode(ctx *web.Context) {
+	w := NewResponseWriter(ctx)
+	address := ctx.Params["q"]
+	if len(address) == 0 {
+		w.SendError(HTTPBadRequestCode, errors.New("No address supplied."))
+	} else if l, err := c.geocoder.Geocode(address); err != nil {
+		w.SendError(HTTPFileNotFoundCode, err)
+	} else {
+		w.SendJSON(l)
+	}
+}
+
+// ReverseGeocode finds the city and region of the point given in the "lat"
+// and "lon" parameters.
+func (c *GeocodeController) ReverseGeocode(ctx *web.Context) {
+	w := NewResponseWriter(ctx)
+	lat, err := strconv.ParseFloat(ctx.Params["lat"], 64)
+	if err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	lon, err := strconv.ParseFloat(ctx.Params["lon"], 64)
+	if err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	if l, err := c.geocoder.ReverseGeocode(geo.Point{Latitude: lat, Longitude: lon}); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else {
+		w.SendJSON(l)
+	}
+}
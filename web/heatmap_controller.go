@@ -0,0 +1,72 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/hoisie/web"
+	"github.com/rchargel/localiday/geo"
+)
+
+// HeatmapController controller for display density heatmap tiles.
+type HeatmapController struct {
+	heatmap *geo.Heatmap
+}
+
+// CreateHeatmapController creates a heatmap controller using the given heatmap.
+func CreateHeatmapController(heatmap *geo.Heatmap) *HeatmapController {
+	return &HeatmapController{heatmap}
+}
+
+// RenderTile renders the density of the z/x/y tile, either as weighted points
+// in JSON or as a PNG heat image. The "weight" parameter selects the
+// weighting, which defaults to the count of displays.
+func (c *HeatmapController) RenderTile(ctx *web.Context, z, x, y, format string) {
+	w := NewResponseWriter(ctx)
+	tile, err := parseTile(z, x, y)
+	if err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	weight := ctx.Params["weight"]
+	if len(weight) == 0 {
+		weight = geo.WeightCount
+	}
+	heat, err := c.heatmap.Tile(tile, weight)
+	if err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+
+	w.LastModified = heat.Generated.Unix()
+	if !w.IsModified() {
+		return
+	}
+	if format == "png" {
+		if data, err := heat.PNG(); err != nil {
+			w.SendError(HTTPServerErrorCode, err)
+		} else {
+			w.Format = pngFileFormat
+			w.Headers[HTTPContentLength] = fmt.Sprint(len(data))
+			w.Respond(bytes.NewReader(data))
+		}
+	} else {
+		w.SendJSON(heat.Points)
+	}
+}
+
+func parseTile(z, x, y string) (geo.Tile, error) {
+	var t geo.Tile
+	var err error
+	if t.Z, err = strconv.Atoi(z); err != nil {
+		return t, err
+	}
+	if t.X, err = strconv.Atoi(x); err != nil {
+		return t, err
+	}
+	if t.Y, err = strconv.Atoi(y); err != nil {
+		return t, err
+	}
+	return t, t.Validate()
+}
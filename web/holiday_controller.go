@@ -0,0 +1,43 @@
+package web
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/hoisie/web"
+	"github.com/rchargel/localiday/holiday"
+)
+
+// HolidayController controller for the holiday calendar rest calls.
+type HolidayController struct{}
+
+// RenderHolidays renders the list of holidays displays may be assigned to.
+func (h HolidayController) RenderHolidays(ctx *web.Context) {
+	w := NewResponseWriter(ctx)
+	type item struct {
+		Key  string
+		Name string
+	}
+	all := holiday.All()
+	items := make([]item, len(all))
+	for i, h := range all {
+		items[i] = item{h.Key, h.Name}
+	}
+	w.SendJSON(items)
+}
+
+// RenderCalendar renders the holiday seasons for the given year.
+func (h HolidayController) RenderCalendar(ctx *web.Context, year string) {
+	w := NewResponseWriter(ctx)
+	if y, err := strconv.Atoi(year); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else {
+		w.SendJSON(holiday.Calendar(y))
+	}
+}
+
+// RenderActive renders the holiday seasons which are running today.
+func (h HolidayController) RenderActive(ctx *web.Context) {
+	w := NewResponseWriter(ctx)
+	w.SendJSON(holiday.ActiveOn(time.Now()))
+}
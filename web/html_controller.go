@@ -2,8 +2,6 @@ package web
 
 import (
 	"html/template"
-	"io/ioutil"
-	"os"
 	"time"
 
 	"github.com/hoisie/web"
@@ -34,7 +32,7 @@ func (c *HTMLController) Render(ctx *web.Context, file string) {
 	}
 
 	if w.IsModified() {
-		f, err := os.Open(htmlDir + "/" + file)
+		f, err := app.OpenFile(htmlDir + "/" + file)
 		defer f.Close()
 
 		if err != nil {
@@ -75,7 +73,7 @@ func (c *HTMLController) getTemplate(name, path string) (*template.Template, err
 	if t, found := c.templates[name]; found {
 		return t, nil
 	}
-	if data, err := ioutil.ReadFile(htmlDir + "/" + path); err == nil {
+	if data, err := app.ReadFile(htmlDir + "/" + path); err == nil {
 		return template.New(name).Parse(string(data))
 	}
 	return nil, err
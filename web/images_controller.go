@@ -1,13 +1,12 @@
 package web
 
 import (
-	"io/ioutil"
 	"math/rand"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/hoisie/web"
+	"github.com/rchargel/localiday/app"
 )
 
 const (
@@ -39,12 +38,12 @@ func (c *ImagesController) RenderImage(ctx *web.Context, imagePath string) {
 		w.LastModified = lm
 	}
 	if w.IsModified() {
-		file, err := os.Open(imagesDir + "/" + imagePath)
+		file, err := app.OpenFile(imagesDir + "/" + imagePath)
 		defer file.Close()
 		if err != nil {
 			w.SendError(HTTPFileNotFoundCode, err)
 		} else {
-			w.Format = c.getContentType(file.Name())
+			w.Format = c.getContentType(imagePath)
 			lm = time.Now().Unix()
 			w.LastModified = lm
 			c.lastModified[imagePath] = lm
@@ -62,10 +61,10 @@ func (c *ImagesController) RenderBGImage(ctx *web.Context) {
 		w.SendError(HTTPServerErrorCode, err)
 	} else {
 		img := imgs[rand.Intn(len(imgs))]
-		file, err := os.Open(bgImagesDir + "/" + img)
+		file, err := app.OpenFile(bgImagesDir + "/" + img)
 		defer file.Close()
 		if err == nil {
-			w.Format = c.getContentType(file.Name())
+			w.Format = c.getContentType(img)
 			w.Respond(file)
 		} else {
 			w.SendError(HTTPServerErrorCode, err)
@@ -78,7 +77,7 @@ func (c *ImagesController) getBGImagesList() ([]string, error) {
 	if len(c.bgImages) > 0 {
 		return c.bgImages, nil
 	}
-	files, err := ioutil.ReadDir(bgImagesDir)
+	files, err := app.ReadDir(bgImagesDir)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,94 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hoisie/web"
+	"github.com/rchargel/localiday/db"
+	"github.com/rchargel/localiday/geo"
+	"github.com/rchargel/localiday/services"
+)
+
+// maxImportSize the largest import request accepted, in bytes.
+const maxImportSize = 20 << 20
+
+// Import file formats.
+const (
+	importCSV     = "csv"
+	importGeoJSON = "geojson"
+)
+
+// ImportController controller for the admin's bulk import of displays.
+type ImportController struct {
+	authenticator
+	imports *services.ImportService
+}
+
+// CreateImportController creates an import controller which creates displays
+// in the database, adding them to the index if it is not nil.
+func CreateImportController(repos *db.Repositories, displays *geo.Index) *ImportController {
+	target := services.NewDisplayImportTarget(services.NewDisplayService(displays))
+	return &ImportController{authenticator{repos}, services.NewImportService(target)}
+}
+
+// ProcessRequest processes an import request.
+func (c *ImportController) ProcessRequest(ctx *web.Context, request string) {
+	callMethod(c, NewResponseWriter(ctx), request)
+}
+
+// Start starts importing a CSV file, with the columns mapped to the import
+// fields, or a GeoJSON file. The import runs in the background, its progress
+// is polled with the returned job ID.
+func (c *ImportController) Start(w *ResponseWriter) {
+	sess, ok := c.requireAdmin(w)
+	if !ok {
+		return
+	}
+	var req struct {
+		Format  string
+		Data    string
+		Columns services.ColumnMapping
+		DryRun  bool
+	}
+	if err := json.NewDecoder(io.LimitReader(w.Request.Body, maxImportSize)).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+
+	imports := c.imports
+	var records []services.ImportRecord
+	var failures []services.ImportRowResult
+	var err error
+	switch strings.ToLower(req.Format) {
+	case importCSV:
+		records, failures, err = imports.ParseCSV(strings.NewReader(req.Data), req.Columns)
+	case importGeoJSON:
+		records, failures, err = imports.ParseGeoJSON(strings.NewReader(req.Data))
+	default:
+		err = fmt.Errorf("%v is not a valid import format.", req.Format)
+	}
+	if err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	job := imports.Start(sess.UserID, records, failures, req.DryRun)
+	w.SendJSON(job.Progress())
+}
+
+// Progress gets the progress and report of an import job.
+func (c *ImportController) Progress(w *ResponseWriter) {
+	if _, ok := c.requireAdmin(w); !ok {
+		return
+	}
+	var req struct{ ID string }
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else if job, err := c.imports.GetJob(req.ID); err != nil {
+		w.SendError(HTTPFileNotFoundCode, err)
+	} else {
+		w.SendJSON(job.Progress())
+	}
+}
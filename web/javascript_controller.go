@@ -5,7 +5,6 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"time"
 
 	"github.com/dchest/jsmin"
@@ -63,7 +62,7 @@ func (c *JSController) RenderJSFile(ctx *web.Context, file string) {
 		w.LastModified = lm
 	}
 	if w.IsModified() {
-		if data, err := ioutil.ReadFile(jsDir + "/" + file); err != nil {
+		if data, err := app.ReadFile(jsDir + "/" + file); err != nil {
 			w.SendError(HTTPFileNotFoundCode, err)
 		} else {
 			if mdata, err := jsmin.Minify(data); err == nil {
@@ -101,7 +100,7 @@ func readJS() (io.Reader, error) {
 
 func loadAssets() (assets, error) {
 	a := assets{}
-	assetData, err := ioutil.ReadFile(jsDir + "/jsresources.yaml")
+	assetData, err := app.ReadFile(jsDir + "/jsresources.yaml")
 	if err != nil {
 		return a, err
 	}
@@ -118,7 +117,7 @@ func loadAssets() (assets, error) {
 func readJSIntoFile(asset string, writer io.Writer) error {
 	var err error
 	file := jsDir + "/" + asset
-	if script, err := ioutil.ReadFile(file); err == nil {
+	if script, err := app.ReadFile(file); err == nil {
 		/* // UNCOMMENT FOR MINIFICATION
 		if strings.Contains(asset, ".min.") {
 			writer.Write(script)
@@ -0,0 +1,196 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/hoisie/web"
+	"github.com/rchargel/localiday/app"
+	"github.com/rchargel/localiday/db"
+	"github.com/rchargel/localiday/geo"
+	"github.com/rchargel/localiday/services"
+)
+
+const moderationQueueSize = 50
+
+// ReportController controller for users to report items.
+type ReportController struct {
+	authenticator
+	displays *services.DisplayService
+}
+
+// ModerationController controller for the admin moderation queue.
+type ModerationController struct {
+	authenticator
+	displays *services.DisplayService
+}
+
+// CreateReportController creates a report controller which takes displays
+// hidden by reports out of the index.
+func CreateReportController(repos *db.Repositories, index *geo.Index) *ReportController {
+	return &ReportController{authenticator{repos}, services.NewDisplayService(index)}
+}
+
+// CreateModerationController creates a moderation controller which keeps the
+// index current with the displays moderators hide and delete.
+func CreateModerationController(repos *db.Repositories, index *geo.Index) *ModerationController {
+	return &ModerationController{authenticator{repos}, services.NewDisplayService(index)}
+}
+
+// ProcessRequest processes a report request.
+func (r *ReportController) ProcessRequest(ctx *web.Context, request string) {
+	callMethod(r, NewResponseWriter(ctx), request)
+}
+
+// Submit reports an item on behalf of the logged in user.
+func (r *ReportController) Submit(w *ResponseWriter) {
+	sess, ok := r.requireSession(w)
+	if !ok {
+		return
+	}
+	var req struct {
+		ItemType string
+		ItemID   int64
+		Reason   string
+		Comment  string
+	}
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	if _, err := services.NewModerationService(r.repos, r.displays).Report(req.ItemType, req.ItemID, sess.UserID, req.Reason, req.Comment); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else {
+		w.SendSuccess()
+	}
+}
+
+// ProcessRequest processes a moderation request. Only admins may moderate.
+func (m *ModerationController) ProcessRequest(ctx *web.Context, request string) {
+	w := NewResponseWriter(ctx)
+	if sess, ok := m.requireSession(w); ok {
+		if m.repos.IsAuthorized(sess.SessionID, db.RoleAdmin) {
+			callMethod(m, w, request)
+		} else {
+			w.SendError(HTTPForbiddenCode, errors.New("Only administrators may moderate content."))
+		}
+	}
+}
+
+// Queue lists reports, optionally filtered by status, item type and reason.
+func (m *ModerationController) Queue(w *ResponseWriter) {
+	var req struct {
+		Status   string
+		ItemType string
+		Reason   string
+		Offset   int
+	}
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	if reports, err := (db.Report{}).FindReports(req.Status, req.ItemType, req.Reason, req.Offset, moderationQueueSize); err != nil {
+		w.SendError(HTTPServerErrorCode, err)
+	} else {
+		w.SendJSON(reports)
+	}
+}
+
+// Claim claims an open report for the logged in moderator.
+func (m *ModerationController) Claim(w *ResponseWriter) {
+	sess, _ := m.requireSession(w)
+	var req struct {
+		ReportID int64
+	}
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	report, err := db.Report{}.Get(req.ReportID)
+	if err != nil {
+		w.SendError(HTTPFileNotFoundCode, err)
+	} else if err = report.Claim(sess.UserID); db.IsConflict(err) {
+		w.SendError(HTTPConflictCode, fmt.Errorf("Report %v was changed by someone else, reload it and try again.", report.ID))
+	} else if err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else {
+		w.SendJSON(report)
+	}
+}
+
+// Decide hides, deletes, dismisses or bans on a report.
+func (m *ModerationController) Decide(w *ResponseWriter) {
+	sess, _ := m.requireSession(w)
+	var req struct {
+		ReportID  int64
+		Action    string
+		Note      string
+		BanUserID int64
+	}
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	if decision, err := services.NewModerationService(m.repos, m.displays).Decide(req.ReportID, sess.UserID, req.Action, req.Note, req.BanUserID); db.IsConflict(err) {
+		w.SendError(HTTPConflictCode, fmt.Errorf("Report %v was changed by someone else, reload it and try again.", req.ReportID))
+	} else if err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else {
+		w.SendJSON(decision)
+	}
+}
+
+// Delete deletes a report.
+func (m *ModerationController) Delete(w *ResponseWriter) {
+	var req struct{ ReportID int64 }
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else if err = db.DeleteReport(req.ReportID); err != nil {
+		w.SendError(HTTPFileNotFoundCode, err)
+	} else {
+		w.SendSuccess()
+	}
+}
+
+// Restore restores a deleted report.
+func (m *ModerationController) Restore(w *ResponseWriter) {
+	var req struct{ ReportID int64 }
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else if err = db.RestoreReport(req.ReportID); err != nil {
+		w.SendError(HTTPFileNotFoundCode, err)
+	} else {
+		w.SendSuccess()
+	}
+}
+
+// History lists the decisions made about an item.
+func (m *ModerationController) History(w *ResponseWriter) {
+	var req struct {
+		ItemType string
+		ItemID   int64
+	}
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	if decisions, err := (db.ModerationDecision{}).FindDecisions(req.ItemType, req.ItemID); err != nil {
+		w.SendError(HTTPServerErrorCode, err)
+	} else {
+		w.SendJSON(decisions)
+	}
+}
+
+// callMethod calls the controller method named by the request, passing it
+// the response writer.
+func callMethod(controller interface{}, w *ResponseWriter, request string) {
+	method := app.MakeFirstLetterUpperCase(request)
+	rm := reflect.ValueOf(controller).MethodByName(method)
+	if rm.IsValid() {
+		rm.Call([]reflect.Value{reflect.ValueOf(w)})
+	} else {
+		w.SendError(HTTPInvalidMethodCode, fmt.Errorf("No method %v found", request))
+	}
+}
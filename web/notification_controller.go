@@ -0,0 +1,104 @@
+package web
+
+import (
+	"encoding/json"
+
+	"github.com/hoisie/web"
+	"github.com/rchargel/localiday/services"
+)
+
+const inboxPageSize = 25
+
+// NotificationController controller for the logged in user's notification
+// inbox and delivery preferences.
+type NotificationController struct {
+	authenticator
+}
+
+// ProcessRequest processes a notification request.
+func (n NotificationController) ProcessRequest(ctx *web.Context, request string) {
+	callMethod(n, NewResponseWriter(ctx), request)
+}
+
+// Inbox gets a page of the user's notifications, newest first.
+func (n NotificationController) Inbox(w *ResponseWriter) {
+	sess, ok := n.requireSession(w)
+	if !ok {
+		return
+	}
+	var req struct {
+		UnreadOnly bool
+		Offset     int
+	}
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	service := services.NewNotificationService(n.repos)
+	if notifications, err := service.Inbox(sess.UserID, req.UnreadOnly, req.Offset, inboxPageSize); err != nil {
+		w.SendError(HTTPServerErrorCode, err)
+	} else {
+		w.SendJSON(notifications)
+	}
+}
+
+// UnreadCount gets the number of unread notifications.
+func (n NotificationController) UnreadCount(w *ResponseWriter) {
+	if sess, ok := n.requireSession(w); ok {
+		w.SendJSON(map[string]uint32{"Unread": services.NewNotificationService(n.repos).UnreadCount(sess.UserID)})
+	}
+}
+
+// MarkRead marks notifications as read, or all of them if no IDs are given.
+func (n NotificationController) MarkRead(w *ResponseWriter) {
+	sess, ok := n.requireSession(w)
+	if !ok {
+		return
+	}
+	var req struct {
+		IDs []int64
+	}
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	if err := services.NewNotificationService(n.repos).MarkRead(sess.UserID, req.IDs); err != nil {
+		w.SendError(HTTPServerErrorCode, err)
+	} else {
+		w.SendSuccess()
+	}
+}
+
+// Preferences gets how each type of notification is delivered.
+func (n NotificationController) Preferences(w *ResponseWriter) {
+	sess, ok := n.requireSession(w)
+	if !ok {
+		return
+	}
+	if prefs, err := services.NewNotificationService(n.repos).Preferences(sess.UserID); err != nil {
+		w.SendError(HTTPServerErrorCode, err)
+	} else {
+		w.SendJSON(prefs)
+	}
+}
+
+// SetPreference sets how a type of notification is delivered.
+func (n NotificationController) SetPreference(w *ResponseWriter) {
+	sess, ok := n.requireSession(w)
+	if !ok {
+		return
+	}
+	var req struct {
+		NotificationType string
+		Delivery         string
+	}
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	if err := services.NewNotificationService(n.repos).SetPreference(sess.UserID, req.NotificationType, req.Delivery); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else {
+		w.SendSuccess()
+	}
+}
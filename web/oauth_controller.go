@@ -2,18 +2,19 @@ package web
 
 import (
 	"fmt"
-	"os"
 	"strings"
 
 	"github.com/hoisie/web"
 	"github.com/rchargel/goauth"
 	"github.com/rchargel/localiday/app"
+	"github.com/rchargel/localiday/db"
 	"github.com/rchargel/localiday/services"
 )
 
-// CreateOAuthController creates the OAuth controller.
-func CreateOAuthController() *OAuthController {
-	file, err := os.Open("app/oauth_config.yaml")
+// CreateOAuthController creates the OAuth controller, which stores users and
+// sessions in the repositories.
+func CreateOAuthController(repos *db.Repositories) *OAuthController {
+	file, err := app.OpenFile("app/oauth_config.yaml")
 	defer file.Close()
 	if err != nil {
 		app.Log(app.Fatal, "Could not read oauth_config.yaml file", err)
@@ -23,12 +24,13 @@ func CreateOAuthController() *OAuthController {
 	if err != nil {
 		app.Log(app.Fatal, "Could not initialize OAuth Controller", err)
 	}
-	return &OAuthController{serviceProviders}
+	return &OAuthController{serviceProviders, repos}
 }
 
 // OAuthController the controller for OAuth2 authentication calls.
 type OAuthController struct {
 	serviceProviders map[string]goauth.OAuthServiceProvider
+	repos            *db.Repositories
 }
 
 // RedirectToAuthScreen redirects the user to the correct auth screen for their request.
@@ -53,7 +55,7 @@ func (c *OAuthController) ProcessOAuthReply(ctx *web.Context, providerName strin
 		userData, err := provider.ProcessResponse(ctx.Request)
 		if hasNoError(ctx, err) {
 			app.Log(app.Debug, "Found user: %v", userData.String())
-			session, err := services.NewUserService().CreateSessionForOAuthUser(userData)
+			session, err := services.NewUserService(c.repos).CreateSessionForOAuthUser(userData)
 			if hasNoError(ctx, err) {
 				ctx.Redirect(HTTPFoundRedirectCode, fmt.Sprintf("/?token=%v", session.SessionID))
 			}
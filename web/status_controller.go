@@ -0,0 +1,90 @@
+package web
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/hoisie/web"
+	"github.com/rchargel/localiday/db"
+	"github.com/rchargel/localiday/services"
+)
+
+// StatusController controller for live display status reports.
+type StatusController struct {
+	authenticator
+}
+
+// ProcessRequest processes a status request.
+func (s StatusController) ProcessRequest(ctx *web.Context, request string) {
+	callMethod(s, NewResponseWriter(ctx), request)
+}
+
+// Submit reports the status of a display on behalf of the logged in user.
+func (s StatusController) Submit(w *ResponseWriter) {
+	sess, ok := s.requireSession(w)
+	if !ok {
+		return
+	}
+	var req struct {
+		DisplayID int64
+		Status    string
+	}
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	service := services.NewStatusService()
+	if _, err := service.Report(req.DisplayID, sess.UserID, req.Status); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else if status, err := service.CurrentStatus(req.DisplayID); err != nil {
+		w.SendError(HTTPServerErrorCode, err)
+	} else {
+		w.SendJSON(status)
+	}
+}
+
+// Delete deletes one of the logged in user's status reports.
+func (s StatusController) Delete(w *ResponseWriter) {
+	sess, ok := s.requireSession(w)
+	if !ok {
+		return
+	}
+	var req struct{ ID int64 }
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else if err = db.DeleteStatusReport(sess.UserID, req.ID); err != nil {
+		w.SendError(HTTPFileNotFoundCode, err)
+	} else {
+		w.SendSuccess()
+	}
+}
+
+// Restore restores a deleted status report.
+func (s StatusController) Restore(w *ResponseWriter) {
+	if _, ok := s.requireAdmin(w); !ok {
+		return
+	}
+	var req struct{ ID int64 }
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else if err = db.RestoreStatusReport(req.ID); err != nil {
+		w.SendError(HTTPFileNotFoundCode, err)
+	} else {
+		w.SendSuccess()
+	}
+}
+
+// RenderStatus renders the current status of a display.
+func (s StatusController) RenderStatus(ctx *web.Context, displayID string) {
+	w := NewResponseWriter(ctx)
+	id, err := strconv.ParseInt(displayID, 10, 64)
+	if err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	if status, err := services.NewStatusService().CurrentStatus(id); err != nil {
+		w.SendError(HTTPServerErrorCode, err)
+	} else {
+		w.SendJSON(status)
+	}
+}
@@ -0,0 +1,164 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hoisie/web"
+	"github.com/rchargel/localiday/app"
+	"github.com/rchargel/localiday/db"
+	"github.com/rchargel/localiday/geo"
+	"github.com/rchargel/localiday/services"
+)
+
+// TourController controller for tour rest calls.
+type TourController struct {
+	authenticator
+}
+
+// tourRequest the start of a tour and the IDs of the displays it visits.
+type tourRequest struct {
+	Start      geo.Point
+	DisplayIDs []int64
+	ReturnHome bool
+}
+
+// ProcessRequest processes a tour request.
+func (t TourController) ProcessRequest(ctx *web.Context, request string) {
+	callMethod(t, NewResponseWriter(ctx), request)
+}
+
+// Plan orders the requested stops, at most services.MaxTourStops of them,
+// into an efficient route for the logged in user.
+func (t TourController) Plan(w *ResponseWriter) {
+	if _, ok := t.requireSession(w); !ok {
+		return
+	}
+	var req tourRequest
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	tour, err := services.NewTourService().PlanDisplays(req.Start, req.DisplayIDs, req.ReturnHome)
+	if err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else {
+		w.SendJSON(tour)
+	}
+}
+
+// Save plans a tour of the requested stops, as Plan does, saves it for the
+// logged in user and returns the link it can be shared with.
+func (t TourController) Save(w *ResponseWriter) {
+	sess, ok := t.requireSession(w)
+	if !ok {
+		return
+	}
+
+	var req tourRequest
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	tour, err := services.NewTourService().PlanDisplays(req.Start, req.DisplayIDs, req.ReturnHome)
+	if err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+
+	saved := &db.Tour{
+		UserID:         sess.UserID,
+		StartLatitude:  tour.Start.Latitude,
+		StartLongitude: tour.Start.Longitude,
+		ReturnHome:     tour.ReturnHome,
+		TotalDistance:  tour.TotalDistance,
+	}
+	stops := make([]db.TourStop, len(tour.Stops))
+	for i, stop := range tour.Stops {
+		stops[i] = db.TourStop{DisplayID: stop.DisplayID, Latitude: stop.Latitude, Longitude: stop.Longitude}
+	}
+	if err = db.SaveTour(saved, stops); err != nil {
+		w.SendError(HTTPServerErrorCode, err)
+		return
+	}
+	w.SendJSON(map[string]interface{}{
+		"ShareCode": saved.ShareCode,
+		"ShareURL":  app.LoadConfiguration().HostURL + "/r/tour/shared/" + saved.ShareCode,
+		"Tour":      tour,
+	})
+}
+
+// Delete deletes one of the logged in user's saved tours.
+func (t TourController) Delete(w *ResponseWriter) {
+	sess, ok := t.requireSession(w)
+	if !ok {
+		return
+	}
+	var req struct{ ShareCode string }
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else if err = db.DeleteTour(sess.UserID, req.ShareCode); err != nil {
+		w.SendError(HTTPFileNotFoundCode, err)
+	} else {
+		w.SendSuccess()
+	}
+}
+
+// Restore restores a deleted tour.
+func (t TourController) Restore(w *ResponseWriter) {
+	if _, ok := t.requireAdmin(w); !ok {
+		return
+	}
+	var req struct{ ShareCode string }
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else if err = db.RestoreTour(req.ShareCode); err != nil {
+		w.SendError(HTTPFileNotFoundCode, err)
+	} else {
+		w.SendSuccess()
+	}
+}
+
+// RenderSharedTour renders a saved tour found by its share code.
+func (t TourController) RenderSharedTour(ctx *web.Context, shareCode string) {
+	w := NewResponseWriter(ctx)
+	saved, err := db.Tour{}.FindByShareCode(shareCode)
+	if err != nil {
+		w.SendError(HTTPFileNotFoundCode, err)
+		return
+	}
+	w.SendJSON(toTour(saved))
+}
+
+// ExportTour exports a saved tour, found by its share code, as GeoJSON, KML or
+// GPX, with the stops as waypoints and the route between them.
+func (t TourController) ExportTour(ctx *web.Context, shareCode, format string) {
+	w := NewResponseWriter(ctx)
+	saved, err := db.Tour{}.FindByShareCode(shareCode)
+	if err != nil {
+		w.SendError(HTTPFileNotFoundCode, err)
+		return
+	}
+	sendExport(w, toTourExport(saved.ShareCode, toTour(saved)), format, "localiday-tour-"+saved.ShareCode)
+}
+
+func toTourExport(name string, tour *services.Tour) *geo.Export {
+	e := &geo.Export{Name: "Localiday tour " + name, Places: make([]geo.Place, len(tour.Stops)), Route: make([]geo.Point, 0, len(tour.Legs)+1)}
+	for i, stop := range tour.Stops {
+		e.Places[i] = geo.Place{Name: fmt.Sprintf("Stop %v", i+1), Description: fmt.Sprintf("Display %v", stop.DisplayID), Point: stop.Point}
+	}
+	e.Route = append(e.Route, tour.Start)
+	for _, leg := range tour.Legs {
+		e.Route = append(e.Route, leg.To)
+	}
+	return e
+}
+
+func toTour(t *db.Tour) *services.Tour {
+	saved := t.GetStops()
+	stops := make([]services.TourStop, len(saved))
+	for i, stop := range saved {
+		stops[i] = services.TourStop{DisplayID: stop.DisplayID, Point: geo.Point{Latitude: stop.Latitude, Longitude: stop.Longitude}}
+	}
+	return services.NewTour(geo.Point{Latitude: t.StartLatitude, Longitude: t.StartLongitude}, stops, t.ReturnHome)
+}
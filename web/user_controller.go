@@ -2,6 +2,7 @@ package web
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
 
@@ -12,7 +13,9 @@ import (
 )
 
 // UserController controller for user rest calls
-type UserController struct{}
+type UserController struct {
+	authenticator
+}
 
 // ProcessRequest processes a user request.
 func (u UserController) ProcessRequest(ctx *web.Context, request string) {
@@ -40,12 +43,12 @@ func (u UserController) Login(w *ResponseWriter) {
 	d := json.NewDecoder(r.Body)
 	err := d.Decode(&cred)
 	if err == nil {
-		u, err := db.User{}.FindByUsernameAndPassword(cred.Username, cred.Password)
+		user, err := u.repos.Users.FindByUsernameAndPassword(cred.Username, cred.Password)
 		if err != nil {
 			w.SendError(HTTPUnauthorizedCode, err)
 		} else {
-			s := db.CreateNewSession(u.ID)
-			output := toUserMap(s, u)
+			s := u.repos.Sessions.Create(user.ID)
+			output := u.toUserMap(s, user)
 			w.SendJSON(output)
 		}
 	} else {
@@ -56,8 +59,11 @@ func (u UserController) Login(w *ResponseWriter) {
 // Logout logs the user out of the session.
 func (u UserController) Logout(w *ResponseWriter) {
 	if sessionID, err := w.GetSessionIDAuthorization(); err == nil {
-		db.DeleteSession(sessionID)
-		w.SendSuccess()
+		if err = u.repos.Sessions.Delete(sessionID); err != nil {
+			w.SendError(HTTPServerErrorCode, err)
+		} else {
+			w.SendSuccess()
+		}
 	} else {
 		w.SendError(HTTPUnauthorizedCode, err)
 		app.Log(app.Error, "There was no authorization in the request.")
@@ -68,12 +74,12 @@ func (u UserController) Logout(w *ResponseWriter) {
 func (u UserController) Validate(w *ResponseWriter) {
 	if sessionID, err := w.GetSessionIDAuthorization(); err == nil {
 		app.Log(app.Debug, "Validating session %v.", sessionID)
-		if sess, err := db.GetSessionBySessionID(sessionID); err == nil {
+		if sess, err := u.repos.Sessions.Get(sessionID); err == nil {
 			app.Log(app.Debug, "Found session %v.", sess.SessionID)
-			user, err := db.User{}.Get(sess.UserID)
+			user, err := u.repos.Users.Get(sess.UserID)
 			if err == nil {
 				app.Log(app.Debug, "Found user %v.", user.Username)
-				output := toUserMap(sess, user)
+				output := u.toUserMap(sess, user)
 				w.SendJSON(output)
 			}
 		} else {
@@ -84,11 +90,85 @@ func (u UserController) Validate(w *ResponseWriter) {
 	}
 }
 
-func toUserMap(s *db.Session, u *db.User) map[string]interface{} {
-	m := structs.Map(u)
+// Update saves an administrator's changes to a user. The request carries the
+// version of the user which was edited, and if the user has been changed
+// since then nothing is saved and a conflict is sent. A user saved as inactive
+// is deactivated, ending their session.
+func (u UserController) Update(w *ResponseWriter) {
+	if _, ok := u.requireAdmin(w); !ok {
+		return
+	}
+	var req struct {
+		ID       int64
+		Version  int64
+		FullName string
+		NickName string
+		Email    string
+		Active   bool
+	}
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+		return
+	}
+	user, err := u.repos.Users.Get(req.ID)
+	if err != nil {
+		w.SendError(HTTPFileNotFoundCode, err)
+		return
+	}
+	user.FullName, user.NickName, user.Email, user.Active = req.FullName, req.NickName, req.Email, req.Active
+	user.Version = req.Version
+	if err = u.repos.Users.Update(user); db.IsConflict(err) {
+		w.SendError(HTTPConflictCode, fmt.Errorf("User %v was changed by someone else, reload it and try again.", user.Username))
+	} else if err != nil {
+		w.SendError(HTTPServerErrorCode, err)
+	} else {
+		user.Password = ""
+		w.SendJSON(user)
+	}
+}
+
+// Delete soft deletes a user, who can be restored later.
+func (u UserController) Delete(w *ResponseWriter) {
+	sess, ok := u.requireAdmin(w)
+	if !ok {
+		return
+	}
+	var req struct{ ID int64 }
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else if req.ID == sess.UserID {
+		w.SendError(HTTPBadRequestCode, errors.New("Administrators may not delete themselves."))
+	} else if err = u.repos.Users.Delete(req.ID); err != nil {
+		w.SendError(HTTPFileNotFoundCode, err)
+	} else {
+		w.SendSuccess()
+	}
+}
+
+// Restore restores a deleted user.
+func (u UserController) Restore(w *ResponseWriter) {
+	if _, ok := u.requireAdmin(w); !ok {
+		return
+	}
+	var req struct{ ID int64 }
+	if err := json.NewDecoder(w.Request.Body).Decode(&req); err != nil {
+		w.SendError(HTTPBadRequestCode, err)
+	} else if err = u.repos.Users.Restore(req.ID); err != nil {
+		w.SendError(HTTPFileNotFoundCode, err)
+	} else {
+		w.SendSuccess()
+	}
+}
+
+func (u UserController) toUserMap(s *db.Session, user *db.User) map[string]interface{} {
+	m := structs.Map(user)
+	for k, v := range structs.Map(user.Audited) {
+		m[k] = v
+	}
+	delete(m, "Audited")
 	m["SessionID"] = s.SessionID
 	m["TokenType"] = "Bearer"
-	m["Authorities"] = u.GetAuthoritiesStrings()
+	m["Authorities"] = u.repos.Roles.GetAuthorities(user.ID)
 	m["LastAccessed"] = s.LastAccessed.Unix()
 	delete(m, "Password")
 
@@ -8,21 +8,24 @@ import (
 	"fmt"
 	"html/template"
 	"io"
-	"os"
+	"net"
 	"strings"
 	"time"
 
 	"github.com/hoisie/web"
+	"github.com/rchargel/localiday/app"
 )
 
 // Constants for writing to output to the browser.
 const (
-	HTTPAcceptEncoding  = "Accept-encoding"
-	HTTPContentEncoding = "Content-encoding"
-	HTTPContentLength   = "Content-length"
-	HTTPLastModified    = "Last-modified"
-	HTTPIfModifiedSince = "If-modified-since"
-	HTTPAuthorization   = "Authorization"
+	HTTPAcceptEncoding     = "Accept-encoding"
+	HTTPContentEncoding    = "Content-encoding"
+	HTTPContentLength      = "Content-length"
+	HTTPLastModified       = "Last-modified"
+	HTTPIfModifiedSince    = "If-modified-since"
+	HTTPAuthorization      = "Authorization"
+	HTTPContentDisposition = "Content-disposition"
+	HTTPForwardedFor       = "X-Forwarded-For"
 
 	HTTPOkayCode          = 200
 	HTTPFoundRedirectCode = 302
@@ -31,6 +34,7 @@ const (
 	HTTPForbiddenCode     = 403
 	HTTPFileNotFoundCode  = 404
 	HTTPInvalidMethodCode = 405
+	HTTPConflictCode      = 409
 	HTTPServerErrorCode   = 500
 
 	dateFormat = "Mon 2 Jan 2006 15:04:05 MST"
@@ -75,10 +79,11 @@ func (w *ResponseWriter) SendError(code int, err error) {
 	w.Abort(code, err.Error())
 }
 
-// SendFile outputs a file to the browser including content type.
+// SendFile outputs one of the application's files to the browser including
+// content type.
 func (w *ResponseWriter) SendFile(contentType, filepath string) {
 	w.Format = contentType
-	if file, err := os.Open(filepath); err != nil {
+	if file, err := app.OpenFile(filepath); err != nil {
 		w.SendError(HTTPFileNotFoundCode, err)
 	} else {
 		if fileInfo, err := file.Stat(); err != nil {
@@ -146,6 +151,81 @@ func (w *ResponseWriter) GetSessionIDAuthorization() (string, error) {
 	return "", errors.New("No authorization found in the request.")
 }
 
+// trustedProxies the networks of the proxies in front of the server, whose
+// forwarded addresses are believed.
+var trustedProxies []*net.IPNet
+
+// ParseNetworks parses a comma separated list of addresses and networks in
+// CIDR notation, such as "10.0.0.0/8, 192.168.1.10".
+func ParseNetworks(list string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, 4)
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("%v is not a valid address.", entry)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			entry = fmt.Sprintf("%v/%v", entry, bits)
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%v is not a valid network.", entry)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// GetClientIP gets the address of the client. The forwarded addresses are
+// only believed when the request comes from a trusted proxy, in which case
+// the client is the last address which is not a trusted proxy.
+func (w *ResponseWriter) GetClientIP() string {
+	return clientIP(w.Request.RemoteAddr, w.Request.Header.Values(HTTPForwardedFor), trustedProxies)
+}
+
+func clientIP(remoteAddr string, forwarded []string, trusted []*net.IPNet) string {
+	client := remoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		client = host
+	}
+	if !isTrusted(client, trusted) {
+		return client
+	}
+	hops := strings.Split(strings.Join(forwarded, ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		client = hop
+		if !isTrusted(hop, trusted) {
+			break
+		}
+	}
+	return client
+}
+
+func isTrusted(address string, trusted []*net.IPNet) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
 func (w *ResponseWriter) isCompressable() bool {
 	return w.Format != "text/html" && !strings.Contains(w.Format, "image/")
 }
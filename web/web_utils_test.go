@@ -0,0 +1,45 @@
+package web
+
+import "testing"
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseNetworks("10.0.0.0/8, 192.168.1.10, ::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded []string
+		expected  string
+	}{
+		{"direct", "203.0.113.7:51234", nil, "203.0.113.7"},
+		{"spoofed without a proxy", "203.0.113.7:51234", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"through a proxy", "10.1.2.3:443", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"through two proxies", "10.1.2.3:443", []string{"198.51.100.1, 192.168.1.10"}, "198.51.100.1"},
+		{"spoofed through a proxy", "10.1.2.3:443", []string{"1.1.1.1, 198.51.100.1"}, "198.51.100.1"},
+		{"in several headers", "10.1.2.3:443", []string{"1.1.1.1", "198.51.100.1, 10.9.9.9"}, "198.51.100.1"},
+		{"garbage forwarded", "10.1.2.3:443", []string{"198.51.100.1, not-an-ip"}, "10.1.2.3"},
+		{"only proxies", "10.1.2.3:443", []string{"10.4.4.4"}, "10.4.4.4"},
+		{"proxy without a header", "[::1]:8080", nil, "::1"},
+	}
+	for _, test := range tests {
+		if actual := clientIP(test.remote, test.forwarded, trusted); actual != test.expected {
+			t.Errorf("%v: got %v, expected %v", test.name, actual, test.expected)
+		}
+	}
+	if actual := clientIP("10.1.2.3:443", []string{"198.51.100.1"}, nil); actual != "10.1.2.3" {
+		t.Errorf("Forwarded addresses should be ignored without trusted proxies, got %v", actual)
+	}
+}
+
+func TestParseNetworks(t *testing.T) {
+	if networks, err := ParseNetworks(""); err != nil || len(networks) != 0 {
+		t.Errorf("An empty list should have no networks: %v, %v", networks, err)
+	}
+	for _, list := range []string{"10.0.0.0/33", "localhost", "10.0.0.1, nope"} {
+		if _, err := ParseNetworks(list); err == nil {
+			t.Errorf("ParseNetworks(%q) should fail", list)
+		}
+	}
+}